@@ -0,0 +1,40 @@
+package apikeysclient
+
+import "context"
+
+// ActorHeader is the request header naming who a mutating call is made on
+// behalf of, such as the operator using admin tooling that authenticates
+// with its own service credentials.
+const ActorHeader = "X-On-Behalf-Of"
+
+type actorContextKey struct{}
+
+// WithActor attributes every mutating call of the client to actor, such as
+// the email of a human operator, while the client keeps authenticating with
+// its own credentials. The server records actor in the audit events of the
+// calls, where ListAuditEventsOptions.Actor selects them. Reads are sent
+// without it.
+func WithActor(actor string) Option {
+	return func(c *Client, _ *options) {
+		c.actor = actor
+	}
+}
+
+// ContextWithActor returns a context attributing the mutating calls made
+// with it to actor, overriding WithActor, for tooling acting for several
+// operators with one client.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// requestActor returns who r, made with ctx, is made on behalf of, empty if
+// it is not a mutation or is made for no one.
+func (c *Client) requestActor(ctx context.Context, r *request) string {
+	if !r.mutates() {
+		return ""
+	}
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok {
+		return actor
+	}
+	return c.actor
+}
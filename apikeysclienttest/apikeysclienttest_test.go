@@ -0,0 +1,109 @@
+package apikeysclienttest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeysclienttest"
+)
+
+// backends returns clients for the same behaviours through a Fake and over
+// HTTP through a Server.
+func backends(t *testing.T) map[string]func() *apikeysclient.Client {
+	return map[string]func() *apikeysclient.Client{
+		"fake": func() *apikeysclient.Client {
+			return apikeysclienttest.NewFake().Client()
+		},
+		"server": func() *apikeysclient.Client {
+			srv := apikeysclienttest.NewServer()
+			t.Cleanup(srv.Close)
+			return srv.Client()
+		},
+	}
+}
+
+func TestClientLifecycle(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, client *apikeysclient.Client)
+	}{
+		{"create", func(t *testing.T, ctx context.Context, client *apikeysclient.Client) {
+			account := uuid.New()
+			created, err := client.CreateKey(ctx, apikeysclient.CreateAPIKeyRequest{ServiceAccountID: account, Name: "ci"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created.Key.ID == uuid.Nil || created.Secret == "" {
+				t.Fatalf("created key %+v has no ID or secret", created)
+			}
+			got, err := client.GetAPIKeyByID(ctx, created.Key.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ServiceAccountID != account || got.Name != "ci" {
+				t.Errorf("GetAPIKey = %+v, want the created key", got)
+			}
+		}},
+		{"validate", func(t *testing.T, ctx context.Context, client *apikeysclient.Client) {
+			created := create(t, ctx, client)
+			if valid, err := client.ValidateAPIKey(ctx, created.APIKey); !valid || err != nil {
+				t.Errorf("ValidateAPIKey(created) = %v, %v; want true, nil", valid, err)
+			}
+			if valid, err := client.ValidateAPIKey(ctx, "ak_unknown"); valid || err != nil {
+				t.Errorf("ValidateAPIKey(unknown) = %v, %v; want false, nil", valid, err)
+			}
+		}},
+		{"revoke", func(t *testing.T, ctx context.Context, client *apikeysclient.Client) {
+			created := create(t, ctx, client)
+			revoked, err := client.RevokeAPIKey(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked.Status != apikeysclient.KeyRevoked {
+				t.Errorf("revoked key status = %q, want %q", revoked.Status, apikeysclient.KeyRevoked)
+			}
+			if valid, err := client.ValidateAPIKey(ctx, created.APIKey); valid || err != nil {
+				t.Errorf("ValidateAPIKey(revoked) = %v, %v; want false, nil", valid, err)
+			}
+		}},
+		{"rotate", func(t *testing.T, ctx context.Context, client *apikeysclient.Client) {
+			created := create(t, ctx, client)
+			rotated, err := client.RotateAPIKey(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rotated.OldKey.ID != created.ID {
+				t.Errorf("rotated OldKey.ID = %v, want %v", rotated.OldKey.ID, created.ID)
+			}
+			if rotated.NewKey.ID == created.ID || rotated.NewKey.APIKey == "" || rotated.NewKey.APIKey == created.APIKey {
+				t.Fatalf("rotated NewKey %+v is not a new key", rotated.NewKey)
+			}
+			if valid, err := client.ValidateAPIKey(ctx, rotated.NewKey.APIKey); !valid || err != nil {
+				t.Errorf("ValidateAPIKey(new) = %v, %v; want true, nil", valid, err)
+			}
+		}},
+	}
+
+	for backend, newClient := range backends(t) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				tt.run(t, context.Background(), newClient())
+			})
+		}
+	}
+}
+
+// create creates a key with client and returns it with its material.
+func create(t *testing.T, ctx context.Context, client *apikeysclient.Client) apikeysclient.APIKey {
+	t.Helper()
+	created, err := client.CreateKey(ctx, apikeysclient.CreateAPIKeyRequest{ServiceAccountID: uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := created.Key
+	key.APIKey = created.Secret
+	return key
+}
@@ -0,0 +1,407 @@
+package apikeysclienttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/jwks"
+)
+
+// Fake is an in-memory keys service. It implements apikeysclient.Transport,
+// so a client built with Client exercises the real client code, including
+// the validation cache, middleware and scope checks, without a network:
+//
+//	fake := apikeysclienttest.NewFake()
+//	key := fake.SeedKey(serviceAccountID, "keys:read")
+//	handler := fake.Client().Middleware(next)
+//
+// A Fake serves a single tenant: the tenants of calls are accepted and
+// ignored. A Fake is safe for concurrent use.
+type Fake struct {
+	store *store
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{store: newStore()}
+}
+
+// Client returns a client whose calls are served by f. opts configure it as
+// for apikeysclient.NewClient.
+func (f *Fake) Client(opts ...apikeysclient.Option) *apikeysclient.Client {
+	opts = append(opts, apikeysclient.WithTransport(f))
+	// NewClient only fails on base URLs, which a Transport does not need.
+	client, _ := apikeysclient.NewClient("", opts...)
+	return client
+}
+
+// Seed stores keys and returns them as stored. Missing IDs, key material and
+// creation times are generated; all other fields are kept as given, so
+// inactive, invalid or expired keys can be seeded directly.
+func (f *Fake) Seed(keys ...apikeysclient.APIKey) []apikeysclient.APIKey {
+	return f.store.seed(keys...)
+}
+
+// SeedKey stores a new active, valid key for the given service account with
+// the given scopes and returns it.
+func (f *Fake) SeedKey(serviceAccountID uuid.UUID, scopes ...string) apikeysclient.APIKey {
+	return f.store.seed(apikeysclient.APIKey{
+		ServiceAccountID: serviceAccountID,
+		Status:           apikeysclient.KeyActive,
+		IsActive:         true,
+		Valid:            true,
+		Scopes:           scopes,
+	})[0]
+}
+
+// RecordUse records a use of the key with the given id at t, as reported by
+// GetAPIKeyUsage and LastUsedAt. Successful validations through the fake
+// record uses automatically.
+func (f *Fake) RecordUse(id uuid.UUID, t time.Time) error {
+	return f.store.addUse(id, t)
+}
+
+// Keys returns every stored key in creation order.
+func (f *Fake) Keys() []apikeysclient.APIKey {
+	keys, _ := f.store.list(nil)
+	return keys
+}
+
+// Webhooks returns every registered webhook in creation order, including
+// their secrets, so tests can sign deliveries with SignWebhookPayload.
+func (f *Fake) Webhooks() []apikeysclient.Webhook {
+	return f.store.listWebhooks(false)
+}
+
+// SetError makes every call of op fail with err until it is cleared with a
+// nil err. op is a Call op name such as "ValidateAPIKey"; the empty op
+// applies to all ops without an error of their own. Use *apikeysclient.APIError
+// values to simulate server responses; the Server writes them with their
+// status code and other errors as 500s.
+func (f *Fake) SetError(op string, err error) {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	if err == nil {
+		delete(f.store.faults, op)
+		return
+	}
+	f.store.faults[op] = err
+}
+
+// SetLatency delays every call of op by d, or clears the delay when d is
+// zero. The empty op applies to all ops without a latency of their own.
+// Calls stop waiting when their context is done.
+func (f *Fake) SetLatency(op string, d time.Duration) {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	if d <= 0 {
+		delete(f.store.latency, op)
+		return
+	}
+	f.store.latency[op] = d
+}
+
+// SetAsync makes the Server answer op, "CreateAPIKey", "DeleteAPIKey" or
+// "PurgeAPIKey", with 202 Accepted and the Location of an operation, as
+// servers working asynchronously do. The call is still applied at once and
+// its operation reported complete. Calls through the Transport are always synchronous.
+// RotateAPIKeys is always answered with an operation, complete as well.
+func (f *Fake) SetAsync(op string, async bool) {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	if !async {
+		delete(f.store.async, op)
+		return
+	}
+	f.store.async[op] = true
+}
+
+// Reset clears all injected errors and latencies and asynchronous ops.
+// Stored keys are kept.
+func (f *Fake) Reset() {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	clear(f.store.faults)
+	clear(f.store.latency)
+	clear(f.store.async)
+}
+
+// RoundTrip implements apikeysclient.Transport. Calls carrying an
+// idempotency key are applied once; repeats return the first result.
+func (f *Fake) RoundTrip(ctx context.Context, call *apikeysclient.Call) error {
+	if err := f.store.fault(ctx, call.Op); err != nil {
+		return err
+	}
+
+	if call.DryRun {
+		// Dry runs are applied to a copy of the store and discarded.
+		dry := &Fake{store: f.store.clone()}
+		return dry.attributed(call)
+	}
+	if call.IdempotencyKey == "" {
+		return f.attributed(call)
+	}
+	return f.store.idempotent(call.Op+" "+call.IdempotencyKey, call.Output, func() error {
+		return f.attributed(call)
+	})
+}
+
+// attributed applies call on behalf of its actor.
+func (f *Fake) attributed(call *apikeysclient.Call) (err error) {
+	f.store.as(call.Actor, func() {
+		err = f.roundTrip(call)
+	})
+	return err
+}
+
+func (f *Fake) roundTrip(call *apikeysclient.Call) error {
+	var (
+		key apikeysclient.APIKey
+		err error
+	)
+
+	switch call.Op {
+	case "CreateAPIKey":
+		key = f.store.create(call.Input.(apikeysclient.APIKey))
+	case "GetAPIKeyByID":
+		key, err = f.store.get(call.Input.(uuid.UUID))
+	case "GetAPIKeyByAPIKey", "LookupAPIKey":
+		key, err = f.store.getByHash(apikeysclient.HashAPIKey(call.Input.(string)))
+	case "GetAPIKeyByHash":
+		key, err = f.store.getByHash(call.Input.(string))
+	case "UpdateAPIKey":
+		in := call.Input.(*apikeysclient.APIKey)
+		key, err = f.store.update(*in, in.Version)
+	case "PatchAPIKey":
+		in := call.Input.(apikeysclient.PatchAPIKeyInput)
+		var doc map[string]any
+		if doc, err = patchDocument(in.Patch); err == nil {
+			key, err = f.store.patch(in.ID, doc, in.Patch.Version)
+		}
+	case "DeleteAPIKey":
+		return f.store.delete(call.Input.(uuid.UUID))
+	case "PurgeAPIKey":
+		return f.store.purge(call.Input.(uuid.UUID))
+	case "ListAPIKeys":
+		return f.list(call, nil)
+	case "ListAPIKeysPage":
+		return f.list(call, call.Input.(*apikeysclient.ListAPIKeysOptions))
+	case "SearchAPIKeys":
+		in := call.Input.(apikeysclient.SearchInput)
+		results, err := f.store.search(in.Query, in.Options)
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.SearchResults) = results
+		return nil
+	case "ListAPIKeysByServiceAccount":
+		in := call.Input.(apikeysclient.ServiceAccountListInput)
+		var opts apikeysclient.ListAPIKeysOptions
+		if in.Options != nil {
+			opts = *in.Options
+		}
+		opts.ServiceAccountID = in.ServiceAccountID
+		return f.list(call, &opts)
+	case "ValidateAPIKey", "ValidateAPIKeyPOST":
+		*call.Output.(*apikeysclient.ValidateResponse) = f.store.validate(apikeysclient.HashAPIKey(call.Input.(string)))
+		return nil
+	case "ValidateAPIKeyHash":
+		*call.Output.(*apikeysclient.ValidateResponse) = f.store.validate(call.Input.(string))
+		return nil
+	case "GetAPIKeyUsage":
+		in := call.Input.(apikeysclient.UsageInput)
+		usage, err := f.store.usage(in.ID, in.From, in.To)
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.APIKeyUsage) = usage
+		return nil
+	case "ReportUsage":
+		f.store.reportUsage(call.Input.([]apikeysclient.UsageEvent))
+		return nil
+	case "RotateAPIKey":
+		rotated, err := f.store.rotate(call.Input.(uuid.UUID))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.RotateAPIKeyResponse) = rotated
+		return nil
+	case "RotateAPIKeys":
+		*call.Output.(*apikeysclient.Operation) = f.store.rotateAll(call.Input.([]uuid.UUID))
+		return nil
+	case "RevokeAPIKey":
+		key, err = f.store.revoke(call.Input.(uuid.UUID))
+	case "ActivateAPIKey":
+		key, err = f.store.activate(call.Input.(uuid.UUID))
+	case "SuspendAPIKey":
+		key, err = f.store.suspend(call.Input.(uuid.UUID))
+	case "ResumeAPIKey":
+		key, err = f.store.resume(call.Input.(uuid.UUID))
+	case "CreateEphemeralKey":
+		in := call.Input.(apikeysclient.EphemeralKeyInput)
+		key, err = f.store.createEphemeral(in.ServiceAccountID, in.TTL, in.Scopes)
+	case "ExtendExpiry":
+		in := call.Input.(apikeysclient.ExtendExpiryInput)
+		key, err = f.store.extendExpiry(in.ID, in.ExpiresAt)
+	case "GetRestrictions":
+		restrictions, err := f.store.restrictions(call.Input.(uuid.UUID))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.Restrictions) = restrictions
+		return nil
+	case "SetRestrictions":
+		in := call.Input.(apikeysclient.SetRestrictionsInput)
+		key, err = f.store.setRestrictions(in.ID, in.Restrictions)
+	case "SetKeyQuota":
+		in := call.Input.(apikeysclient.SetKeyQuotaInput)
+		key, err = f.store.setQuota(in.ID, in.Quota)
+	case "GetKeyQuotaUsage":
+		usage, err := f.store.quotaUsage(call.Input.(uuid.UUID))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.QuotaUsage) = usage
+		return nil
+	case "GetOperation":
+		op, err := f.store.operation(call.Input.(string))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.Operation) = op
+		return nil
+	case "Health":
+		*call.Output.(*apikeysclient.HealthStatus) = f.store.health()
+		return nil
+	case "GetVersion":
+		*call.Output.(*apikeysclient.ServerVersion) = serverVersion
+		return nil
+	case "GetCapabilities":
+		*call.Output.(*apikeysclient.Capabilities) = capabilities
+		return nil
+	case "ListAuditEvents":
+		*call.Output.(*apikeysclient.AuditEventPage) = f.store.auditEvents(call.Input.(*apikeysclient.ListAuditEventsOptions))
+		return nil
+	case "RevealAPIKey":
+		material, err := f.store.reveal(call.Input.(uuid.UUID))
+		if err != nil {
+			return err
+		}
+		return decodeInto(call.Output, keyMaterial{APIKey: material})
+	case "ExchangeForToken":
+		token, err := f.store.exchange(call.Input.(string))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.Token) = token
+		return nil
+	case "GetJWKS":
+		*call.Output.(*jwks.KeySet) = f.store.tokens.jwks()
+		return nil
+	case "CreateServiceAccount":
+		account, err := f.store.createServiceAccount(call.Input.(apikeysclient.ServiceAccount))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.ServiceAccount) = account
+		return nil
+	case "GetServiceAccount":
+		account, err := f.store.getServiceAccount(call.Input.(uuid.UUID))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.ServiceAccount) = account
+		return nil
+	case "ListServiceAccounts", "ListTenantServiceAccounts":
+		*call.Output.(*[]apikeysclient.ServiceAccount) = f.store.listServiceAccounts()
+		return nil
+	case "DeleteServiceAccount":
+		in := call.Input.(apikeysclient.DeleteServiceAccountInput)
+		return f.store.deleteServiceAccount(in.ID, in.Options != nil && in.Options.Cascade)
+	case "CreateWebhook":
+		hook, err := f.store.createWebhook(call.Input.(apikeysclient.Webhook))
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.Webhook) = hook
+		return nil
+	case "ListWebhooks":
+		*call.Output.(*[]apikeysclient.Webhook) = f.store.listWebhooks(true)
+		return nil
+	case "DeleteWebhook":
+		return f.store.deleteWebhook(call.Input.(uuid.UUID))
+	case "PollRevocations":
+		revs, next := f.store.revocationsSince(call.Input.(string))
+		return decodeInto(call.Output, revocationsPage{Revocations: revs, NextSince: next})
+	default:
+		// Bulk calls fall back to single calls in the client.
+		return fmt.Errorf("%s: %w", call.Op, apikeysclient.ErrUnsupportedOperation)
+	}
+
+	if err != nil {
+		return err
+	}
+	*call.Output.(*apikeysclient.APIKey) = key
+	return nil
+}
+
+func (f *Fake) list(call *apikeysclient.Call, opts *apikeysclient.ListAPIKeysOptions) error {
+	keys, total := f.store.list(opts)
+	*call.Output.(*[]apikeysclient.APIKey) = keys
+	call.Header.Set("X-Total-Count", strconv.Itoa(total))
+	return nil
+}
+
+// serverVersion is reported by the fake's GET /version. The Server serves
+// both API versions.
+var serverVersion = apikeysclient.ServerVersion{
+	Version:     "apikeysclienttest",
+	APIVersions: []apikeysclient.APIVersion{apikeysclient.APIVersion1, apikeysclient.APIVersion2},
+}
+
+// capabilities are the optional server features the fake implements.
+var capabilities = apikeysclient.Capabilities{
+	Features: []string{apikeysclient.CapabilityBodyLookup, apikeysclient.CapabilityHashedKeys},
+}
+
+// keyMaterial is the wire form of a reveal call.
+type keyMaterial struct {
+	APIKey string `json:"api_key"`
+}
+
+// revocationsPage is the wire form of a revocations poll.
+type revocationsPage struct {
+	Revocations []apikeysclient.Revocation `json:"revocations"`
+	NextSince   string                     `json:"next_since"`
+}
+
+// decodeInto stores v in out through its JSON form, for outputs whose Go
+// type is internal to the client.
+func decodeInto(out, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// patchDocument returns the JSON Merge Patch document of patch.
+func patchDocument(patch apikeysclient.APIKeyPatch) (map[string]any, error) {
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	err = json.Unmarshal(b, &doc)
+	return doc, err
+}
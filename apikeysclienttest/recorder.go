@@ -0,0 +1,324 @@
+package apikeysclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/internal/redact"
+)
+
+// ErrNoRecordedInteraction is returned by a replaying Recorder for requests
+// matching no recorded interaction left to replay.
+var ErrNoRecordedInteraction = errors.New("no recorded interaction")
+
+// RecorderMode is whether a Recorder records exchanges or replays them.
+type RecorderMode int
+
+const (
+	// ModeReplayOrRecord replays the fixture file if it exists and records
+	// it otherwise, so deleting a fixture re-records it.
+	ModeReplayOrRecord RecorderMode = iota
+
+	// ModeReplay replays the fixture file and fails requests it has no
+	// recording for. It never reaches the network, as CI should.
+	ModeReplay
+
+	// ModeRecord sends every request and overwrites the fixture file with
+	// the exchanges when the Recorder is stopped.
+	ModeRecord
+)
+
+// Interaction is a recorded HTTP exchange.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the request of an Interaction. URL has no scheme or
+// host, so fixtures replay against any base URL.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response of an Interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// cassette is the content of a fixture file.
+type cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithRecorderTransport sets the transport recorded requests are sent
+// with, http.DefaultTransport by default. TLS, proxy and dialing options
+// of the client do not apply to a Recorder; configure this transport
+// instead.
+func WithRecorderTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.transport = rt
+	}
+}
+
+// WithRequestMatcher replaces how requests are matched to recordings. By
+// default the method, path, query and body must be those recorded, after
+// redaction; headers and the host are ignored.
+func WithRequestMatcher(match func(req *http.Request, body []byte, recorded RecordedRequest) bool) RecorderOption {
+	return func(r *Recorder) {
+		r.match = match
+	}
+}
+
+// WithRecordFilter calls filter on each exchange before it is saved, after
+// the default redaction, to scrub further data such as account names.
+func WithRecordFilter(filter func(*Interaction)) RecorderOption {
+	return func(r *Recorder) {
+		r.filter = filter
+	}
+}
+
+// Recorder is an http.RoundTripper recording the exchanges of a client to a
+// fixture file and replaying them in later runs, for integration tests that
+// cannot reach the keys service:
+//
+//	mode := apikeysclienttest.ModeReplay
+//	if *record {
+//		mode = apikeysclienttest.ModeRecord
+//	}
+//	rec, err := apikeysclienttest.NewRecorder("testdata/rotate.json", mode)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer rec.Stop()
+//	client, err := rec.Client(baseURL, apikeysclient.WithBearerToken(token))
+//
+// Secret headers, query parameters and JSON fields, and key material in
+// paths, are replaced with "REDACTED" in fixtures. Requests are redacted
+// the same way before being matched, so calls whose key material came from
+// a replayed response match their recording. Identical requests replay
+// their recordings in order. Responses are read in full before they are
+// returned when recording, so streams are recorded once they end. A
+// Recorder is safe for concurrent use.
+type Recorder struct {
+	path      string
+	mode      RecorderMode
+	transport http.RoundTripper
+	match     func(*http.Request, []byte, RecordedRequest) bool
+	filter    func(*Interaction)
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// NewRecorder returns a Recorder for the fixture file at path. It fails if
+// mode replays and the file cannot be read.
+func NewRecorder(path string, mode RecorderMode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		match:     matchRequest,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode == ModeReplayOrRecord {
+		r.mode = ModeReplay
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			r.mode = ModeRecord
+		}
+	}
+	if r.mode == ModeRecord {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	r.interactions = c.Interactions
+	r.replayed = make([]bool, len(c.Interactions))
+	return r, nil
+}
+
+// Mode returns whether r records or replays, ModeRecord or ModeReplay.
+func (r *Recorder) Mode() RecorderMode {
+	return r.mode
+}
+
+// HTTPClient returns an http.Client sending its requests through r.
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Client returns a client for the keys service at baseURL sending its
+// requests through r. opts configure it as for apikeysclient.NewClient.
+func (r *Recorder) Client(baseURL string, opts ...apikeysclient.Option) (*apikeysclient.Client, error) {
+	opts = append([]apikeysclient.Option{apikeysclient.WithHTTPClient(r.HTTPClient())}, opts...)
+	return apikeysclient.NewClient(baseURL, opts...)
+}
+
+// Stop writes the recorded exchanges to the fixture file when recording.
+// It does nothing when replaying.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.MarshalIndent(cassette{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encode fixture: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	if body != nil {
+		sent := req.Clone(req.Context())
+		sent.Body = io.NopCloser(bytes.NewReader(body))
+		req = sent
+	}
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    requestURI(req.URL),
+			Header: redact.Header(req.Header),
+			Body:   redactRecorded(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redact.Header(resp.Header),
+			Body:       redactRecorded(respBody),
+		},
+	}
+	if r.filter != nil {
+		r.filter(&in)
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.interactions {
+		if r.replayed[i] || !r.match(req, body, in.Request) {
+			continue
+		}
+		r.replayed[i] = true
+
+		header := in.Response.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		// Redaction may have changed the length of the body.
+		header.Set("Content-Length", strconv.Itoa(len(in.Response.Body)))
+		return &http.Response{
+			Status:        strconv.Itoa(in.Response.StatusCode) + " " + http.StatusText(in.Response.StatusCode),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w for %s %s in %s", ErrNoRecordedInteraction, req.Method, requestURI(req.URL), r.path)
+}
+
+// matchRequest is the default request matcher.
+func matchRequest(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	return req.Method == recorded.Method &&
+		requestURI(req.URL) == recorded.URL &&
+		redactRecorded(body) == recorded.Body
+}
+
+// requestURI returns the redacted path and query of u.
+func requestURI(u *url.URL) string {
+	return redact.URL(u).RequestURI()
+}
+
+// redactRecorded redacts body if it is JSON and returns it unchanged
+// otherwise, such as for event streams.
+func redactRecorded(body []byte) string {
+	if out, ok := redact.JSON(body); ok {
+		return string(out)
+	}
+	return string(body)
+}
+
+// readRequestBody reads and closes the body of req.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,814 @@
+package apikeysclienttest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// Server serves a Fake over the keys service REST API, for tests that need
+// real HTTP exchanges: retries, interceptors, authentication headers or
+// clients in other processes. Seeded keys and injected faults are shared with
+// the embedded Fake.
+type Server struct {
+	*httptest.Server
+
+	Fake *Fake
+}
+
+// NewServer starts a Server backed by a new Fake. Call Close when done.
+func NewServer() *Server {
+	return NewServerWithFake(NewFake())
+}
+
+// NewServerWithFake starts a Server backed by f, so the same keys can be
+// reached over HTTP and through f.Client. Call Close when done.
+func NewServerWithFake(f *Fake) *Server {
+	s := &Server{Fake: f}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// Client returns a client for s. opts configure it as for
+// apikeysclient.NewClient.
+func (s *Server) Client(opts ...apikeysclient.Option) *apikeysclient.Client {
+	opts = append([]apikeysclient.Option{apikeysclient.WithHTTPClient(s.Server.Client())}, opts...)
+	// The httptest URL is always a valid base URL.
+	client, _ := apikeysclient.NewClient(s.URL, opts...)
+	return client
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	handleOp := func(pattern string, op func(*http.Request) string, h func(http.ResponseWriter, *http.Request) (any, error)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			name := op(r)
+			if err := s.Fake.store.fault(r.Context(), name); err != nil {
+				writeError(w, err)
+				return
+			}
+
+			if r.Method != http.MethodGet && r.Header.Get(apikeysclient.DryRunHeader) == "true" {
+				// Dry runs are served from a copy of the store and discarded.
+				dry := &Server{Fake: &Fake{store: s.Fake.store.clone()}}
+				r = r.Clone(r.Context())
+				r.Header.Del(apikeysclient.DryRunHeader)
+				dry.routes().ServeHTTP(w, r)
+				return
+			}
+
+			serve := func(w http.ResponseWriter, r *http.Request) {
+				v, err := h(w, r)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				switch {
+				case v == nil:
+				case r.Method == http.MethodGet:
+					writeTagged(w, r, v)
+				default:
+					writeBody(w, r, http.StatusOK, v)
+				}
+			}
+
+			if r.Method != http.MethodGet {
+				// Mutations are attributed to the actor they are made on
+				// behalf of.
+				next := serve
+				serve = func(w http.ResponseWriter, r *http.Request) {
+					s.Fake.store.as(r.Header.Get(apikeysclient.ActorHeader), func() {
+						next(w, r)
+					})
+				}
+			}
+
+			if key := r.Header.Get(apikeysclient.IdempotencyKeyHeader); key != "" {
+				s.Fake.store.idempotentHTTP(name+" "+key, w, serve, r)
+				return
+			}
+			serve(w, r)
+		})
+	}
+	handle := func(pattern, op string, h func(http.ResponseWriter, *http.Request) (any, error)) {
+		handleOp(pattern, func(*http.Request) string { return op }, h)
+	}
+
+	st := s.Fake.store
+
+	handle("POST /apikeys", "CreateAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var key apikeysclient.APIKey
+		if err := decodeBody(r, &key); err != nil {
+			return nil, err
+		}
+		created := st.create(key)
+		if st.isAsync("CreateAPIKey") {
+			writeAccepted(w, r, st.finishOperation("/apikeys/"+created.ID.String(), nil, nil))
+			return nil, nil
+		}
+		writeBody(w, r, http.StatusCreated, created)
+		return nil, nil
+	})
+	handle("POST /apikeys/ephemeral", "CreateEphemeralKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var body struct {
+			ServiceAccountID uuid.UUID `json:"service_account_id"`
+			TTLSeconds       int64     `json:"ttl_seconds"`
+			Scopes           []string  `json:"scopes"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		key, err := st.createEphemeral(body.ServiceAccountID, time.Duration(body.TTLSeconds)*time.Second, body.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		writeBody(w, r, http.StatusCreated, key)
+		return nil, nil
+	})
+	listOp := func(r *http.Request) string {
+		// ListAPIKeys and ListAPIKeysPage share the route.
+		if r.URL.RawQuery == "" {
+			return "ListAPIKeys"
+		}
+		return "ListAPIKeysPage"
+	}
+	handleOp("GET /apikeys", listOp, func(w http.ResponseWriter, r *http.Request) (any, error) {
+		opts, err := listOptions(r)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(r.Header.Get("Accept"), apikeysclient.MediaTypeNDJSON) {
+			streamNDJSON(w, listed(w, st, opts))
+			return nil, nil
+		}
+		return listed(w, st, opts), nil
+	})
+	handle("GET /apikeys/{id}", "GetAPIKeyByID", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.get(id)
+	})
+	handle("PATCH /apikeys/{id}", "PatchAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		ifVersion, err := ifMatchVersion(r)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]any
+		if err := decodeBody(r, &doc); err != nil {
+			return nil, err
+		}
+		return st.patch(id, doc, ifVersion)
+	})
+	handle("PUT /apikeys/{id}", "UpdateAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		var key apikeysclient.APIKey
+		if err := decodeBody(r, &key); err != nil {
+			return nil, err
+		}
+		ifVersion, err := ifMatchVersion(r)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = id
+		return st.update(key, ifVersion)
+	})
+	deleteOp := func(r *http.Request) string {
+		// PurgeAPIKey is DeleteAPIKey with purge=true.
+		if r.URL.Query().Get("purge") == "true" {
+			return "PurgeAPIKey"
+		}
+		return "DeleteAPIKey"
+	}
+	handleOp("DELETE /apikeys/{id}", deleteOp, func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		op, remove := deleteOp(r), st.delete
+		if op == "PurgeAPIKey" {
+			remove = st.purge
+		}
+		if st.isAsync(op) {
+			writeAccepted(w, r, st.finishOperation("", nil, remove(id)))
+			return nil, nil
+		}
+		return nil, remove(id)
+	})
+	handle("GET /operations/{id}", "GetOperation", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return st.operation(r.PathValue("id"))
+	})
+	handle("GET /apikeys/key/{key}/validate", "ValidateAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return st.validate(apikeysclient.HashAPIKey(r.PathValue("key"))), nil
+	})
+	handleOp("POST /apikeys/lookup", keyOp("LookupAPIKey", "GetAPIKeyByHash"), func(w http.ResponseWriter, r *http.Request) (any, error) {
+		hash, err := decodeKeyLookup(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.getByHash(hash)
+	})
+	handleOp("POST /apikeys/validate", keyOp("ValidateAPIKeyPOST", "ValidateAPIKeyHash"), func(w http.ResponseWriter, r *http.Request) (any, error) {
+		hash, err := decodeKeyLookup(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.validate(hash), nil
+	})
+	handle("GET /apikeys/search", "SearchAPIKeys", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		q := r.URL.Query()
+		opts := &apikeysclient.SearchOptions{Cursor: q.Get("cursor")}
+		if v := q.Get("per_page"); v != "" {
+			var err error
+			if opts.PerPage, err = strconv.Atoi(v); err != nil {
+				return nil, badRequest("invalid per_page")
+			}
+		}
+		return st.search(q.Get("q"), opts)
+	})
+	handle("GET /audit/events", "ListAuditEvents", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		opts, err := auditOptions(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.auditEvents(opts), nil
+	})
+	handle("POST /token/exchange", "ExchangeForToken", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var body struct {
+			APIKey string `json:"api_key"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return st.exchange(body.APIKey)
+	})
+	handle("GET /.well-known/jwks.json", "GetJWKS", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return st.tokens.jwks(), nil
+	})
+	handle("POST /serviceaccounts", "CreateServiceAccount", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var account apikeysclient.ServiceAccount
+		if err := decodeBody(r, &account); err != nil {
+			return nil, err
+		}
+		created, err := st.createServiceAccount(account)
+		if err != nil {
+			return nil, err
+		}
+		writeBody(w, r, http.StatusCreated, created)
+		return nil, nil
+	})
+	handle("GET /serviceaccounts", "ListServiceAccounts", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return st.listServiceAccounts(), nil
+	})
+	handle("GET /serviceaccounts/{id}", "GetServiceAccount", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.getServiceAccount(id)
+	})
+	handle("DELETE /serviceaccounts/{id}", "DeleteServiceAccount", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.deleteServiceAccount(id, r.URL.Query().Get("cascade") == "true")
+	})
+	handle("POST /webhooks", "CreateWebhook", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var hook apikeysclient.Webhook
+		if err := decodeBody(r, &hook); err != nil {
+			return nil, err
+		}
+		created, err := st.createWebhook(hook)
+		if err != nil {
+			return nil, err
+		}
+		writeBody(w, r, http.StatusCreated, created)
+		return nil, nil
+	})
+	handle("GET /webhooks", "ListWebhooks", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return st.listWebhooks(true), nil
+	})
+	handle("DELETE /webhooks/{id}", "DeleteWebhook", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.deleteWebhook(id)
+	})
+	handle("GET /healthz", "Health", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return st.health(), nil
+	})
+	handle("GET /version", "GetVersion", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return serverVersion, nil
+	})
+	handle("GET /capabilities", "GetCapabilities", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		return capabilities, nil
+	})
+	handle("POST /apikeys/{id}/rotate", "RotateAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.rotate(id)
+	})
+	handle("POST /apikeys/usage/report", "ReportUsage", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var report struct {
+			Events []apikeysclient.UsageEvent `json:"events"`
+		}
+		if err := decodeBody(r, &report); err != nil {
+			return nil, err
+		}
+		st.reportUsage(report.Events)
+		return nil, nil
+	})
+	handle("POST /apikeys/batch/rotate", "RotateAPIKeys", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		var body struct {
+			IDs []uuid.UUID `json:"ids"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		writeAccepted(w, r, st.rotateAll(body.IDs))
+		return nil, nil
+	})
+	handle("POST /apikeys/{id}/reveal", "RevealAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		material, err := st.reveal(id)
+		if err != nil {
+			return nil, err
+		}
+		return keyMaterial{APIKey: material}, nil
+	})
+	handle("PATCH /apikeys/{id}/revoke", "RevokeAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.revoke(id)
+	})
+	handle("PATCH /apikeys/{id}/activate", "ActivateAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.activate(id)
+	})
+	handle("PATCH /apikeys/{id}/suspend", "SuspendAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.suspend(id)
+	})
+	handle("PATCH /apikeys/{id}/resume", "ResumeAPIKey", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		return st.resume(id)
+	})
+	handle("PATCH /apikeys/{id}/expiry", "ExtendExpiry", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		var body struct {
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return st.extendExpiry(id, body.ExpiresAt)
+	})
+	handle("PUT /apikeys/{id}/restrictions", "SetRestrictions", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		var restrictions apikeysclient.Restrictions
+		if err := decodeBody(r, &restrictions); err != nil {
+			return nil, err
+		}
+		return st.setRestrictions(id, restrictions)
+	})
+	handle("PUT /apikeys/{id}/quota", "SetKeyQuota", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		var quota apikeysclient.Quota
+		if err := decodeBody(r, &quota); err != nil {
+			return nil, err
+		}
+		return st.setQuota(id, quota)
+	})
+	// GET /apikeys/key/{key} overlaps every GET /apikeys/{id}/<name> route,
+	// so they are all served by one pattern dispatching on the last segment.
+	type keyRoute struct {
+		op string
+		h  func(http.ResponseWriter, *http.Request) (any, error)
+	}
+	keyRoutes := map[string]keyRoute{
+		"usage": {"GetAPIKeyUsage", func(w http.ResponseWriter, r *http.Request) (any, error) {
+			id, err := pathID(r)
+			if err != nil {
+				return nil, err
+			}
+			from, err := queryTime(r, "from")
+			if err != nil {
+				return nil, err
+			}
+			to, err := queryTime(r, "to")
+			if err != nil {
+				return nil, err
+			}
+			return st.usage(id, from, to)
+		}},
+		"restrictions": {"GetRestrictions", func(w http.ResponseWriter, r *http.Request) (any, error) {
+			id, err := pathID(r)
+			if err != nil {
+				return nil, err
+			}
+			return st.restrictions(id)
+		}},
+		"quota": {"GetKeyQuotaUsage", func(w http.ResponseWriter, r *http.Request) (any, error) {
+			id, err := pathID(r)
+			if err != nil {
+				return nil, err
+			}
+			return st.quotaUsage(id)
+		}},
+	}
+	keyRouteOp := func(r *http.Request) string {
+		if r.PathValue("id") == "key" {
+			return "GetAPIKeyByAPIKey"
+		}
+		return keyRoutes[r.PathValue("name")].op
+	}
+	handleOp("GET /apikeys/{id}/{name}", keyRouteOp, func(w http.ResponseWriter, r *http.Request) (any, error) {
+		if r.PathValue("id") == "key" {
+			return st.getByHash(apikeysclient.HashAPIKey(r.PathValue("name")))
+		}
+
+		route, ok := keyRoutes[r.PathValue("name")]
+		if !ok {
+			return nil, &apikeysclient.APIError{StatusCode: http.StatusNotFound, Code: "not_found", Message: "no such endpoint"}
+		}
+		return route.h(w, r)
+	})
+	handle("GET /serviceaccounts/{id}/apikeys", "ListAPIKeysByServiceAccount", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := listOptions(r)
+		if err != nil {
+			return nil, err
+		}
+		opts.ServiceAccountID = id
+		return listed(w, st, opts), nil
+	})
+	handle("GET /apikeys/revocations", "PollRevocations", func(w http.ResponseWriter, r *http.Request) (any, error) {
+		revs, next := st.revocationsSince(r.URL.Query().Get("since"))
+		return revocationsPage{Revocations: revs, NextSince: next}, nil
+	})
+
+	return tenantPrefix(envelopeV2(mux))
+}
+
+// tenantPrefix serves the requests of clients using
+// apikeysclient.TenantPathPrefix by stripping the tenant from their paths.
+func tenantPrefix(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/orgs/")
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// Skip the org, then the project if any.
+		_, rest, _ = strings.Cut(rest, "/")
+		if project, ok := strings.CutPrefix(rest, "projects/"); ok {
+			_, rest, _ = strings.Cut(project, "/")
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + rest
+		r2.URL.RawPath = ""
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// envelopeV2 wraps the successful JSON responses of h in the
+// apikeysclient.APIVersion2 envelope for clients asking for it, moving the
+// listing headers into its meta.
+func envelopeV2(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), apikeysclient.MediaTypeV2) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, r)
+		maps.Copy(w.Header(), res.Header())
+
+		body := res.Body.Bytes()
+		if res.Code >= 300 || len(body) == 0 || res.Header().Get("Content-Type") != "application/json" {
+			w.WriteHeader(res.Code)
+			_, _ = w.Write(body)
+			return
+		}
+
+		meta := make(map[string]any)
+		if total, err := strconv.Atoi(res.Header().Get("X-Total-Count")); err == nil {
+			meta["total_count"] = total
+		}
+		if cursor := res.Header().Get("X-Next-Cursor"); cursor != "" {
+			meta["next_cursor"] = cursor
+		}
+		w.Header().Del("X-Total-Count")
+		w.Header().Del("X-Next-Cursor")
+
+		w.Header().Set("Content-Type", apikeysclient.MediaTypeV2)
+		w.WriteHeader(res.Code)
+		_ = json.NewEncoder(w).Encode(struct {
+			Data json.RawMessage `json:"data"`
+			Meta map[string]any  `json:"meta"`
+		}{body, meta})
+	})
+}
+
+// listed returns the keys selected by opts, reporting the total count in the
+// X-Total-Count header.
+func listed(w http.ResponseWriter, st *store, opts *apikeysclient.ListAPIKeysOptions) []apikeysclient.APIKey {
+	keys, total := st.list(opts)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	return keys
+}
+
+// streamNDJSON writes keys one per line, flushing after each as a server
+// streaming from its database would.
+func streamNDJSON(w http.ResponseWriter, keys []apikeysclient.APIKey) {
+	w.Header().Set("Content-Type", apikeysclient.MediaTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for _, key := range keys {
+		if enc.Encode(key) != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// listOptions parses the list query parameters sent by the client.
+func listOptions(r *http.Request) (*apikeysclient.ListAPIKeysOptions, error) {
+	q := r.URL.Query()
+	opts := &apikeysclient.ListAPIKeysOptions{Sort: apikeysclient.SortOrder(q.Get("sort"))}
+
+	var err error
+	if v := q.Get("page"); v != "" {
+		if opts.Page, err = strconv.Atoi(v); err != nil {
+			return nil, badRequest("invalid page")
+		}
+	}
+	if v := q.Get("per_page"); v != "" {
+		if opts.PerPage, err = strconv.Atoi(v); err != nil {
+			return nil, badRequest("invalid per_page")
+		}
+	}
+	if v := q.Get("service_account_id"); v != "" {
+		if opts.ServiceAccountID, err = uuid.Parse(v); err != nil {
+			return nil, badRequest("invalid service_account_id")
+		}
+	}
+	if v := q.Get("is_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, badRequest("invalid is_active")
+		}
+		opts.IsActive = &active
+	}
+	if v := q.Get("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			opts.Status = append(opts.Status, apikeysclient.KeyStatus(status))
+		}
+	}
+	if opts.CreatedAfter, err = queryTime(r, "created_after"); err != nil {
+		return nil, err
+	}
+	if opts.Labels, err = apikeysclient.ParseLabelSelector(q.Get("label_selector")); err != nil {
+		return nil, badRequest(err.Error())
+	}
+
+	return opts, nil
+}
+
+// auditOptions parses the audit event query parameters sent by the client.
+func auditOptions(r *http.Request) (*apikeysclient.ListAuditEventsOptions, error) {
+	q := r.URL.Query()
+	opts := &apikeysclient.ListAuditEventsOptions{Cursor: q.Get("cursor"), Actor: q.Get("actor")}
+
+	var err error
+	if v := q.Get("per_page"); v != "" {
+		if opts.PerPage, err = strconv.Atoi(v); err != nil {
+			return nil, badRequest("invalid per_page")
+		}
+	}
+	if v := q.Get("key_id"); v != "" {
+		if opts.KeyID, err = uuid.Parse(v); err != nil {
+			return nil, badRequest("invalid key_id")
+		}
+	}
+	for _, t := range q["type"] {
+		opts.Types = append(opts.Types, apikeysclient.AuditEventType(t))
+	}
+	if opts.Since, err = queryTime(r, "since"); err != nil {
+		return nil, err
+	}
+	if opts.Until, err = queryTime(r, "until"); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+func queryTime(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, badRequest("invalid " + name)
+	}
+	return t, nil
+}
+
+func pathID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return uuid.Nil, badRequest("invalid id")
+	}
+	return id, nil
+}
+
+// ifMatchVersion returns the key version of the If-Match header of r, zero
+// if it has none.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	tag := r.Header.Get("If-Match")
+	if tag == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+	if err != nil {
+		return 0, badRequest("invalid If-Match header")
+	}
+	return version, nil
+}
+
+func decodeBody(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequest("invalid request body")
+	}
+	return nil
+}
+
+type keyLookup struct {
+	APIKey  string `json:"api_key"`
+	KeyHash string `json:"key_hash"`
+}
+
+// keyOp returns the op of a key lookup or validation, which depends on
+// whether the body carries the key or its hash. The body is left unread.
+func keyOp(plain, hashed string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		b, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+
+		var body keyLookup
+		if json.Unmarshal(b, &body) == nil && body.APIKey == "" && body.KeyHash != "" {
+			return hashed
+		}
+		return plain
+	}
+}
+
+// decodeKeyLookup returns the hash of the key named by a lookup or
+// validation body.
+func decodeKeyLookup(r *http.Request) (string, error) {
+	var body keyLookup
+	if err := decodeBody(r, &body); err != nil {
+		return "", err
+	}
+
+	switch {
+	case body.APIKey != "":
+		return apikeysclient.HashAPIKey(body.APIKey), nil
+	case body.KeyHash != "":
+		return body.KeyHash, nil
+	}
+	return "", badRequest("missing api_key or key_hash")
+}
+
+func badRequest(msg string) error {
+	return &apikeysclient.APIError{StatusCode: http.StatusBadRequest, Code: "bad_request", Message: msg}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// encodeFor encodes v for r: as MessagePack when r's Accept header prefers
+// it, as JSON otherwise.
+func encodeFor(r *http.Request, v any) (contentType string, body []byte, err error) {
+	if strings.HasPrefix(r.Header.Get("Accept"), apikeysclient.ContentTypeMsgpack) {
+		body, err = apikeysclient.MsgpackCodec{}.Marshal(v)
+		return apikeysclient.ContentTypeMsgpack, body, err
+	}
+	body, err = json.Marshal(v)
+	return "application/json", append(body, '\n'), err
+}
+
+// writeBody writes v in the encoding r asks for.
+func writeBody(w http.ResponseWriter, r *http.Request, status int, v any) {
+	contentType, body, err := encodeFor(r, v)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// writeAccepted answers r with 202 and op, located at its operation URL.
+func writeAccepted(w http.ResponseWriter, r *http.Request, op apikeysclient.Operation) {
+	w.Header().Set("Location", "/operations/"+op.ID)
+	writeBody(w, r, http.StatusAccepted, op)
+}
+
+// writeTagged writes v with an ETag derived from its encoding, or a 304
+// when the request's If-None-Match carries that tag.
+func writeTagged(w http.ResponseWriter, r *http.Request, v any) {
+	contentType, body, err := encodeFor(r, v)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// writeError writes err in the error format the client decodes. Errors other
+// than *apikeysclient.APIError are reported as 500s.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := &apikeysclient.APIError{StatusCode: http.StatusInternalServerError, Code: "internal", Message: err.Error()}
+	errors.As(err, &apiErr)
+
+	writeJSON(w, apiErr.StatusCode, struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}{apiErr.Code, apiErr.Message})
+}
@@ -0,0 +1,1117 @@
+// Package apikeysclienttest provides test doubles for code using
+// apikeysclient: a Fake that backs a real *apikeysclient.Client with an
+// in-memory store, and a Server that serves the same store over the REST
+// API with httptest.
+package apikeysclienttest
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// RotationGracePeriod is how long a rotated key keeps working in the fake.
+const RotationGracePeriod = 24 * time.Hour
+
+// store is the in-memory key database shared by Fake and Server.
+type store struct {
+	mu              sync.Mutex
+	keys            map[uuid.UUID]apikeysclient.APIKey
+	byHash          map[string]uuid.UUID
+	revocations     []apikeysclient.Revocation
+	uses            map[uuid.UUID][]time.Time
+	events          []apikeysclient.AuditEvent
+	webhooks        []apikeysclient.Webhook
+	serviceAccounts []apikeysclient.ServiceAccount
+	tokens          *tokenSigner
+	started         time.Time
+
+	faults  map[string]error
+	latency map[string]time.Duration
+
+	// async lists the ops the Server answers with 202 and an operation.
+	async      map[string]bool
+	operations map[string]apikeysclient.Operation
+
+	// replayMu serializes calls carrying an idempotency key so concurrent
+	// retries of a call are applied once.
+	replayMu sync.Mutex
+	replays  map[string]replay
+
+	// actorMu serializes mutating calls so the audit events they record
+	// are attributed to the actor they are made on behalf of, held in
+	// actor while they run.
+	actorMu sync.Mutex
+	actor   string
+}
+
+// replay is the recorded outcome of a call made with an idempotency key.
+type replay struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func newStore() *store {
+	return &store{
+		keys:       make(map[uuid.UUID]apikeysclient.APIKey),
+		byHash:     make(map[string]uuid.UUID),
+		uses:       make(map[uuid.UUID][]time.Time),
+		faults:     make(map[string]error),
+		latency:    make(map[string]time.Duration),
+		async:      make(map[string]bool),
+		operations: make(map[string]apikeysclient.Operation),
+		replays:    make(map[string]replay),
+		tokens:     newTokenSigner(),
+		started:    time.Now(),
+	}
+}
+
+// clone returns a copy of the data in s, without its faults, latencies and
+// recorded replays, for serving dry runs.
+func (s *store) clone() *store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := newStore()
+	c.keys = maps.Clone(s.keys)
+	c.byHash = maps.Clone(s.byHash)
+	c.revocations = slices.Clone(s.revocations)
+	for id, uses := range s.uses {
+		c.uses[id] = slices.Clone(uses)
+	}
+	c.events = slices.Clone(s.events)
+	c.webhooks = slices.Clone(s.webhooks)
+	c.serviceAccounts = slices.Clone(s.serviceAccounts)
+	c.tokens = s.tokens
+	c.started = s.started
+	return c
+}
+
+func notFound() error {
+	return &apikeysclient.APIError{StatusCode: http.StatusNotFound, Code: "not_found", Message: "API key not found"}
+}
+
+// seed stores keys as given, filling in IDs and material when missing. Keys
+// created from a hash alone keep no material.
+func (s *store) seed(keys ...apikeysclient.APIKey) []apikeysclient.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seeded := make([]apikeysclient.APIKey, len(keys))
+	for i, key := range keys {
+		if key.ID == uuid.Nil {
+			key.ID = uuid.New()
+		}
+		if key.APIKey == "" && key.KeyHash == "" {
+			key.APIKey, _ = apikeysclient.GenerateAPIKey()
+		}
+		if key.APIKey != "" {
+			key.KeyHash = apikeysclient.HashAPIKey(key.APIKey)
+			key.KeyPrefix = apikeysclient.KeyPrefix(key.APIKey)
+		}
+		if key.CreatedAt.IsZero() {
+			key.CreatedAt = time.Now().UTC()
+			key.UpdatedAt = key.CreatedAt
+		}
+		if key.Status == "" {
+			key.Status = flagStatus(&key)
+		}
+		key.SetStatus(key.Status)
+		s.put(&key)
+		seeded[i] = key
+	}
+	return seeded
+}
+
+// flagStatus returns the status of a key given with the Valid and IsActive
+// flags alone.
+func flagStatus(key *apikeysclient.APIKey) apikeysclient.KeyStatus {
+	switch {
+	case !key.Valid:
+		return apikeysclient.KeyRevoked
+	case !key.IsActive:
+		return apikeysclient.KeySuspended
+	default:
+		return apikeysclient.KeyActive
+	}
+}
+
+// put stores key as the next version of the key with its ID.
+func (s *store) put(key *apikeysclient.APIKey) {
+	old, ok := s.keys[key.ID]
+	if ok && old.KeyHash != key.KeyHash {
+		delete(s.byHash, old.KeyHash)
+	}
+	key.Version = old.Version + 1
+	s.keys[key.ID] = *key
+	s.byHash[key.KeyHash] = key.ID
+}
+
+func (s *store) create(key apikeysclient.APIKey) apikeysclient.APIKey {
+	key.ID = uuid.Nil
+	key.CreatedAt = time.Time{}
+	key = s.seed(key)[0]
+
+	s.mu.Lock()
+	s.audit(apikeysclient.AuditKeyCreated, key.ID)
+	s.mu.Unlock()
+
+	return key
+}
+
+// createEphemeral creates a key for the service account expiring after ttl.
+// Expired keys fail validation like any other, so nothing needs to clean
+// them up.
+func (s *store) createEphemeral(serviceAccountID uuid.UUID, ttl time.Duration, scopes []string) (apikeysclient.APIKey, error) {
+	if ttl <= 0 {
+		return apikeysclient.APIKey{}, badRequest("ttl must be positive")
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	return s.create(apikeysclient.APIKey{
+		ServiceAccountID: serviceAccountID,
+		Scopes:           scopes,
+		Status:           apikeysclient.KeyActive,
+		IsActive:         true,
+		Valid:            true,
+		ExpiresAt:        &expiresAt,
+	}), nil
+}
+
+func (s *store) get(id uuid.UUID) (apikeysclient.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return apikeysclient.APIKey{}, notFound()
+	}
+	return key, nil
+}
+
+func (s *store) getByHash(hash string) (apikeysclient.APIKey, error) {
+	s.mu.Lock()
+	id, ok := s.byHash[hash]
+	s.mu.Unlock()
+
+	if !ok {
+		return apikeysclient.APIKey{}, notFound()
+	}
+	return s.get(id)
+}
+
+// update replaces the key with key.ID by key, if it is at version
+// ifVersion when that is not zero.
+func (s *store) update(key apikeysclient.APIKey, ifVersion int64) (apikeysclient.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkVersion(key.ID, ifVersion); err != nil {
+		return apikeysclient.APIKey{}, err
+	}
+	return s.updateLocked(key)
+}
+
+// patch applies the JSON Merge Patch doc to the key with the given id, if
+// it is at version ifVersion when that is not zero.
+func (s *store) patch(id uuid.UUID, doc map[string]any, ifVersion int64) (apikeysclient.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkVersion(id, ifVersion); err != nil {
+		return apikeysclient.APIKey{}, err
+	}
+	old, ok := s.keys[id]
+	if !ok {
+		return apikeysclient.APIKey{}, notFound()
+	}
+
+	b, err := json.Marshal(old)
+	if err != nil {
+		return apikeysclient.APIKey{}, err
+	}
+	var current map[string]any
+	if err := json.Unmarshal(b, &current); err != nil {
+		return apikeysclient.APIKey{}, err
+	}
+	if b, err = json.Marshal(mergePatch(current, doc)); err != nil {
+		return apikeysclient.APIKey{}, err
+	}
+	var key apikeysclient.APIKey
+	if err := json.Unmarshal(b, &key); err != nil {
+		return apikeysclient.APIKey{}, badRequest("invalid patch: " + err.Error())
+	}
+	key.ID = id
+	return s.updateLocked(key)
+}
+
+// checkVersion fails with 412 if the key with the given id exists and is
+// not at version ifVersion, unless that is zero.
+func (s *store) checkVersion(id uuid.UUID, ifVersion int64) error {
+	old, ok := s.keys[id]
+	if !ok || ifVersion == 0 || old.Version == ifVersion {
+		return nil
+	}
+	return &apikeysclient.APIError{
+		StatusCode: http.StatusPreconditionFailed,
+		Code:       "version_mismatch",
+		Message:    fmt.Sprintf("API key is at version %d, not %d", old.Version, ifVersion),
+	}
+}
+
+// mergePatch applies the JSON Merge Patch patch to doc, as of RFC 7396.
+func mergePatch(doc, patch map[string]any) map[string]any {
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	for name, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(doc, name)
+		case map[string]any:
+			target, _ := doc[name].(map[string]any)
+			doc[name] = mergePatch(target, value)
+		default:
+			doc[name] = value
+		}
+	}
+	return doc
+}
+
+func (s *store) updateLocked(key apikeysclient.APIKey) (apikeysclient.APIKey, error) {
+	old, ok := s.keys[key.ID]
+	if !ok {
+		return apikeysclient.APIKey{}, notFound()
+	}
+
+	key.CreatedAt = old.CreatedAt
+	key.UpdatedAt = time.Now().UTC()
+	// Statuses only change through the lifecycle calls.
+	key.SetStatus(old.Status)
+	if key.APIKey == "" {
+		key.APIKey = old.APIKey
+	}
+	if key.APIKey != "" {
+		key.KeyHash = apikeysclient.HashAPIKey(key.APIKey)
+	} else if key.KeyHash == "" {
+		key.KeyHash = old.KeyHash
+	}
+	s.put(&key)
+	s.audit(apikeysclient.AuditKeyUpdated, key.ID)
+
+	return key, nil
+}
+
+// delete soft-deletes the key with the given id.
+func (s *store) delete(id uuid.UUID) error {
+	_, err := s.transition(id, apikeysclient.AuditKeyDeleted, apikeysclient.KeyDeleted,
+		apikeysclient.KeyPending, apikeysclient.KeyActive, apikeysclient.KeySuspended, apikeysclient.KeyRevoked)
+	return err
+}
+
+// purge removes the key with the given id and its uses.
+func (s *store) purge(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return notFound()
+	}
+	delete(s.keys, id)
+	delete(s.byHash, key.KeyHash)
+	delete(s.uses, id)
+	s.audit(apikeysclient.AuditKeyPurged, id)
+
+	return nil
+}
+
+// transition moves the key with the given id to status to and records an
+// audit event of type typ. Keys in another status than from fail with a
+// conflict; keys already in status to are returned unchanged.
+func (s *store) transition(id uuid.UUID, typ apikeysclient.AuditEventType, to apikeysclient.KeyStatus, from ...apikeysclient.KeyStatus) (apikeysclient.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return apikeysclient.APIKey{}, notFound()
+	}
+	if key.Status == to {
+		return key, nil
+	}
+	if !slices.Contains(from, key.Status) {
+		return apikeysclient.APIKey{}, &apikeysclient.APIError{
+			StatusCode: http.StatusConflict,
+			Code:       "invalid_transition",
+			Message:    fmt.Sprintf("API key is %s, cannot become %s", key.Status, to),
+		}
+	}
+	key.SetStatus(to)
+	key.UpdatedAt = time.Now().UTC()
+	s.put(&key)
+	s.audit(typ, id)
+
+	return key, nil
+}
+
+// mutate applies fn to the key with the given id, stores the result and
+// records an audit event of type typ.
+func (s *store) mutate(id uuid.UUID, typ apikeysclient.AuditEventType, fn func(*apikeysclient.APIKey)) (apikeysclient.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return apikeysclient.APIKey{}, notFound()
+	}
+	fn(&key)
+	key.UpdatedAt = time.Now().UTC()
+	s.put(&key)
+	s.audit(typ, id)
+
+	return key, nil
+}
+
+func (s *store) revoke(id uuid.UUID) (apikeysclient.APIKey, error) {
+	key, err := s.transition(id, apikeysclient.AuditKeyRevoked, apikeysclient.KeyRevoked,
+		apikeysclient.KeyPending, apikeysclient.KeyActive, apikeysclient.KeySuspended)
+	if err != nil {
+		return key, err
+	}
+
+	s.mu.Lock()
+	s.revocations = append(s.revocations, apikeysclient.Revocation{
+		KeyID:     key.ID,
+		KeyHash:   key.KeyHash,
+		RevokedAt: key.UpdatedAt,
+		ExpiresAt: key.ExpiresAt,
+	})
+	s.mu.Unlock()
+
+	return key, nil
+}
+
+func (s *store) activate(id uuid.UUID) (apikeysclient.APIKey, error) {
+	return s.transition(id, apikeysclient.AuditKeyActivated, apikeysclient.KeyActive,
+		apikeysclient.KeyPending, apikeysclient.KeySuspended)
+}
+
+func (s *store) suspend(id uuid.UUID) (apikeysclient.APIKey, error) {
+	return s.transition(id, apikeysclient.AuditKeySuspended, apikeysclient.KeySuspended, apikeysclient.KeyActive)
+}
+
+func (s *store) resume(id uuid.UUID) (apikeysclient.APIKey, error) {
+	return s.transition(id, apikeysclient.AuditKeyResumed, apikeysclient.KeyActive, apikeysclient.KeySuspended)
+}
+
+func (s *store) extendExpiry(id uuid.UUID, expiresAt time.Time) (apikeysclient.APIKey, error) {
+	return s.mutate(id, apikeysclient.AuditKeyUpdated, func(k *apikeysclient.APIKey) { k.ExpiresAt = &expiresAt })
+}
+
+func (s *store) restrictions(id uuid.UUID) (apikeysclient.Restrictions, error) {
+	key, err := s.get(id)
+	if err != nil || key.Restrictions == nil {
+		return apikeysclient.Restrictions{}, err
+	}
+	return *key.Restrictions, nil
+}
+
+// setRestrictions replaces the restrictions of a key. A zero Restrictions
+// clears them.
+func (s *store) setRestrictions(id uuid.UUID, restrictions apikeysclient.Restrictions) (apikeysclient.APIKey, error) {
+	return s.mutate(id, apikeysclient.AuditKeyUpdated, func(k *apikeysclient.APIKey) {
+		k.Restrictions = nil
+		if !restrictions.IsZero() {
+			k.Restrictions = &restrictions
+		}
+	})
+}
+
+// setQuota replaces the quota of a key. A zero Quota clears it.
+func (s *store) setQuota(id uuid.UUID, quota apikeysclient.Quota) (apikeysclient.APIKey, error) {
+	return s.mutate(id, apikeysclient.AuditKeyUpdated, func(k *apikeysclient.APIKey) {
+		k.Quota = nil
+		if !quota.IsZero() {
+			k.Quota = &quota
+		}
+	})
+}
+
+func (s *store) quotaUsage(id uuid.UUID) (apikeysclient.QuotaUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return apikeysclient.QuotaUsage{}, notFound()
+	}
+	if key.Quota.IsZero() {
+		return apikeysclient.QuotaUsage{}, nil
+	}
+	return s.quotaUsageLocked(&key, time.Now().UTC()), nil
+}
+
+// quotaUsageLocked counts the uses of a key with a quota in the period
+// containing now. s.mu must be held.
+func (s *store) quotaUsageLocked(key *apikeysclient.APIKey, now time.Time) apikeysclient.QuotaUsage {
+	start, end := quotaWindow(key.Quota.Period, now)
+	usage := apikeysclient.QuotaUsage{Limit: key.Quota.Limit, Period: key.Quota.Period, ResetsAt: end}
+	for _, t := range s.uses[key.ID] {
+		if !t.Before(start) && t.Before(end) {
+			usage.Used++
+		}
+	}
+	usage.Remaining = max(usage.Limit-usage.Used, 0)
+	return usage
+}
+
+// quotaWindow returns the UTC calendar period containing t.
+func quotaWindow(period apikeysclient.QuotaPeriod, t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	switch period {
+	case apikeysclient.QuotaPerMinute:
+		start = t.Truncate(time.Minute)
+		return start, start.Add(time.Minute)
+	case apikeysclient.QuotaPerHour:
+		start = t.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case apikeysclient.QuotaPerMonth:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+func (s *store) rotate(id uuid.UUID) (apikeysclient.RotateAPIKeyResponse, error) {
+	graceEnd := time.Now().UTC().Add(RotationGracePeriod)
+
+	old, err := s.mutate(id, apikeysclient.AuditKeyRotated, func(k *apikeysclient.APIKey) {
+		if k.ExpiresAt == nil || k.ExpiresAt.After(graceEnd) {
+			k.ExpiresAt = &graceEnd
+		}
+	})
+	if err != nil {
+		return apikeysclient.RotateAPIKeyResponse{}, err
+	}
+
+	replacement := old
+	replacement.APIKey = ""
+	replacement.KeyHash = ""
+	replacement.ExpiresAt = nil
+	replacement.SetStatus(apikeysclient.KeyActive)
+
+	return apikeysclient.RotateAPIKeyResponse{
+		NewKey:            s.create(replacement),
+		OldKey:            old,
+		GracePeriodEndsAt: graceEnd,
+	}, nil
+}
+
+// validate reports whether the key with the given hash is valid, recording
+// a use of it if so. Keys with a quota report their usage; the fake counts
+// uses over quota but does not reject them, leaving that to the client.
+func (s *store) validate(hash string) apikeysclient.ValidateResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[s.byHash[hash]]
+	if !ok {
+		s.audit(apikeysclient.AuditValidationFailure, uuid.Nil)
+		return apikeysclient.ValidateResponse{}
+	}
+
+	now := time.Now().UTC()
+	valid := key.EffectiveStatus() == apikeysclient.KeyActive
+	if valid {
+		s.recordUse(&key, now)
+		s.keys[key.ID] = key
+	} else {
+		s.audit(apikeysclient.AuditValidationFailure, key.ID)
+	}
+
+	validation := apikeysclient.ValidateResponse{IsValid: valid, ExpiresAt: key.ExpiresAt}
+	if valid && !key.Quota.IsZero() {
+		usage := s.quotaUsageLocked(&key, now)
+		validation.Quota = &usage
+	}
+	return validation
+}
+
+// health reports the fake as healthy. Unhealthy servers are simulated by
+// injecting faults for the Health op.
+func (s *store) health() apikeysclient.HealthStatus {
+	return apikeysclient.HealthStatus{
+		Status:        apikeysclient.HealthOK,
+		Version:       "apikeysclienttest",
+		UptimeSeconds: time.Since(s.started).Seconds(),
+		Dependencies: map[string]apikeysclient.DependencyHealth{
+			"store": {Status: apikeysclient.HealthOK},
+		},
+	}
+}
+
+// audit records an event of type typ about the key with the given id. s.mu
+// must be held.
+func (s *store) audit(typ apikeysclient.AuditEventType, id uuid.UUID) {
+	e := apikeysclient.AuditEvent{
+		ID:         strconv.Itoa(len(s.events) + 1),
+		Type:       typ,
+		KeyID:      id,
+		OccurredAt: time.Now().UTC(),
+	}
+	// Failed validations are attributed to the key, not to a caller.
+	if typ != apikeysclient.AuditValidationFailure {
+		e.Actor = s.actor
+	}
+	s.events = append(s.events, e)
+}
+
+// as runs the mutating call fn on behalf of actor, attributing the audit
+// events it records to actor.
+func (s *store) as(actor string, fn func()) {
+	s.actorMu.Lock()
+	defer s.actorMu.Unlock()
+
+	s.mu.Lock()
+	s.actor = actor
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.actor = ""
+		s.mu.Unlock()
+	}()
+
+	fn()
+}
+
+// auditEvents returns the page of recorded events selected by opts. Cursors
+// are positions in the event log.
+func (s *store) auditEvents(opts *apikeysclient.ListAuditEventsOptions) apikeysclient.AuditEventPage {
+	var o apikeysclient.ListAuditEventsOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, _ := strconv.Atoi(o.Cursor)
+	start = min(max(start, 0), len(s.events))
+
+	page := apikeysclient.AuditEventPage{Events: []apikeysclient.AuditEvent{}}
+	for i := start; i < len(s.events); i++ {
+		e := s.events[i]
+		if (o.KeyID != uuid.Nil && e.KeyID != o.KeyID) ||
+			(o.Actor != "" && e.Actor != o.Actor) ||
+			(len(o.Types) > 0 && !slices.Contains(o.Types, e.Type)) ||
+			(!o.Since.IsZero() && e.OccurredAt.Before(o.Since)) ||
+			(!o.Until.IsZero() && !e.OccurredAt.Before(o.Until)) {
+			continue
+		}
+
+		if o.PerPage > 0 && len(page.Events) == o.PerPage {
+			page.NextCursor = strconv.Itoa(i)
+			break
+		}
+		page.Events = append(page.Events, e)
+	}
+
+	return page
+}
+
+// search returns the page of keys matching query selected by opts, most
+// relevant first, then newest first. Cursors are positions in the ranked
+// results.
+func (s *store) search(query string, opts *apikeysclient.SearchOptions) (apikeysclient.SearchResults, error) {
+	q, err := apikeysclient.ParseSearchQuery(query)
+	if err != nil {
+		return apikeysclient.SearchResults{}, badRequest(err.Error())
+	}
+	var o apikeysclient.SearchOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	s.mu.Lock()
+	results := []apikeysclient.SearchResult{}
+	for _, key := range s.keys {
+		if q.Matches(&key) {
+			results = append(results, apikeysclient.SearchResult{Key: key, Score: q.Score(&key)})
+		}
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(results, func(a, b apikeysclient.SearchResult) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		if c := b.Key.CreatedAt.Compare(a.Key.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.Key.ID[:], b.Key.ID[:])
+	})
+
+	page := apikeysclient.SearchResults{TotalCount: len(results)}
+	start, _ := strconv.Atoi(o.Cursor)
+	start = min(max(start, 0), len(results))
+	end := len(results)
+	if o.PerPage > 0 && start+o.PerPage < end {
+		end = start + o.PerPage
+		page.NextCursor = strconv.Itoa(end)
+	}
+	page.Results = results[start:end]
+
+	return page, nil
+}
+
+// reveal returns the key material of the key with the given id. Keys
+// created from a hash only have none.
+func (s *store) reveal(id uuid.UUID) (string, error) {
+	key, err := s.get(id)
+	if err != nil {
+		return "", err
+	}
+	if key.APIKey == "" {
+		return "", notFound()
+	}
+	return key.APIKey, nil
+}
+
+func serviceAccountNotFound() error {
+	return &apikeysclient.APIError{StatusCode: http.StatusNotFound, Code: "not_found", Message: "service account not found"}
+}
+
+// createServiceAccount stores account with a new ID.
+func (s *store) createServiceAccount(account apikeysclient.ServiceAccount) (apikeysclient.ServiceAccount, error) {
+	if account.Name == "" {
+		return apikeysclient.ServiceAccount{}, badRequest("name is required")
+	}
+
+	account.ID = uuid.New()
+	account.CreatedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.serviceAccounts = append(s.serviceAccounts, account)
+	return account, nil
+}
+
+func (s *store) getServiceAccount(id uuid.UUID) (apikeysclient.ServiceAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.serviceAccounts, func(a apikeysclient.ServiceAccount) bool { return a.ID == id })
+	if i < 0 {
+		return apikeysclient.ServiceAccount{}, serviceAccountNotFound()
+	}
+	return s.serviceAccounts[i], nil
+}
+
+// listServiceAccounts returns the service accounts in creation order.
+func (s *store) listServiceAccounts() []apikeysclient.ServiceAccount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := slices.Clone(s.serviceAccounts)
+	if accounts == nil {
+		accounts = []apikeysclient.ServiceAccount{}
+	}
+	return accounts
+}
+
+// deleteServiceAccount deletes the service account with the given id. With
+// cascade its keys are deleted too; otherwise it fails with a conflict while
+// the account owns keys.
+func (s *store) deleteServiceAccount(id uuid.UUID, cascade bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.serviceAccounts, func(a apikeysclient.ServiceAccount) bool { return a.ID == id })
+	if i < 0 {
+		return serviceAccountNotFound()
+	}
+
+	var owned []apikeysclient.APIKey
+	live := 0
+	for _, key := range s.keys {
+		if key.ServiceAccountID == id {
+			owned = append(owned, key)
+			if key.Status != apikeysclient.KeyDeleted {
+				live++
+			}
+		}
+	}
+	// Soft-deleted keys do not hold the account back and go with it.
+	if live > 0 && !cascade {
+		return &apikeysclient.APIError{StatusCode: http.StatusConflict, Code: "conflict", Message: "service account still owns API keys"}
+	}
+
+	for _, key := range owned {
+		delete(s.keys, key.ID)
+		delete(s.byHash, key.KeyHash)
+		delete(s.uses, key.ID)
+		s.audit(apikeysclient.AuditKeyDeleted, key.ID)
+	}
+	s.serviceAccounts = slices.Delete(s.serviceAccounts, i, i+1)
+	return nil
+}
+
+// createWebhook stores hook with a new ID and, unless given, a generated
+// secret.
+func (s *store) createWebhook(hook apikeysclient.Webhook) (apikeysclient.Webhook, error) {
+	if hook.URL == "" {
+		return apikeysclient.Webhook{}, badRequest("url is required")
+	}
+
+	hook.ID = uuid.New()
+	hook.CreatedAt = time.Now().UTC()
+	if hook.Secret == "" {
+		secret, err := apikeysclient.GenerateAPIKey()
+		if err != nil {
+			return apikeysclient.Webhook{}, err
+		}
+		hook.Secret = secret
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks = append(s.webhooks, hook)
+	return hook, nil
+}
+
+// listWebhooks returns the registered webhooks in creation order, with their
+// secrets unless redact is set.
+func (s *store) listWebhooks(redact bool) []apikeysclient.Webhook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hooks := slices.Clone(s.webhooks)
+	if hooks == nil {
+		hooks = []apikeysclient.Webhook{}
+	}
+	if redact {
+		for i := range hooks {
+			hooks[i].Secret = ""
+		}
+	}
+	return hooks
+}
+
+func (s *store) deleteWebhook(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.webhooks, func(h apikeysclient.Webhook) bool { return h.ID == id })
+	if i < 0 {
+		return notFound()
+	}
+	s.webhooks = slices.Delete(s.webhooks, i, i+1)
+	return nil
+}
+
+// recordUse records a use of key at t. s.mu must be held.
+func (s *store) recordUse(key *apikeysclient.APIKey, t time.Time) {
+	s.uses[key.ID] = append(s.uses[key.ID], t)
+	if key.LastUsedAt == nil || t.After(*key.LastUsedAt) {
+		key.LastUsedAt = &t
+	}
+}
+
+func (s *store) addUse(id uuid.UUID, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return notFound()
+	}
+	s.recordUse(&key, t)
+	s.keys[id] = key
+
+	return nil
+}
+
+// reportUsage records the uses in events. Uses of unknown keys are ignored,
+// as a key may be purged before the uses of it are reported.
+func (s *store) reportUsage(events []apikeysclient.UsageEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range events {
+		key, ok := s.keys[e.KeyID]
+		if !ok {
+			continue
+		}
+		s.recordUse(&key, e.UsedAt)
+		s.keys[e.KeyID] = key
+	}
+}
+
+// usage reports the recorded uses of the key with the given id between from
+// and to; zero bounds are open.
+func (s *store) usage(id uuid.UUID, from, to time.Time) (apikeysclient.APIKeyUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return apikeysclient.APIKeyUsage{}, notFound()
+	}
+
+	usage := apikeysclient.APIKeyUsage{KeyID: id, From: from, To: to, LastUsedAt: key.LastUsedAt}
+	for _, t := range s.uses[id] {
+		if (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to)) {
+			usage.RequestCount++
+		}
+	}
+
+	return usage, nil
+}
+
+// list returns the page of keys selected by opts and the total number of
+// matching keys.
+func (s *store) list(opts *apikeysclient.ListAPIKeysOptions) ([]apikeysclient.APIKey, int) {
+	s.mu.Lock()
+	keys := make([]apikeysclient.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.Status == apikeysclient.KeyDeleted && (opts == nil || !slices.Contains(opts.Status, apikeysclient.KeyDeleted)) {
+			continue
+		}
+		if opts != nil {
+			if opts.ServiceAccountID != uuid.Nil && key.ServiceAccountID != opts.ServiceAccountID {
+				continue
+			}
+			if opts.IsActive != nil && key.IsActive != *opts.IsActive {
+				continue
+			}
+			if len(opts.Status) > 0 && !slices.Contains(opts.Status, key.EffectiveStatus()) {
+				continue
+			}
+			if !opts.CreatedAfter.IsZero() && !key.CreatedAt.After(opts.CreatedAfter) {
+				continue
+			}
+			if !opts.Labels.Matches(key.Labels) {
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+
+	desc := opts != nil && opts.Sort == apikeysclient.SortCreatedDesc
+	slices.SortFunc(keys, func(a, b apikeysclient.APIKey) int {
+		c := a.CreatedAt.Compare(b.CreatedAt)
+		if c == 0 {
+			c = slices.Compare(a.ID[:], b.ID[:])
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+
+	total := len(keys)
+	if opts != nil && opts.PerPage > 0 {
+		page := max(opts.Page, 1)
+		start := min((page-1)*opts.PerPage, total)
+		end := min(start+opts.PerPage, total)
+		keys = keys[start:end]
+	}
+
+	return keys, total
+}
+
+// revocationsSince returns the revocations after the cursor since and the
+// cursor to pass next.
+func (s *store) revocationsSince(since string) ([]apikeysclient.Revocation, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, _ := strconv.Atoi(since)
+	start = min(max(start, 0), len(s.revocations))
+
+	revs := slices.Clone(s.revocations[start:])
+	return revs, strconv.Itoa(len(s.revocations))
+}
+
+// isAsync reports whether the Server answers op asynchronously.
+func (s *store) isAsync(op string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.async[op]
+}
+
+// finishOperation records an operation that completed with err, or
+// succeeded with the result at resource or the inline result, either of
+// which may be empty.
+func (s *store) finishOperation(resource string, result any, err error) apikeysclient.Operation {
+	now := time.Now().UTC()
+	op := apikeysclient.Operation{
+		ID:        "op_" + uuid.NewString(),
+		Status:    apikeysclient.OperationSucceeded,
+		Resource:  resource,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if result != nil && err == nil {
+		op.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		op.Status = apikeysclient.OperationFailed
+		op.Resource = ""
+		op.Error = err.Error()
+		var apiErr *apikeysclient.APIError
+		if errors.As(err, &apiErr) {
+			op.Error = apiErr.Message
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations[op.ID] = op
+	return op
+}
+
+// rotationResults mirrors the result of a bulk rotation.
+type rotationResults struct {
+	Results []rotationResult `json:"results"`
+}
+
+type rotationResult struct {
+	ID       uuid.UUID                           `json:"id"`
+	Rotation *apikeysclient.RotateAPIKeyResponse `json:"rotation,omitempty"`
+	Error    *itemError                          `json:"error,omitempty"`
+}
+
+// itemError mirrors the per-item error of bulk results.
+type itemError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// rotateAll rotates the keys with the given ids and records the outcome as
+// a completed operation.
+func (s *store) rotateAll(ids []uuid.UUID) apikeysclient.Operation {
+	results := rotationResults{Results: make([]rotationResult, len(ids))}
+	for i, id := range ids {
+		results.Results[i].ID = id
+		rotated, err := s.rotate(id)
+		if err != nil {
+			apiErr := &apikeysclient.APIError{StatusCode: http.StatusInternalServerError, Code: "internal", Message: err.Error()}
+			errors.As(err, &apiErr)
+			results.Results[i].Error = &itemError{Status: apiErr.StatusCode, Code: apiErr.Code, Message: apiErr.Message}
+			continue
+		}
+		results.Results[i].Rotation = &rotated
+	}
+	return s.finishOperation("", results, nil)
+}
+
+func (s *store) operation(id string) (apikeysclient.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return apikeysclient.Operation{}, notFound()
+	}
+	return op, nil
+}
+
+// fault waits out the latency injected for op and returns the error injected
+// for it, if any. Entries for the empty op apply to every op without one of
+// its own.
+func (s *store) fault(ctx context.Context, op string) error {
+	s.mu.Lock()
+	d, ok := s.latency[op]
+	if !ok {
+		d = s.latency[""]
+	}
+	err, ok := s.faults[op]
+	if !ok {
+		err = s.faults[""]
+	}
+	s.mu.Unlock()
+
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	return err
+}
+
+// idempotent runs fn for the first call with the given idempotency key and
+// replays the JSON form of its result into out for later calls with the same
+// key. Failed calls are not recorded, so they can be retried.
+func (s *store) idempotent(key string, out any, fn func() error) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	if r, ok := s.replays[key]; ok {
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(r.body, out)
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	s.replays[key] = replay{body: b}
+
+	return nil
+}
+
+// idempotentHTTP is idempotent for the Server: the first successful
+// response written by h for the given key is recorded and written again for
+// later requests with the same key.
+func (s *store) idempotentHTTP(key string, w http.ResponseWriter, h http.HandlerFunc, r *http.Request) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	rec, ok := s.replays[key]
+	if !ok {
+		res := httptest.NewRecorder()
+		h(res, r)
+
+		rec = replay{status: res.Code, header: res.Header(), body: res.Body.Bytes()}
+		if rec.status < 300 {
+			s.replays[key] = rec
+		}
+	}
+
+	maps.Copy(w.Header(), rec.header)
+	w.WriteHeader(rec.status)
+	_, _ = w.Write(rec.body)
+}
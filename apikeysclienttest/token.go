@@ -0,0 +1,88 @@
+package apikeysclienttest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/jwks"
+)
+
+// TokenTTL is the lifetime of tokens issued by the fake's token exchange.
+const TokenTTL = 15 * time.Minute
+
+// tokenIssuer is the iss claim of tokens issued by the fake.
+const tokenIssuer = "apikeysclienttest"
+
+// tokenSigner signs the fake's tokens with an Ed25519 key generated for
+// each store.
+type tokenSigner struct {
+	kid string
+	key ed25519.PrivateKey
+}
+
+func newTokenSigner() *tokenSigner {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return &tokenSigner{kid: uuid.NewString(), key: key}
+}
+
+// jwks returns the JSON Web Key Set publishing the signer's public key.
+func (ts *tokenSigner) jwks() jwks.KeySet {
+	pub := ts.key.Public().(ed25519.PublicKey)
+	return jwks.KeySet{Keys: []jwks.Key{{
+		KeyType:   "OKP",
+		KeyID:     ts.kid,
+		Algorithm: "EdDSA",
+		Use:       "sig",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(pub),
+	}}}
+}
+
+// sign returns a JWT for key valid from now for TokenTTL.
+func (ts *tokenSigner) sign(key apikeysclient.APIKey, now time.Time) apikeysclient.Token {
+	expiresAt := now.Add(TokenTTL)
+	header, _ := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": ts.kid})
+	payload, _ := json.Marshal(map[string]any{
+		"iss":    tokenIssuer,
+		"sub":    key.ServiceAccountID.String(),
+		"key_id": key.ID.String(),
+		"scope":  strings.Join(key.Scopes, " "),
+		"iat":    now.Unix(),
+		"exp":    expiresAt.Unix(),
+	})
+
+	b64 := base64.RawURLEncoding
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	sig := ed25519.Sign(ts.key, []byte(signed))
+
+	return apikeysclient.Token{
+		AccessToken: signed + "." + b64.EncodeToString(sig),
+		TokenType:   "Bearer",
+		ExpiresAt:   time.Unix(expiresAt.Unix(), 0).UTC(),
+	}
+}
+
+// exchange issues a token for apiKey if it is valid.
+func (s *store) exchange(apiKey string) (apikeysclient.Token, error) {
+	hash := apikeysclient.HashAPIKey(apiKey)
+	if !s.validate(hash).IsValid {
+		return apikeysclient.Token{}, &apikeysclient.APIError{StatusCode: http.StatusUnauthorized, Code: "invalid_api_key", Message: "API key is not valid"}
+	}
+
+	key, err := s.getByHash(hash)
+	if err != nil {
+		return apikeysclient.Token{}, err
+	}
+	return s.tokens.sign(key, time.Now()), nil
+}
@@ -0,0 +1,5 @@
+// Package apikeyspb contains the protobuf messages and gRPC service
+// definitions of the keys server, generated from proto/apikeys/v1.
+package apikeyspb
+
+//go:generate protoc --proto_path=../proto --go_out=.. --go_opt=module=github.com/PiccoloMondoC/apikeysclient --go-grpc_out=.. --go-grpc_opt=module=github.com/PiccoloMondoC/apikeysclient apikeys/v1/apikeys.proto
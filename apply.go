@@ -0,0 +1,207 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeySpec is the desired state of a key managed by Apply. Keys are
+// identified by Name, which must be unique among the specs passed to one
+// Apply call.
+type APIKeySpec struct {
+	Name             string
+	ServiceAccountID uuid.UUID
+	Description      string
+	Scopes           []string
+	Labels           map[string]string
+	ExpiresAt        *time.Time
+}
+
+// ApplyOptions controls Apply.
+type ApplyOptions struct {
+	// Selector restricts the keys Apply manages to those carrying its
+	// labels. Every spec must match it.
+	Selector LabelSelector
+
+	// Prune revokes managed keys that no spec names. Without a Selector it
+	// applies to every active named key on the server.
+	Prune bool
+}
+
+// ChangeAction is the kind of change made by Apply.
+type ChangeAction string
+
+// Changes made by Apply.
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeRevoke ChangeAction = "revoke"
+)
+
+// Change is a change made, or attempted, by Apply.
+type Change struct {
+	Action ChangeAction
+	Name   string
+
+	// Key is the key after the change. Created keys carry their material.
+	Key *APIKey
+
+	// Err is the error the change failed with, if any.
+	Err error
+}
+
+// ApplyReport lists the changes made by Apply.
+type ApplyReport struct {
+	Changes []Change
+
+	// Unchanged is the number of keys already matching their spec.
+	Unchanged int
+}
+
+// ErrInvalidSpec is returned by Apply for specs it cannot reconcile, such
+// as duplicate or missing names.
+var ErrInvalidSpec = errors.New("invalid API key spec")
+
+// Apply converges the active, named keys matching opts.Selector towards
+// desired: keys missing on the server are created, keys that differ from
+// their spec are updated and, with opts.Prune, keys without a spec are
+// revoked. Inactive keys are left alone, so a spec whose key was revoked
+// gets a new key. A key whose service account differs from its spec is
+// replaced by a new one. Keys sharing a name are treated as one key and
+// its duplicates; the duplicates are revoked with opts.Prune.
+//
+// Every change is attempted even if others fail; the report lists them all
+// and the returned error joins their errors. Combine with WithDryRun or
+// ContextWithDryRun to plan changes without making them. opts may be nil.
+func (c *Client) Apply(ctx context.Context, desired []APIKeySpec, opts *ApplyOptions) (*ApplyReport, error) {
+	if opts == nil {
+		opts = &ApplyOptions{}
+	}
+
+	specs := make(map[string]*APIKeySpec, len(desired))
+	for i := range desired {
+		spec := &desired[i]
+		switch {
+		case spec.Name == "":
+			return nil, fmt.Errorf("%w: spec %d has no name", ErrInvalidSpec, i)
+		case specs[spec.Name] != nil:
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrInvalidSpec, spec.Name)
+		case !opts.Selector.Matches(spec.Labels):
+			return nil, fmt.Errorf("%w: labels of %q do not match selector %s", ErrInvalidSpec, spec.Name, opts.Selector)
+		}
+		specs[spec.Name] = spec
+	}
+
+	active := true
+	current := make(map[string]APIKey)
+	var extra []APIKey
+	it := c.ListAPIKeysIter(&ListAPIKeysOptions{IsActive: &active, Labels: opts.Selector, Sort: SortCreatedAsc})
+	for it.Next(ctx) {
+		key := it.APIKey()
+		switch {
+		case key.Name == "":
+		case current[key.Name].ID == uuid.Nil:
+			current[key.Name] = key
+		default:
+			extra = append(extra, key)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("list current keys: %w", err)
+	}
+
+	report := &ApplyReport{}
+	var errs []error
+	record := func(change Change) {
+		report.Changes = append(report.Changes, change)
+		if change.Err != nil {
+			errs = append(errs, fmt.Errorf("%s %q: %w", change.Action, change.Name, change.Err))
+		}
+	}
+	// Every change is a call of its own: a caller's idempotency key is
+	// suffixed per change so the server does not replay the first one.
+	var calls int
+	next := func() context.Context {
+		calls++
+		return itemContext(ctx, calls-1)
+	}
+	revoke := func(key APIKey) {
+		revoked, err := c.RevokeAPIKey(next(), key.ID)
+		record(Change{Action: ChangeRevoke, Name: key.Name, Key: revoked, Err: err})
+	}
+
+	for _, spec := range desired {
+		key, ok := current[spec.Name]
+		switch {
+		case !ok:
+			created, err := c.createAPIKey(next(), spec.apiKey())
+			record(Change{Action: ChangeCreate, Name: spec.Name, Key: keyOrNil(created, err), Err: err})
+		case key.ServiceAccountID != spec.ServiceAccountID:
+			created, err := c.createAPIKey(next(), spec.apiKey())
+			record(Change{Action: ChangeCreate, Name: spec.Name, Key: keyOrNil(created, err), Err: err})
+			if err == nil {
+				revoke(key)
+			}
+		case !spec.matches(&key):
+			spec.applyTo(&key)
+			updated, err := c.UpdateAPIKey(next(), &key)
+			record(Change{Action: ChangeUpdate, Name: spec.Name, Key: updated, Err: err})
+		default:
+			report.Unchanged++
+		}
+	}
+
+	if opts.Prune {
+		for _, name := range slices.Sorted(maps.Keys(current)) {
+			if specs[name] == nil {
+				revoke(current[name])
+			}
+		}
+		for _, key := range extra {
+			revoke(key)
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
+
+func keyOrNil(key APIKey, err error) *APIKey {
+	if err != nil {
+		return nil
+	}
+	return &key
+}
+
+// apiKey returns a new active key with the spec's attributes.
+func (s *APIKeySpec) apiKey() APIKey {
+	key := APIKey{ServiceAccountID: s.ServiceAccountID, Status: KeyActive, IsActive: true, Valid: true}
+	s.applyTo(&key)
+	return key
+}
+
+// applyTo sets the attributes managed by the spec on key.
+func (s *APIKeySpec) applyTo(key *APIKey) {
+	key.Name = s.Name
+	key.Description = s.Description
+	key.Scopes = slices.Clone(s.Scopes)
+	key.Labels = maps.Clone(s.Labels)
+	key.ExpiresAt = s.ExpiresAt
+}
+
+// matches reports whether key has the attributes managed by the spec.
+// Scopes are compared regardless of order.
+func (s *APIKeySpec) matches(key *APIKey) bool {
+	sameExpiry := (s.ExpiresAt == nil) == (key.ExpiresAt == nil) &&
+		(s.ExpiresAt == nil || s.ExpiresAt.Equal(*key.ExpiresAt))
+
+	return key.Description == s.Description &&
+		slices.Equal(slices.Sorted(slices.Values(key.Scopes)), slices.Sorted(slices.Values(s.Scopes))) &&
+		maps.Equal(key.Labels, s.Labels) &&
+		sameExpiry
+}
@@ -0,0 +1,64 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeysclienttest"
+)
+
+// TestApplySuffixesIdempotencyKeys checks that each change made by Apply
+// under a caller's idempotency key is sent with a key of its own.
+func TestApplySuffixesIdempotencyKeys(t *testing.T) {
+	srv := apikeysclienttest.NewServer()
+	defer srv.Close()
+	account := uuid.New()
+	srv.Fake.Seed(
+		apikeysclient.APIKey{Name: "app", ServiceAccountID: account, Status: apikeysclient.KeyActive, IsActive: true, Valid: true},
+		apikeysclient.APIKey{Name: "stale", ServiceAccountID: account, Status: apikeysclient.KeyActive, IsActive: true, Valid: true},
+	)
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	client := srv.Client(apikeysclient.WithRequestInterceptor(func(req *http.Request) error {
+		if key := req.Header.Get(apikeysclient.IdempotencyKeyHeader); key != "" {
+			mu.Lock()
+			keys = append(keys, key)
+			mu.Unlock()
+		}
+		return nil
+	}))
+	ctx := apikeysclient.ContextWithIdempotencyKey(context.Background(), "apply")
+
+	report, err := client.Apply(ctx, []apikeysclient.APIKeySpec{
+		{Name: "app", ServiceAccountID: account, Description: "updated"},
+		{Name: "worker", ServiceAccountID: account},
+		{Name: "cron", ServiceAccountID: account},
+	}, &apikeysclient.ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 4 {
+		t.Fatalf("got %d changes, want 4: %+v", len(report.Changes), report.Changes)
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "apply-") || seen[key] {
+			t.Errorf("idempotency keys = %q, want distinct keys derived from %q", keys, "apply")
+			break
+		}
+		seen[key] = true
+	}
+	if len(keys) != 2 {
+		t.Errorf("got %d idempotency keys, want one per create: %q", len(keys), keys)
+	}
+}
@@ -0,0 +1,178 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEventType is the kind of action recorded by an audit event.
+type AuditEventType string
+
+// Audit event types reported by the server.
+const (
+	AuditKeyCreated        AuditEventType = "key.created"
+	AuditKeyUpdated        AuditEventType = "key.updated"
+	AuditKeyRotated        AuditEventType = "key.rotated"
+	AuditKeyRevoked        AuditEventType = "key.revoked"
+	AuditKeyActivated      AuditEventType = "key.activated"
+	AuditKeyDeleted        AuditEventType = "key.deleted"
+	AuditValidationFailure AuditEventType = "key.validation_failed"
+)
+
+// AuditEvent records an action on a key.
+type AuditEvent struct {
+	ID    string         `json:"id"`
+	Type  AuditEventType `json:"type"`
+	KeyID uuid.UUID      `json:"key_id"`
+
+	// Actor identifies who performed the action, such as a user or service
+	// account. It is empty for actions attributed to the key itself, like
+	// failed validations.
+	Actor string `json:"actor,omitempty"`
+
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// Metadata holds type-specific details, such as the requesting address
+	// of a failed validation.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ListAuditEventsOptions selects a page of audit events. Zero fields are not
+// sent.
+type ListAuditEventsOptions struct {
+	PerPage int
+	Cursor  string
+
+	KeyID uuid.UUID
+	Actor string
+	Types []AuditEventType
+
+	// Since and Until bound OccurredAt; Since is inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// values encodes o as query parameters.
+func (o *ListAuditEventsOptions) values() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+
+	if o.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.KeyID != uuid.Nil {
+		q.Set("key_id", o.KeyID.String())
+	}
+	if o.Actor != "" {
+		q.Set("actor", o.Actor)
+	}
+	for _, t := range o.Types {
+		q.Add("type", string(t))
+	}
+	if !o.Since.IsZero() {
+		q.Set("since", o.Since.UTC().Format(time.RFC3339))
+	}
+	if !o.Until.IsZero() {
+		q.Set("until", o.Until.UTC().Format(time.RFC3339))
+	}
+
+	return q
+}
+
+// AuditEventPage is one page of audit events.
+type AuditEventPage struct {
+	Events []AuditEvent `json:"events"`
+
+	// NextCursor is the cursor of the following page, empty on the last.
+	NextCursor string `json:"next_cursor"`
+}
+
+// ListAuditEvents retrieves the page of audit events selected by opts, oldest
+// first.
+func (c *Client) ListAuditEvents(ctx context.Context, opts *ListAuditEventsOptions) (*AuditEventPage, error) {
+	var page AuditEventPage
+	_, err := c.do(ctx, &request{
+		op:     "ListAuditEvents",
+		method: http.MethodGet,
+		url:    c.endpoint("audit", "events"),
+		query:  opts.values(),
+		in:     opts,
+	}, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// AuditEventIterator walks every page of an audit event listing, like
+// APIKeyIterator does for keys.
+type AuditEventIterator struct {
+	client *Client
+	opts   ListAuditEventsOptions
+
+	events []AuditEvent
+	pos    int
+	done   bool
+	err    error
+}
+
+// ListAuditEventsIter returns an iterator over all audit events matching
+// opts, fetching further pages as needed.
+func (c *Client) ListAuditEventsIter(opts *ListAuditEventsOptions) *AuditEventIterator {
+	it := &AuditEventIterator{client: c, pos: -1}
+	if opts != nil {
+		it.opts = *opts
+	}
+	return it
+}
+
+// Next advances to the next event, fetching the next page when the current
+// one is exhausted. It returns false when there are no more events or an
+// error occurred.
+func (it *AuditEventIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.pos++
+	for it.pos >= len(it.events) {
+		if it.done {
+			return false
+		}
+
+		page, err := it.client.ListAuditEvents(ctx, &it.opts)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.events = page.Events
+		it.pos = 0
+		it.opts.Cursor = page.NextCursor
+		it.done = page.NextCursor == "" || len(page.Events) == 0
+	}
+
+	return true
+}
+
+// Event returns the current event. It is only valid after Next returned
+// true.
+func (it *AuditEventIterator) Event() AuditEvent {
+	return it.events[it.pos]
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *AuditEventIterator) Err() error {
+	return it.err
+}
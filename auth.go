@@ -0,0 +1,75 @@
+package apikeysclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// TokenSource supplies the bearer token sent with each request. Token is
+// called for every attempt, so implementations should cache tokens and
+// refresh them before they expire. It must be safe for concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f.
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// WithBearerToken authenticates requests with a static bearer token in the
+// Authorization header.
+func WithBearerToken(token string) Option {
+	return func(c *Client, _ *options) {
+		c.Token = token
+	}
+}
+
+// WithTokenSource authenticates requests with bearer tokens obtained from ts,
+// taking precedence over a static token.
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client, _ *options) {
+		c.tokenSource = ts
+	}
+}
+
+// WithAPIKeyHeader authenticates requests by sending key in the given
+// header, e.g. X-API-Key. It can be combined with a bearer token.
+func WithAPIKeyHeader(header, key string) Option {
+	return func(c *Client, _ *options) {
+		c.credentialHeader = header
+		c.credentialKey = key
+	}
+}
+
+// authorize attaches the client's credentials to req, or the elevated ones
+// when elevated is set and configured.
+func (c *Client) authorize(ctx context.Context, req *http.Request, elevated bool) error {
+	token := c.Token
+	ts := c.tokenSource
+	if elevated && c.elevatedTokenSource != nil {
+		ts = c.elevatedTokenSource
+	}
+	if ts != nil {
+		var err error
+		token, err = ts.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("get token: %w", err)
+		}
+	}
+
+	// Add the Authorization header with the Bearer token
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if c.credentialHeader != "" {
+		req.Header.Set(c.credentialHeader, c.credentialKey)
+	}
+
+	return nil
+}
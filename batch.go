@@ -0,0 +1,375 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultBatchConcurrency is the number of concurrent requests used when a
+// batch operation falls back to one request per item.
+const defaultBatchConcurrency = 8
+
+// WithBatchConcurrency sets how many requests CreateAPIKeys and
+// DeleteAPIKeys issue concurrently when the server has no bulk endpoint.
+func WithBatchConcurrency(n int) Option {
+	return func(c *Client, _ *options) {
+		c.batchConcurrency = n
+	}
+}
+
+// forEach calls fn for every index in [0, n) using at most concurrency
+// goroutines. It stops starting new calls once ctx is done and returns
+// ctx.Err() in that case.
+func forEach(ctx context.Context, n, concurrency int, fn func(i int)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		i := i
+		g.Go(func() error {
+			fn(i)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return ctx.Err()
+}
+
+// ValidateAPIKeys validates keys concurrently using at most concurrency
+// in-flight requests and returns whether each key is valid. A failure to
+// validate one key does not stop the others: failed keys are left out of the
+// result map and their errors are joined into the returned error. If ctx is
+// cancelled, no further validations are started and ctx.Err() is returned
+// together with the results gathered so far.
+func (c *Client) ValidateAPIKeys(ctx context.Context, keys []string, concurrency int) (map[string]bool, error) {
+	unique := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			unique = append(unique, key)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		results = make(map[string]bool, len(unique))
+		errs    []error
+	)
+
+	err := forEach(ctx, len(unique), concurrency, func(i int) {
+		valid, err := c.ValidateAPIKey(ctx, unique[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			// Identify the key by position so secrets never end up in errors.
+			errs = append(errs, fmt.Errorf("validate key %d: %w", i, err))
+			return
+		}
+		results[unique[i]] = valid
+	})
+	if err != nil {
+		return results, err
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// GetAPIKeyResult is the outcome of fetching one key in a batch. Exactly one
+// of Key and Err is set.
+type GetAPIKeyResult struct {
+	Key *APIKey
+	Err error
+}
+
+// GetAPIKeysByIDs fetches the keys with the given ids concurrently using at
+// most concurrency in-flight requests, and returns the outcome for each id.
+// Lookups go through GetAPIKeyByID, so the key cache applies, and a failure
+// to fetch one key does not stop the others. If ctx is cancelled, no further
+// lookups are started and ctx.Err() is returned together with the results
+// gathered so far; ids never looked up are left out of the map.
+func (c *Client) GetAPIKeysByIDs(ctx context.Context, ids []uuid.UUID, concurrency int) (map[uuid.UUID]GetAPIKeyResult, error) {
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			unique = append(unique, id)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		results = make(map[uuid.UUID]GetAPIKeyResult, len(unique))
+	)
+
+	err := forEach(ctx, len(unique), concurrency, func(i int) {
+		key, err := c.GetAPIKeyByID(ctx, unique[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+		results[unique[i]] = GetAPIKeyResult{Key: key, Err: err}
+	})
+
+	return results, err
+}
+
+// APIKeyRequest describes a key to create in a batch.
+type APIKeyRequest struct {
+	ServiceAccountID uuid.UUID  `json:"service_account_id"`
+	ServiceName      string     `json:"service_name"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	Scopes           []string   `json:"scopes,omitempty"`
+}
+
+// apiKey returns the APIKey sent to CreateAPIKey for r.
+func (r APIKeyRequest) apiKey() APIKey {
+	return APIKey{
+		ServiceAccountID: r.ServiceAccountID,
+		ServiceName:      r.ServiceName,
+		ExpiresAt:        r.ExpiresAt,
+		Scopes:           r.Scopes,
+		Status:           KeyActive,
+		IsActive:         true,
+		Valid:            true,
+	}
+}
+
+// CreateAPIKeyResult is the outcome of creating one key in a batch. Exactly
+// one of Key and Err is set.
+type CreateAPIKeyResult struct {
+	Key *APIKey
+	Err error
+}
+
+// DeleteAPIKeyResult is the outcome of deleting one key in a batch. Err is nil
+// if the key was deleted.
+type DeleteAPIKeyResult struct {
+	ID  uuid.UUID
+	Err error
+}
+
+// batchItemError is the per-item error returned by bulk endpoints.
+type batchItemError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *batchItemError) err() error {
+	if e == nil {
+		return nil
+	}
+	return &APIError{StatusCode: e.Status, Code: e.Code, Message: e.Message}
+}
+
+type createAPIKeysRequest struct {
+	Keys []APIKeyRequest `json:"keys"`
+}
+
+type createAPIKeysResponse struct {
+	Results []struct {
+		Key   *APIKey         `json:"key"`
+		Error *batchItemError `json:"error"`
+	} `json:"results"`
+}
+
+// CreateAPIKeys creates the keys described by reqs and returns one result per
+// request, in order. It uses the server's bulk endpoint and falls back to
+// concurrent single creations when the server lacks one. The returned error
+// is only set when the batch as a whole failed; per-key failures are
+// reported in the results.
+func (c *Client) CreateAPIKeys(ctx context.Context, reqs []APIKeyRequest) ([]CreateAPIKeyResult, error) {
+	var bulk createAPIKeysResponse
+	_, err := c.do(ctx, &request{
+		op:             "CreateAPIKeys",
+		method:         http.MethodPost,
+		url:            c.endpoint("apikeys", "batch"),
+		body:           createAPIKeysRequest{Keys: reqs},
+		in:             reqs,
+		idempotencyKey: c.idempotencyKey(ctx, "CreateAPIKeys", reqs),
+	}, &bulk, http.StatusOK, http.StatusCreated, http.StatusMultiStatus)
+	switch {
+	case err == nil:
+		if len(bulk.Results) != len(reqs) {
+			return nil, fmt.Errorf("bulk create returned %d results for %d keys", len(bulk.Results), len(reqs))
+		}
+
+		results := make([]CreateAPIKeyResult, len(reqs))
+		for i, r := range bulk.Results {
+			results[i] = CreateAPIKeyResult{Key: r.Key, Err: r.Error.err()}
+		}
+		return results, nil
+	case !isMissingEndpoint(err):
+		return nil, err
+	}
+
+	results := make([]CreateAPIKeyResult, len(reqs))
+	err = forEach(ctx, len(reqs), c.batchConcurrency, func(i int) {
+		key, err := c.createAPIKey(itemContext(ctx, i), reqs[i].apiKey())
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].Key = &key
+	})
+
+	return results, err
+}
+
+type deleteAPIKeysRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+type deleteAPIKeysResponse struct {
+	Results []struct {
+		ID    uuid.UUID       `json:"id"`
+		Error *batchItemError `json:"error"`
+	} `json:"results"`
+}
+
+// DeleteAPIKeys deletes the keys with the given ids and returns one result
+// per id, in order. Like CreateAPIKeys it prefers the bulk endpoint and falls
+// back to concurrent single deletions.
+func (c *Client) DeleteAPIKeys(ctx context.Context, ids []uuid.UUID) ([]DeleteAPIKeyResult, error) {
+	var bulk deleteAPIKeysResponse
+	_, err := c.do(ctx, &request{
+		op:             "DeleteAPIKeys",
+		method:         http.MethodPost,
+		url:            c.endpoint("apikeys", "batch", "delete"),
+		body:           deleteAPIKeysRequest{IDs: ids},
+		in:             ids,
+		idempotencyKey: c.idempotencyKey(ctx, "DeleteAPIKeys", ids),
+	}, &bulk, http.StatusOK, http.StatusMultiStatus)
+	switch {
+	case err == nil:
+		if len(bulk.Results) != len(ids) {
+			return nil, fmt.Errorf("bulk delete returned %d results for %d keys", len(bulk.Results), len(ids))
+		}
+
+		results := make([]DeleteAPIKeyResult, len(ids))
+		for i, r := range bulk.Results {
+			results[i] = DeleteAPIKeyResult{ID: ids[i], Err: r.Error.err()}
+			c.forgetKey(ctx, ids[i])
+		}
+		return results, nil
+	case !isMissingEndpoint(err):
+		return nil, err
+	}
+
+	results := make([]DeleteAPIKeyResult, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+	}
+	err = forEach(ctx, len(ids), c.batchConcurrency, func(i int) {
+		results[i].Err = c.DeleteAPIKey(itemContext(ctx, i), ids[i])
+	})
+
+	return results, err
+}
+
+// isMissingEndpoint reports whether err means the server or transport does
+// not implement the requested endpoint.
+func isMissingEndpoint(err error) bool {
+	if errors.Is(err, ErrUnsupportedOperation) {
+		return true
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed
+}
+
+// RotateAPIKeyResult is the outcome of rotating one key in a bulk rotation.
+// Exactly one of Rotation and Err is set.
+type RotateAPIKeyResult struct {
+	ID       uuid.UUID
+	Rotation *RotateAPIKeyResponse
+	Err      error
+}
+
+type rotateAPIKeysRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+type rotateAPIKeysResponse struct {
+	Results []struct {
+		ID       uuid.UUID             `json:"id"`
+		Rotation *RotateAPIKeyResponse `json:"rotation"`
+		Error    *batchItemError       `json:"error"`
+	} `json:"results"`
+}
+
+// RotateAPIKeys asks the server to rotate the keys with the given ids in the
+// background and returns the Operation doing it. Once the operation is
+// done, RotationResults reports the outcome per key. Unlike the other bulk
+// calls, there is no fallback for servers without the bulk endpoint.
+func (c *Client) RotateAPIKeys(ctx context.Context, ids []uuid.UUID) (*Operation, error) {
+	op := &Operation{c: c}
+	resp, err := c.do(ctx, &request{
+		op:             "RotateAPIKeys",
+		method:         http.MethodPost,
+		url:            c.endpoint("apikeys", "batch", "rotate"),
+		body:           rotateAPIKeysRequest{IDs: ids},
+		in:             ids,
+		idempotencyKey: c.idempotencyKey(ctx, "RotateAPIKeys", ids),
+		secret:         true,
+	}, op, http.StatusOK, http.StatusAccepted)
+	if err != nil && !errors.Is(err, errEmptyBody) {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusAccepted || errors.Is(err, errEmptyBody) {
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return nil, fmt.Errorf("bulk rotate: %d response without Location header", resp.StatusCode)
+		}
+		return c.operationAt(resp.Request.URL, location)
+	}
+
+	op.c = c
+	return op, nil
+}
+
+// RotationResults returns the per-key results of a succeeded RotateAPIKeys
+// operation, in the order of the rotated ids. Rotated keys are dropped from
+// the client's key cache.
+func (o *Operation) RotationResults() ([]RotateAPIKeyResult, error) {
+	if o.c == nil {
+		return nil, errUnboundOperation
+	}
+	var bulk rotateAPIKeysResponse
+	if err := o.DecodeResult(&bulk); err != nil {
+		return nil, err
+	}
+
+	results := make([]RotateAPIKeyResult, len(bulk.Results))
+	for i, r := range bulk.Results {
+		results[i] = RotateAPIKeyResult{ID: r.ID, Rotation: r.Rotation, Err: r.Error.err()}
+		if r.Rotation != nil {
+			o.c.prepareKeys(&request{secret: true}, r.Rotation)
+		}
+		o.c.forgetKey(context.Background(), r.ID)
+	}
+	return results, nil
+}
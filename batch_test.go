@@ -0,0 +1,223 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeysclienttest"
+)
+
+// batchServer serves the key endpoints used by CreateAPIKeys and
+// DeleteAPIKeys. Keys named or with ids in reject fail with 409 Conflict;
+// bulk endpoints answer 404 unless bulk is set.
+type batchServer struct {
+	bulk   bool
+	reject map[string]bool
+
+	bulkCalls, singleCalls atomic.Int32
+}
+
+func (s *batchServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	conflict := map[string]any{"status": http.StatusConflict, "code": "conflict", "message": "conflict"}
+
+	mux.HandleFunc("POST /apikeys/batch", func(w http.ResponseWriter, r *http.Request) {
+		if !s.bulk {
+			http.NotFound(w, r)
+			return
+		}
+		s.bulkCalls.Add(1)
+		var req struct {
+			Keys []apikeysclient.APIKeyRequest `json:"keys"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var results []map[string]any
+		for _, k := range req.Keys {
+			if s.reject[k.ServiceName] {
+				results = append(results, map[string]any{"error": conflict})
+				continue
+			}
+			results = append(results, map[string]any{"key": apikeysclient.APIKey{ID: uuid.New(), ServiceName: k.ServiceName}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+	})
+	mux.HandleFunc("POST /apikeys/batch/delete", func(w http.ResponseWriter, r *http.Request) {
+		if !s.bulk {
+			http.NotFound(w, r)
+			return
+		}
+		s.bulkCalls.Add(1)
+		var req struct {
+			IDs []uuid.UUID `json:"ids"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var results []map[string]any
+		for _, id := range req.IDs {
+			result := map[string]any{"id": id}
+			if s.reject[id.String()] {
+				result["error"] = conflict
+			}
+			results = append(results, result)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+	})
+	mux.HandleFunc("POST /apikeys", func(w http.ResponseWriter, r *http.Request) {
+		s.singleCalls.Add(1)
+		var key apikeysclient.APIKey
+		json.NewDecoder(r.Body).Decode(&key)
+		w.Header().Set("Content-Type", "application/json")
+		if s.reject[key.ServiceName] {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(conflict)
+			return
+		}
+		key.ID = uuid.New()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key)
+	})
+	mux.HandleFunc("DELETE /apikeys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		s.singleCalls.Add(1)
+		if s.reject[r.PathValue("id")] {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(conflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func TestCreateAPIKeys(t *testing.T) {
+	reqs := []apikeysclient.APIKeyRequest{
+		{ServiceAccountID: uuid.New(), ServiceName: "billing"},
+		{ServiceAccountID: uuid.New(), ServiceName: "taken"},
+		{ServiceAccountID: uuid.New(), ServiceName: "search"},
+	}
+
+	for _, bulk := range []bool{true, false} {
+		name := "fallback"
+		if bulk {
+			name = "bulk"
+		}
+		t.Run(name, func(t *testing.T) {
+			s := &batchServer{bulk: bulk, reject: map[string]bool{"taken": true}}
+			srv := httptest.NewServer(s.handler())
+			defer srv.Close()
+			client, err := apikeysclient.NewClient(srv.URL, apikeysclient.WithBatchConcurrency(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := client.CreateAPIKeys(context.Background(), reqs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(reqs) {
+				t.Fatalf("got %d results, want %d", len(results), len(reqs))
+			}
+			for i, res := range results {
+				if reqs[i].ServiceName == "taken" {
+					var apiErr *apikeysclient.APIError
+					if res.Key != nil || !errors.As(res.Err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+						t.Errorf("result %d = %+v, want a 409 error", i, res)
+					}
+					continue
+				}
+				if res.Err != nil || res.Key == nil || res.Key.ServiceName != reqs[i].ServiceName {
+					t.Errorf("result %d = %+v, want key for %s", i, res, reqs[i].ServiceName)
+				}
+			}
+
+			wantBulk, wantSingle := int32(0), int32(len(reqs))
+			if bulk {
+				wantBulk, wantSingle = 1, 0
+			}
+			if s.bulkCalls.Load() != wantBulk || s.singleCalls.Load() != wantSingle {
+				t.Errorf("got %d bulk and %d single calls, want %d and %d",
+					s.bulkCalls.Load(), s.singleCalls.Load(), wantBulk, wantSingle)
+			}
+		})
+	}
+}
+
+func TestDeleteAPIKeys(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	for _, bulk := range []bool{true, false} {
+		name := "fallback"
+		if bulk {
+			name = "bulk"
+		}
+		t.Run(name, func(t *testing.T) {
+			s := &batchServer{bulk: bulk, reject: map[string]bool{ids[1].String(): true}}
+			srv := httptest.NewServer(s.handler())
+			defer srv.Close()
+			client, err := apikeysclient.NewClient(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := client.DeleteAPIKeys(context.Background(), ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(ids) {
+				t.Fatalf("got %d results, want %d", len(results), len(ids))
+			}
+			for i, res := range results {
+				if res.ID != ids[i] {
+					t.Errorf("result %d is for %s, want %s", i, res.ID, ids[i])
+				}
+				if wantErr := i == 1; wantErr != (res.Err != nil) {
+					t.Errorf("result %d error = %v, want error %v", i, res.Err, wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeysMismatchedBulkResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer srv.Close()
+	client, err := apikeysclient.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.CreateAPIKeys(context.Background(), []apikeysclient.APIKeyRequest{{ServiceName: "billing"}}); err == nil {
+		t.Error("CreateAPIKeys accepted a bulk response without a result per key")
+	}
+}
+
+func TestValidateAPIKeys(t *testing.T) {
+	srv := apikeysclienttest.NewServer()
+	defer srv.Close()
+	valid := srv.Fake.SeedKey(uuid.New())
+	revoked := srv.Fake.Seed(apikeysclient.APIKey{ServiceAccountID: uuid.New(), Status: apikeysclient.KeyRevoked})[0]
+
+	results, err := srv.Client().ValidateAPIKeys(context.Background(),
+		[]string{valid.APIKey, revoked.APIKey, valid.APIKey}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{valid.APIKey: true, revoked.APIKey: false}
+	if len(results) != len(want) || results[valid.APIKey] != true || results[revoked.APIKey] != false {
+		t.Errorf("ValidateAPIKeys = %v, want %v", results, want)
+	}
+}
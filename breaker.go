@@ -0,0 +1,238 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the server while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerConfig configures the circuit breaker. Zero fields take the
+// documented defaults.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit. Defaults to 5.
+	FailureThreshold int
+
+	// OpenTimeout is how long the circuit stays open before trial requests
+	// are let through. Defaults to 30 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenMaxRequests is the number of concurrent trial requests allowed
+	// while half-open. Defaults to 1.
+	HalfOpenMaxRequests int
+}
+
+// WithCircuitBreaker makes calls fail fast with ErrCircuitOpen once the
+// server has failed repeatedly. Network errors, 5xx and 429 responses count
+// as failures; other responses count as successes.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return func(c *Client, _ *options) {
+		c.breaker = newCircuitBreaker(cfg)
+	}
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+// Circuit breaker states.
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trials   int
+
+	// onChange, if set, is called with the new state after every state
+	// change, without b.mu held.
+	onChange func(CircuitState)
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenMaxRequests <= 0 {
+		cfg.HalfOpenMaxRequests = 1
+	}
+	return &circuitBreaker{cfg: cfg}
+}
+
+// allow reports whether a request may be sent. Every allowed request must be
+// followed by a call to record.
+func (b *circuitBreaker) allow() bool {
+	defer b.lock()()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		b.state = CircuitHalfOpen
+		b.trials = 0
+	}
+
+	switch b.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.trials >= b.cfg.HalfOpenMaxRequests {
+			return false
+		}
+		b.trials++
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed request.
+func (b *circuitBreaker) record(success bool) {
+	defer b.lock()()
+
+	if success {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// lock acquires b.mu and returns the function releasing it, which reports
+// the state change made in between, if any, to onChange.
+func (b *circuitBreaker) lock() func() {
+	b.mu.Lock()
+	from := b.state
+	return func() {
+		to := b.state
+		b.mu.Unlock()
+		if to != from && b.onChange != nil {
+			b.onChange(to)
+		}
+	}
+}
+
+// release gives back a trial slot for an allowed request whose outcome says
+// nothing about the server, such as one cancelled by the caller.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen && b.trials > 0 {
+		b.trials--
+	}
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// CircuitState returns the state of the client's circuit breaker, which is
+// always CircuitClosed when no breaker is configured.
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.currentState()
+}
+
+// isServerFailure reports whether a response or error means the server is
+// unhealthy.
+func isServerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// FailurePolicy decides what ValidateAPIKey reports when the keys server
+// cannot be reached.
+type FailurePolicy int
+
+const (
+	// FailWithError returns the error to the caller. This is the default.
+	FailWithError FailurePolicy = iota
+
+	// FailOpen treats the key as valid.
+	FailOpen
+
+	// FailClosed treats the key as invalid.
+	FailClosed
+)
+
+// WithValidationFailurePolicy sets how ValidateAPIKey behaves when the server
+// is unavailable: the circuit is open, the request failed at the network
+// level, or the server answered 5xx or 429. Other errors are always
+// returned. It sets the Fallback of the client's DegradationPolicy.
+func WithValidationFailurePolicy(p FailurePolicy) Option {
+	return func(c *Client, _ *options) {
+		c.degradation.Fallback = p
+	}
+}
+
+// isUnavailable reports whether err means the server could not be reached
+// or could not answer: the circuit is open, the exchange failed at the
+// network level or timed out, or the server answered 5xx or 429. Other
+// errors, such as undecodable responses and failures to authorize the
+// request, mean the answer cannot be trusted and are never degraded.
+func isUnavailable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, errValidationTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// applyFailurePolicy resolves a failed validation according to p.
+func (c *Client) applyFailurePolicy(ctx context.Context, p FailurePolicy, err error) (bool, error) {
+	if !isUnavailable(err) {
+		return false, err
+	}
+
+	switch p {
+	case FailOpen:
+		c.logDegraded(ctx, "fail_open", err)
+		return true, nil
+	case FailClosed:
+		c.logDegraded(ctx, "fail_closed", err)
+		return false, nil
+	}
+	return false, err
+}
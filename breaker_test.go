@@ -0,0 +1,51 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestFailOpenOnlyWhenUnavailable(t *testing.T) {
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>captive portal</html>"))
+	}))
+	defer html.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		wantValid bool
+		wantErr   bool
+	}{
+		{"undecodable response", html.URL, false, true},
+		{"unreachable server", down.URL, true, false},
+		{"server unavailable", unavailable.URL, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := apikeysclient.NewClient(tt.url,
+				apikeysclient.WithValidationFailurePolicy(apikeysclient.FailOpen))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			valid, err := client.ValidateAPIKey(context.Background(), "not-a-key")
+			if valid != tt.wantValid || (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAPIKey = %v, %v; want valid %v, error %v", valid, err, tt.wantValid, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package apikeysclient
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Cache is a shared store for the validation and key caches, such as Redis
+// through package apikeysredis, so that replicas of a service see the same
+// cached results and a key invalidated by one of them, for instance when it
+// is revoked, is invalidated for all. Values are opaque to the cache.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key and whether there is one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value under key for ttl, or until deleted when ttl is
+	// zero.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the values stored under keys, if any.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// ValidationCacheConfig configures the in-memory cache of ValidateAPIKey
+// results.
+type ValidationCacheConfig struct {
+	// TTL is how long a valid result is served from the cache.
+	TTL time.Duration
+
+	// NegativeTTL is how long an invalid result is served from the cache.
+	// Invalid results are not cached when it is zero.
+	NegativeTTL time.Duration
+
+	// MaxEntries bounds the number of cached keys; the least recently used
+	// entry is evicted when it is exceeded. Zero means no limit.
+	MaxEntries int
+
+	// Cache stores the results instead of process memory when set, in which
+	// case MaxEntries does not apply. Cache errors count as misses and are
+	// logged to the client's WithLogger logger at warning level.
+	Cache Cache
+}
+
+// WithValidationCache caches ValidateAPIKey results according to cfg. Use
+// Client.Invalidate to drop a key, e.g. after revoking it.
+func WithValidationCache(cfg ValidationCacheConfig) Option {
+	return func(c *Client, _ *options) {
+		if cfg.Cache != nil {
+			c.validationCache = &sharedValidationCache{cfg: cfg, c: c}
+			return
+		}
+		c.validationCache = newValidationCache(cfg)
+	}
+}
+
+// validationStore holds the results of the validation cache.
+type validationStore interface {
+	// get returns the cached result for hash, if it has one that has not
+	// expired, or that expired at most maxStale ago, in which case stale
+	// is set. Results whose key has expired since are fresh and invalid.
+	get(ctx context.Context, hash string, maxStale time.Duration) (valid, stale, ok bool)
+
+	// set stores the result for hash, unless the configuration says
+	// results of that kind are not cached. keyExpiresAt is the key's
+	// expiry, if known.
+	set(ctx context.Context, hash string, valid bool, keyExpiresAt *time.Time)
+
+	delete(ctx context.Context, hash string)
+}
+
+// Invalidate removes apiKey from the validation cache and the key cache so
+// the next validation or lookup asks the server again.
+func (c *Client) Invalidate(apiKey string) {
+	c.invalidateHash(HashAPIKey(apiKey))
+}
+
+func (c *Client) invalidateHash(hash string) {
+	ctx := context.Background()
+	if c.validationCache != nil {
+		c.validationCache.delete(ctx, hash)
+	}
+	if c.keyCache != nil {
+		c.keyCache.delete(ctx, "hash:"+hash)
+	}
+}
+
+type validationEntry struct {
+	hash    string
+	valid   bool
+	expires time.Time
+
+	// keyExpiresAt is the key's own expiry, zero if unknown.
+	keyExpiresAt time.Time
+}
+
+// validationCache is an LRU cache of validation results with per-entry
+// expiry. It is safe for concurrent use.
+type validationCache struct {
+	cfg ValidationCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newValidationCache(cfg ValidationCacheConfig) *validationCache {
+	return &validationCache{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (vc *validationCache) get(_ context.Context, hash string, maxStale time.Duration) (valid, stale, ok bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	el, ok := vc.entries[hash]
+	if !ok {
+		return false, false, false
+	}
+
+	entry := el.Value.(*validationEntry)
+	now := time.Now()
+	if now.After(entry.expires.Add(maxStale)) {
+		vc.removeElement(el)
+		return false, false, false
+	}
+	vc.lru.MoveToFront(el)
+
+	if !entry.keyExpiresAt.IsZero() && !now.Before(entry.keyExpiresAt) {
+		return false, false, true
+	}
+
+	return entry.valid, now.After(entry.expires), true
+}
+
+func (vc *validationCache) set(_ context.Context, hash string, valid bool, keyExpiresAt *time.Time) {
+	ttl := vc.cfg.TTL
+	if !valid {
+		ttl = vc.cfg.NegativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	entry := &validationEntry{hash: hash, valid: valid, expires: time.Now().Add(ttl)}
+	if keyExpiresAt != nil {
+		entry.keyExpiresAt = *keyExpiresAt
+	}
+	if el, ok := vc.entries[hash]; ok {
+		el.Value = entry
+		vc.lru.MoveToFront(el)
+		return
+	}
+
+	vc.entries[hash] = vc.lru.PushFront(entry)
+	if vc.cfg.MaxEntries > 0 && vc.lru.Len() > vc.cfg.MaxEntries {
+		vc.removeElement(vc.lru.Back())
+	}
+}
+
+func (vc *validationCache) delete(_ context.Context, hash string) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if el, ok := vc.entries[hash]; ok {
+		vc.removeElement(el)
+	}
+}
+
+func (vc *validationCache) removeElement(el *list.Element) {
+	vc.lru.Remove(el)
+	delete(vc.entries, el.Value.(*validationEntry).hash)
+}
+
+// sharedValidationEntry is the form of a validation result in a Cache.
+type sharedValidationEntry struct {
+	Valid        bool       `json:"valid"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	KeyExpiresAt *time.Time `json:"key_expires_at,omitempty"`
+}
+
+// sharedValidationCache keeps validation results in a Cache, under
+// "validation:<key hash>". Entries are stored for their TTL and the
+// MaxStaleness of the DegradationPolicy of the validation.
+type sharedValidationCache struct {
+	cfg ValidationCacheConfig
+	c   *Client
+}
+
+func (vc *sharedValidationCache) get(ctx context.Context, hash string, maxStale time.Duration) (valid, stale, ok bool) {
+	b, ok, err := vc.cfg.Cache.Get(ctx, "validation:"+hash)
+	if err != nil {
+		vc.c.logCacheError(ctx, "get", err)
+		return false, false, false
+	}
+	if !ok {
+		return false, false, false
+	}
+
+	var entry sharedValidationEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		vc.c.logCacheError(ctx, "decode", err)
+		return false, false, false
+	}
+	now := time.Now()
+	if now.After(entry.ExpiresAt.Add(maxStale)) {
+		return false, false, false
+	}
+	if entry.KeyExpiresAt != nil && !now.Before(*entry.KeyExpiresAt) {
+		return false, false, true
+	}
+	return entry.Valid, now.After(entry.ExpiresAt), true
+}
+
+func (vc *sharedValidationCache) set(ctx context.Context, hash string, valid bool, keyExpiresAt *time.Time) {
+	ttl := vc.cfg.TTL
+	if !valid {
+		ttl = vc.cfg.NegativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	b, _ := json.Marshal(sharedValidationEntry{Valid: valid, ExpiresAt: time.Now().Add(ttl), KeyExpiresAt: keyExpiresAt})
+	if err := vc.cfg.Cache.Set(ctx, "validation:"+hash, b, ttl+vc.c.degradationPolicy(ctx).MaxStaleness); err != nil {
+		vc.c.logCacheError(ctx, "set", err)
+	}
+}
+
+func (vc *sharedValidationCache) delete(ctx context.Context, hash string) {
+	if err := vc.cfg.Cache.Delete(ctx, "validation:"+hash); err != nil {
+		vc.c.logCacheError(ctx, "delete", err)
+	}
+}
+
+// logCacheError logs the failure of a shared cache operation, which the
+// client otherwise treats as a cache miss.
+func (c *Client) logCacheError(ctx context.Context, op string, err error) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelWarn, "apikeys cache error",
+		slog.String("op", op),
+		slog.String("error", err.Error()),
+	)
+}
@@ -2,13 +2,23 @@ package apikeysclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
+	"github.com/PiccoloMondoC/apikeysclient/jwks"
 )
 
 // Client represents an HTTP client that can be used to send requests to the skills server.
@@ -16,277 +26,606 @@ type Client struct {
 	BaseURL    string
 	HttpClient *http.Client
 	Token      string
+
+	// UserAgent is sent as the User-Agent header when set.
+	UserAgent string
+
+	// Retry configures retries of failed requests. Requests are not retried
+	// when it is nil.
+	Retry *RetryPolicy
+
+	callTimeout    time.Duration
+	methodTimeouts map[string]time.Duration
+	acceptedStatus map[string][]int
+
+	validationCache  validationStore
+	batchConcurrency int
+
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+
+	codec  Codec
+	codecs map[string]Codec
+
+	telemetry *telemetry
+	observer  Observer
+	logger    *slog.Logger
+
+	tokenSource      TokenSource
+	credentialHeader string
+	credentialKey    string
+
+	breaker     *circuitBreaker
+	degradation DegradationPolicy
+
+	hashedKeys         bool
+	dryRun             bool
+	actor              string
+	headerAllowList    map[string]bool
+	noIdempotencyKeys  bool
+	capabilities       atomic.Pointer[Capabilities]
+	capabilitiesFailed atomic.Pointer[capabilitiesFailure]
+	keyCache           keyStore
+	redactSecrets      bool
+
+	elevatedTokenSource TokenSource
+	requestSigner       KeySigner
+
+	apiVersion      APIVersion
+	resolvedVersion atomic.Pointer[APIVersion]
+	versionRetryAt  atomic.Int64 // UnixNano
+
+	signedKeys    *SignedKeyConfig
+	tokenKeys     *jwks.Cache
+	revocations   atomic.Pointer[RevocationWatcher]
+	usageReporter atomic.Pointer[UsageReporter]
+
+	validateEndpoints atomic.Pointer[validateEndpoints]
+
+	transport Transport
+
+	limiter   *rate.Limiter
+	rateLimit atomic.Pointer[RateLimitState]
+
+	tenant     Tenant
+	tenantMode TenantMode
+
+	operationPollInterval time.Duration
+
+	// overQuota holds the QuotaUsage of keys last validated over quota, by
+	// hash.
+	overQuota sync.Map
 }
 
+// APIKey is a key as stored by the server. Its JSON form uses the
+// snake_case names of its db columns, like the other types of the API.
 type APIKey struct {
-	ID               uuid.UUID `db:"id"`
-	ServiceAccountID uuid.UUID `db:"service_account_id"`
-	APIKey           string    `db:"api_key"`
-	CreatedAt        time.Time `db:"created_at"`
-	UpdatedAt        time.Time `db:"updated_at"`
-	Valid            bool      `db:"valid"`
-	IsActive         bool      `db:"is_active"`
-	ServiceName      string    `db:"service_name"`
+	ID               uuid.UUID `db:"id" json:"id"`
+	ServiceAccountID uuid.UUID `db:"service_account_id" json:"service_account_id"`
+	APIKey           string    `db:"api_key" json:"api_key,omitempty"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at" json:"updated_at"`
+	ServiceName      string    `db:"service_name" json:"service_name,omitempty"`
+
+	// Status is the lifecycle state of the key. Servers that predate it
+	// leave it empty; read it with EffectiveStatus, which falls back to
+	// Valid and IsActive.
+	Status KeyStatus `db:"status" json:"status,omitempty"`
+
+	// Valid is false for revoked and deleted keys when the server sends a
+	// Status; see SetStatus.
+	//
+	// Deprecated: Use EffectiveStatus.
+	Valid bool `db:"valid" json:"valid"`
+
+	// IsActive is true for active keys only when the server sends a
+	// Status.
+	//
+	// Deprecated: Use EffectiveStatus.
+	IsActive bool `db:"is_active" json:"is_active"`
+
+	// KeyPrefix is the first KeyPrefixLength characters of the key material,
+	// enough to recognize a key without exposing it. It is filled in from
+	// the material when the server does not send it.
+	KeyPrefix string `db:"key_prefix" json:"key_prefix,omitempty"`
+
+	// Name and Description are free-form, human-readable details of the key.
+	Name        string `db:"name" json:"name,omitempty"`
+	Description string `db:"description" json:"description,omitempty"`
+
+	// Labels are key/value pairs used to organize keys and to select them
+	// in listings through ListAPIKeysOptions.Labels.
+	Labels map[string]string `db:"labels" json:"labels,omitempty"`
+
+	// ExpiresAt is when the key stops being valid. Keys without an expiry
+	// have a nil ExpiresAt.
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+
+	// Scopes lists the permissions granted to the key.
+	Scopes []string `db:"scopes" json:"scopes,omitempty"`
+
+	// Restrictions limit where the key may be used from, nil if it may be
+	// used from anywhere. Client.Middleware enforces them.
+	Restrictions *Restrictions `db:"restrictions" json:"restrictions,omitempty"`
+
+	// RateLimit and Quota bound how much the key may be used, nil if it is
+	// unbounded. The server enforces them; see Client.CheckQuota.
+	RateLimit *KeyRateLimit `db:"rate_limit" json:"rate_limit,omitempty"`
+	Quota     *Quota        `db:"quota" json:"quota,omitempty"`
+
+	// LastUsedAt is when the key last authenticated a request, nil if it
+	// never has or the server does not track usage.
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+
+	// KeyHash is the HashAPIKey digest of the key material, set by servers
+	// that store hashes instead of secrets.
+	KeyHash string `db:"key_hash" json:"key_hash,omitempty"`
+
+	// Version is the revision of the key, which the server increments on
+	// every change. It is zero from servers that do not track revisions.
+	// UpdateAPIKey and PatchAPIKey send it to only apply their change to
+	// the revision it was based on.
+	Version int64 `db:"version" json:"version,omitempty"`
+
+	// Location is the URL of the key as reported by the server's Location
+	// header on creation. It is not part of the stored model.
+	Location string `db:"-" json:"-"`
 }
 
-type ValidateResponse struct {
-	IsValid bool `json:"is_valid"`
+// IsExpired reports whether the key has an expiry that has passed.
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && !time.Now().Before(*k.ExpiresAt)
 }
 
-func NewClient(baseURL string, token string, httpClient ...*http.Client) *Client {
-	var client *http.Client
-	if len(httpClient) > 0 {
-		client = httpClient[0]
-	} else {
-		client = &http.Client{
-			Timeout: time.Second * 10,
-		}
-	}
+// legacyAPIKey holds the fields of APIKey whose Go names, as sent by servers
+// predating its json tags, do not match the tags.
+type legacyAPIKey struct {
+	ServiceAccountID     uuid.UUID
+	APIKey               string
+	CreatedAt, UpdatedAt time.Time
+	ServiceName          string
+	IsActive             bool
+	KeyPrefix            string
+	ExpiresAt            *time.Time
+	RateLimit            *KeyRateLimit
+	LastUsedAt           *time.Time
+	KeyHash              string
+}
 
-	return &Client{
-		BaseURL:    baseURL,
-		HttpClient: client,
-		Token:      token,
+// UnmarshalJSON implements json.Unmarshaler. It also accepts keys encoded
+// with Go field names, as sent by older servers and stored in shared caches
+// by older clients.
+func (k *APIKey) UnmarshalJSON(b []byte) error {
+	type plain APIKey
+	if err := json.Unmarshal(b, (*plain)(k)); err != nil {
+		return err
+	}
+	// Legacy encodings always carry a ServiceAccountID field, which leaves
+	// service_account_id unset, so only they pay for a second decoding.
+	if k.ServiceAccountID != uuid.Nil || !bytes.Contains(b, []byte(`"ServiceAccountID"`)) {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["ServiceAccountID"]; !ok {
+		return nil
 	}
-}
 
-func (c *Client) CreateAPIKey(apiKey APIKey) (APIKey, error) {
-	apiKeyJSON, err := json.Marshal(apiKey)
-	if err != nil {
-		return APIKey{}, err
+	var legacy legacyAPIKey
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
 	}
+	k.ServiceAccountID, k.APIKey, k.ServiceName = legacy.ServiceAccountID, legacy.APIKey, legacy.ServiceName
+	k.CreatedAt, k.UpdatedAt = legacy.CreatedAt, legacy.UpdatedAt
+	k.IsActive, k.KeyPrefix, k.KeyHash = legacy.IsActive, legacy.KeyPrefix, legacy.KeyHash
+	k.ExpiresAt, k.RateLimit, k.LastUsedAt = legacy.ExpiresAt, legacy.RateLimit, legacy.LastUsedAt
+	return nil
+}
 
-	url := fmt.Sprintf("%s/apikeys", c.BaseURL)
+type ValidateResponse struct {
+	IsValid bool `json:"is_valid"`
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(apiKeyJSON))
-	if err != nil {
-		return APIKey{}, err
-	}
+	// ExpiresAt is the key's expiry, when the server reports it. It lets
+	// cached results turn invalid once the key expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	// Quota is the quota usage of the key, when it has a quota and the
+	// server reports it.
+	Quota *QuotaUsage `json:"quota,omitempty"`
+}
 
-	resp, err := c.HttpClient.Do(req)
-	if err != nil {
-		return APIKey{}, err
+// ErrInvalidBaseURL is returned by NewClient for a base URL it cannot send
+// requests to.
+var ErrInvalidBaseURL = errors.New("invalid base URL")
+
+// NewClient returns a Client for the keys server at baseURL, configured by
+// opts. Without options it sends unauthenticated requests using an
+// http.Client with a 10 second timeout.
+//
+// baseURL must be an absolute http or https URL without query or fragment;
+// trailing slashes are removed. It may be empty when a Transport is
+// installed with WithTransport, or with WithUnixSocket, which then
+// defaults it to http://localhost. NewClient fails with ErrInvalidBaseURL
+// otherwise.
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	c := &Client{
+		HttpClient: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		batchConcurrency:      defaultBatchConcurrency,
+		operationPollInterval: DefaultOperationPollInterval,
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return APIKey{}, fmt.Errorf("create API key failed: %s", resp.Status)
+	var o options
+	for _, opt := range opts {
+		opt(c, &o)
+	}
+	if err := o.apply(c); err != nil {
+		return nil, err
 	}
+	c.tokenKeys = jwks.New("", jwks.WithFetcher(c.fetchTokenKeys))
 
-	var createdKey APIKey
-	err = json.NewDecoder(resp.Body).Decode(&createdKey)
-	if err != nil {
-		return APIKey{}, err
+	if baseURL == "" && o.unixSocket != "" {
+		baseURL = unixSocketBaseURL
 	}
+	if baseURL != "" || c.transport == nil {
+		u, err := normalizeBaseURL(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		c.BaseURL = u
+	}
+	c.validateURLs()
 
-	return createdKey, nil
+	return c, nil
 }
 
-func (c *Client) GetAPIKeyByID(id uuid.UUID) (*APIKey, error) {
-	// Create the URL for the request
-	endpoint := fmt.Sprintf("%s/apikeys/%s", c.BaseURL, url.PathEscape(id.String()))
+// normalizeBaseURL validates raw as a base URL and returns it without
+// trailing slashes.
+func normalizeBaseURL(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidBaseURL)
+	}
 
-	// Create the GET request
-	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	u, err := url.Parse(strings.TrimSpace(raw))
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("%w: %v", ErrInvalidBaseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%w %q: scheme must be http or https", ErrInvalidBaseURL, raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%w %q: missing host", ErrInvalidBaseURL, raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%w %q: query and fragment are not allowed", ErrInvalidBaseURL, raw)
 	}
 
-	// Add the Authorization header with the Bearer token
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return u.String(), nil
+}
 
-	// Send the request
-	res, err := c.HttpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
+// endpoint returns the URL of the API path made of elems below BaseURL.
+// Each element is escaped as a single path segment, so key material and
+// other caller-supplied values cannot alter the path.
+func (c *Client) endpoint(elems ...string) string {
+	return c.tenantEndpoint(Tenant{}, elems...)
+}
 
-	// Check for a successful status code
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error: received status code %d", res.StatusCode)
+// joinEndpoint returns the URL of the path made of elems under BaseURL,
+// escaping each of them.
+func (c *Client) joinEndpoint(elems ...string) string {
+	escaped := make([]string, len(elems))
+	for i, elem := range elems {
+		escaped[i] = url.PathEscape(elem)
 	}
 
-	// Decode the response body into an APIKey struct
-	var key APIKey
-	err = json.NewDecoder(res.Body).Decode(&key)
+	u, err := url.JoinPath(c.BaseURL, escaped...)
 	if err != nil {
-		return nil, err
+		// BaseURL was set to an invalid URL after NewClient; sending the
+		// request reports it.
+		return c.BaseURL + "/" + strings.Join(escaped, "/")
 	}
+	return u
+}
 
-	// Return the APIKey
-	return &key, nil
+// CreateAPIKey creates a new API key. Servers may answer with 201 and the
+// created key in the body, or with 200/201, an empty body and a Location
+// header, in which case the key is fetched from that location. Servers
+// creating keys asynchronously answer with 202 and the Location of an
+// Operation, which is polled until the key is created. Locations on another
+// scheme or host than the base URL fail with ErrForeignLocation. The
+// returned key's Location field holds the URL of the key when the server
+// sent one.
+//
+// With WithHashedKeys, key material set in apiKey is replaced by its KeyHash
+// before sending and restored in the returned key.
+//
+// Deprecated: Use CreateKey, which does not take the server-managed fields
+// of APIKey.
+func (c *Client) CreateAPIKey(ctx context.Context, apiKey APIKey) (APIKey, error) {
+	return c.createAPIKey(ctx, apiKey)
 }
 
-func (c *Client) GetAPIKeyByAPIKey(apiKey string) (*APIKey, error) {
-	url := fmt.Sprintf("%s/apikeys/key/%s", c.BaseURL, apiKey)
+// createAPIKey is CreateAPIKey.
+func (c *Client) createAPIKey(ctx context.Context, apiKey APIKey) (APIKey, error) {
+	body := apiKey
+	if c.hashedKeys && body.APIKey != "" {
+		body.KeyHash = HashAPIKey(body.APIKey)
+		body.APIKey = ""
+	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
+	var createdKey APIKey
+	resp, err := c.do(ctx, &request{
+		op:             "CreateAPIKey",
+		method:         http.MethodPost,
+		url:            c.endpoint("apikeys"),
+		body:           body,
+		in:             body,
+		idempotencyKey: c.idempotencyKey(ctx, "CreateAPIKey", body),
+		secret:         true,
+	}, &createdKey, http.StatusCreated, http.StatusOK, http.StatusAccepted)
+	if err != nil && !errors.Is(err, errEmptyBody) {
+		return APIKey{}, fmt.Errorf("create API key failed: %w", err)
 	}
 
-	// Add the Authorization header with the Bearer token
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	location := resp.Header.Get("Location")
 
-	resp, err := c.HttpClient.Do(req)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode == http.StatusAccepted {
+		if location == "" {
+			return APIKey{}, fmt.Errorf("create API key failed: accepted without Location header")
+		}
+		op, opErr := c.awaitOperation(ctx, resp.Request.URL, location)
+		if opErr != nil {
+			return APIKey{}, fmt.Errorf("create API key failed: %w", opErr)
+		}
+		if op.Resource == "" {
+			return APIKey{}, fmt.Errorf("create API key failed: operation %s has no resource", op.ID)
+		}
+		location = op.Resource
+		err = errEmptyBody
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status code %d", resp.StatusCode)
+	if errors.Is(err, errEmptyBody) {
+		if location == "" {
+			return APIKey{}, fmt.Errorf("create API key failed: empty response without Location header")
+		}
+
+		createdKey, err = c.getAPIKeyByLocation(ctx, resp.Request.URL, location)
+		if err != nil {
+			return APIKey{}, fmt.Errorf("fetch created API key: %w", err)
+		}
 	}
 
-	var key APIKey
-	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
-		return nil, err
+	createdKey.Location = location
+	if createdKey.APIKey == "" {
+		createdKey.APIKey = apiKey.APIKey
 	}
 
-	return &key, nil
+	return createdKey, nil
 }
 
-func (c *Client) UpdateAPIKey(key *APIKey) (*APIKey, error) {
-	// 1. Serialize the updated APIKey into JSON
-	body, err := json.Marshal(key)
+// ErrForeignLocation is returned for Location headers and operation
+// resources outside the scheme and host of the base URL. They are not
+// followed, so the client's credentials are never sent to another host.
+var ErrForeignLocation = errors.New("location outside the keys server")
+
+// resolveLocation returns the URL of location, which may be absolute or
+// relative to base. It fails with ErrForeignLocation for locations on
+// another scheme or host than BaseURL.
+func (c *Client) resolveLocation(base *url.URL, location string) (string, error) {
+	ref, err := url.Parse(location)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("parse Location header: %w", err)
 	}
+	u := base.ResolveReference(ref)
 
-	// 2. Construct the URL for the request
-	url := fmt.Sprintf("%s/apikeys/%s", c.BaseURL, key.ID)
+	origin := base
+	if b, err := url.Parse(c.BaseURL); err == nil && b.Host != "" {
+		origin = b
+	}
+	if !strings.EqualFold(u.Scheme, origin.Scheme) || !strings.EqualFold(u.Host, origin.Host) {
+		return "", fmt.Errorf("%w: %s", ErrForeignLocation, u.Redacted())
+	}
+	return u.String(), nil
+}
 
-	// 3. Create a new HTTP PUT request
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(body))
+// getAPIKeyByLocation fetches the API key found at location, which may be
+// absolute or relative to base.
+func (c *Client) getAPIKeyByLocation(ctx context.Context, base *url.URL, location string) (APIKey, error) {
+	u, err := c.resolveLocation(base, location)
 	if err != nil {
-		return nil, err
+		return APIKey{}, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	// Add the Authorization header with the Bearer token
-	req.Header.Set("Authorization", "Bearer "+c.Token)
-
-	// 4. Send the request using the HTTP client
-	resp, err := c.HttpClient.Do(req)
+	var key APIKey
+	_, err = c.do(ctx, &request{
+		op:     "CreateAPIKey",
+		method: http.MethodGet,
+		url:    u,
+		secret: true,
+	}, &key)
 	if err != nil {
-		return nil, err
+		return APIKey{}, err
 	}
-	defer resp.Body.Close()
 
-	// 5. Read the response
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status code %d", resp.StatusCode)
-	}
+	return key, nil
+}
+
+// GetAPIKeyByID retrieves the APIKey with the given id.
+func (c *Client) GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	return c.getKey(ctx, "id:"+id.String(), &request{
+		op:     "GetAPIKeyByID",
+		keyID:  id,
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys", id.String()),
+		in:     id,
+	})
+}
+
+// GetAPIKeyByAPIKey retrieves the APIKey record for the given key material.
+// The key is sent in a request body instead of the URL when the server
+// advertises CapabilityBodyLookup, and only its hash is sent with
+// WithHashedKeys.
+func (c *Client) GetAPIKeyByAPIKey(ctx context.Context, apiKey string) (*APIKey, error) {
+	return c.getKey(ctx, "hash:"+HashAPIKey(apiKey), c.lookupRequest(ctx, apiKey))
+}
 
+// UpdateAPIKey replaces the stored APIKey with key and returns the result.
+// When key.Version is set, as in keys read from the server, the update is
+// sent with If-Match and fails with an error matching ErrConflict if the key
+// has changed since that version; read it again and retry. Keys with a zero
+// Version overwrite the stored key whatever its version.
+func (c *Client) UpdateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error) {
 	var updatedKey APIKey
-	if err := json.NewDecoder(resp.Body).Decode(&updatedKey); err != nil {
+	_, err := c.do(ctx, &request{
+		op:      "UpdateAPIKey",
+		keyID:   key.ID,
+		method:  http.MethodPut,
+		url:     c.endpoint("apikeys", key.ID.String()),
+		body:    key,
+		in:      key,
+		ifMatch: versionTag(key.Version),
+	}, &updatedKey)
+	if err != nil {
 		return nil, err
 	}
 
 	return &updatedKey, nil
 }
 
-// DeleteAPIKey deletes the APIKey with the given id.
-func (c *Client) DeleteAPIKey(id uuid.UUID) error {
-	// Create the URL for the DELETE request
-	url := fmt.Sprintf("%s/apikeys/%s", c.BaseURL, id)
-
-	// Create the DELETE request
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		return fmt.Errorf("create DELETE request: %w", err)
+// versionTag returns the entity tag of the key version v sent in If-Match
+// headers, empty for a zero version.
+func versionTag(v int64) string {
+	if v == 0 {
+		return ""
 	}
+	return `"` + strconv.FormatInt(v, 10) + `"`
+}
 
-	// Add the Authorization header with the Bearer token
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+// DeleteAPIKey soft-deletes the APIKey with the given id: the key stops
+// validating and leaves listings but can still be retrieved, with status
+// KeyDeleted, until removed by PurgeAPIKey. Servers may answer with
+// 200, 204, or 202 and the Location of an Operation, which is polled until
+// the key is deleted. A 404 answering a retry is taken as the deletion of an
+// earlier attempt whose response was lost.
+//
+// The server returns no key to invalidate, so the key's validation results
+// are dropped from the validation cache only when its hash is known from a
+// record in the key cache, as the middleware's lookups leave them.
+func (c *Client) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	return c.removeAPIKey(ctx, "DeleteAPIKey", id, nil)
+}
 
-	// Send the request
-	resp, err := c.HttpClient.Do(req)
+// removeAPIKey sends the DELETE request of op for the key with the given id,
+// as described by DeleteAPIKey.
+func (c *Client) removeAPIKey(ctx context.Context, op string, id uuid.UUID, query url.Values) error {
+	r := &request{
+		op:             op,
+		keyID:          id,
+		method:         http.MethodDelete,
+		url:            c.endpoint("apikeys", id.String()),
+		query:          query,
+		in:             id,
+		idempotencyKey: c.idempotencyKey(ctx, op, id),
+	}
+	resp, err := c.do(ctx, r, nil, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+	if errors.Is(err, ErrNotFound) && r.attempts > 1 {
+		// An earlier attempt deleted the key but its response was lost.
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("send DELETE request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	if location := resp.Header.Get("Location"); resp.StatusCode == http.StatusAccepted && location != "" {
+		if _, err := c.awaitOperation(ctx, resp.Request.URL, location); err != nil {
+			return fmt.Errorf("delete API key failed: %w", err)
+		}
 	}
-
 	return nil
 }
 
 // ListAPIKeys retrieves all API keys.
-func (c *Client) ListAPIKeys() ([]APIKey, error) {
-	// Create the URL for the GET request
-	url := fmt.Sprintf("%s/apikeys", c.BaseURL)
-
-	// Create the GET request
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var apiKeys []APIKey
+	_, err := c.do(ctx, &request{
+		op:     "ListAPIKeys",
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys"),
+	}, &apiKeys)
 	if err != nil {
-		return nil, fmt.Errorf("create GET request: %w", err)
+		return nil, err
 	}
 
-	// Add the Authorization header with the Bearer token
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	return apiKeys, nil
+}
 
-	// Send the request
-	resp, err := c.HttpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("send GET request: %w", err)
+// ValidateAPIKey validates an API key. Results are served from the
+// validation cache when one is configured, and cached keys whose expiry has
+// passed are reported invalid without asking the server. When the server is
+// unavailable the outcome follows the DegradationPolicy. Signed keys
+// are verified locally when configured with WithSignedKeys, and keys seen
+// revoked by an attached RevocationWatcher are invalid. Like
+// GetAPIKeyByAPIKey, it keeps the key out of the URL when the server
+// supports it.
+func (c *Client) ValidateAPIKey(ctx context.Context, apikey string) (bool, error) {
+	valid, err := c.validateAPIKey(ctx, apikey)
+	if c.observer != nil {
+		c.observer.ObserveValidation(validationOutcome(valid, err))
 	}
-	defer resp.Body.Close()
+	return valid, err
+}
 
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+func (c *Client) validateAPIKey(ctx context.Context, apikey string) (bool, error) {
+	if valid, handled := c.validateSignedKey(apikey); handled {
+		return valid, nil
 	}
 
-	// Decode the response body into a slice of APIKey
-	var apiKeys []APIKey
-	err = json.NewDecoder(resp.Body).Decode(&apiKeys)
-	if err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	hash := HashAPIKey(apikey)
+	if w := c.revocations.Load(); w != nil && w.isHashRevoked(hash) {
+		return false, nil
 	}
 
-	return apiKeys, nil
-}
-
-// ValidateAPIKey validates an API key.
-func (c *Client) ValidateAPIKey(apikey string) (bool, error) {
-	// Create the URL for the GET request
-	url := fmt.Sprintf("%s/apikeys/key/%s/validate", c.BaseURL, apikey)
-
-	// Create the GET request
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return false, fmt.Errorf("create GET request: %w", err)
+	policy := c.degradationPolicy(ctx)
+	var stale, staleValid bool
+	if c.validationCache != nil {
+		valid, isStale, ok := c.validationCache.get(ctx, hash, policy.MaxStaleness)
+		c.observeCache(CacheValidation, ok && !isStale)
+		if ok && !isStale {
+			return valid, nil
+		}
+		stale, staleValid = ok, valid
 	}
 
-	// Add the Authorization header with the Bearer token
-	req.Header.Set("Authorization", "Bearer "+c.Token)
-
-	// Send the request
-	resp, err := c.HttpClient.Do(req)
+	degrade := stale || policy.Fallback != FailWithError
+	validation, err := c.validateWithin(ctx, apikey, hash, policy, degrade)
 	if err != nil {
-		return false, fmt.Errorf("send GET request: %w", err)
+		if stale && isUnavailable(err) {
+			c.logDegraded(ctx, "stale", err)
+			return staleValid, nil
+		}
+		return c.applyFailurePolicy(ctx, policy.Fallback, err)
 	}
-	defer resp.Body.Close()
 
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("unexpected status code %d", resp.StatusCode)
-	}
+	return validation.IsValid, nil
+}
 
-	// Decode the response body into a ValidateResponse
+// validate asks the server whether a key is valid by sending r. Keys the
+// server reports valid but whose expiry has passed are invalid.
+func (c *Client) validate(ctx context.Context, r *request) (ValidateResponse, error) {
 	var validation ValidateResponse
-	err = json.NewDecoder(resp.Body).Decode(&validation)
+	_, err := c.do(ctx, r, &validation)
 	if err != nil {
-		return false, fmt.Errorf("decode response: %w", err)
+		return ValidateResponse{}, err
 	}
 
-	return validation.IsValid, nil
+	if validation.IsValid && validation.ExpiresAt != nil && !time.Now().Before(*validation.ExpiresAt) {
+		validation.IsValid = false
+	}
+
+	return validation, nil
 }
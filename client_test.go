@@ -0,0 +1,270 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestCreateAPIKeyResponses(t *testing.T) {
+	id := uuid.New()
+	created := apikeysclient.APIKey{ID: id, Name: "created", APIKey: "ak_test_created"}
+
+	var foreignHits atomic.Int32
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignHits.Add(1)
+		json.NewEncoder(w).Encode(created)
+	}))
+	defer foreign.Close()
+
+	tests := []struct {
+		name string
+		// respond answers POST /apikeys; srvURL is the URL of the server.
+		respond      func(w http.ResponseWriter, srvURL string)
+		wantErr      error
+		wantLocation string
+	}{
+		{
+			name: "inline body",
+			respond: func(w http.ResponseWriter, _ string) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(created)
+			},
+		},
+		{
+			name: "empty body with relative Location",
+			respond: func(w http.ResponseWriter, _ string) {
+				w.Header().Set("Location", "/apikeys/"+id.String())
+				w.WriteHeader(http.StatusCreated)
+			},
+			wantLocation: "/apikeys/" + id.String(),
+		},
+		{
+			name: "200 with empty body and Location",
+			respond: func(w http.ResponseWriter, _ string) {
+				w.Header().Set("Location", "/apikeys/"+id.String())
+				w.WriteHeader(http.StatusOK)
+			},
+			wantLocation: "/apikeys/" + id.String(),
+		},
+		{
+			name: "202 with operation",
+			respond: func(w http.ResponseWriter, _ string) {
+				w.Header().Set("Location", "/operations/1")
+				w.WriteHeader(http.StatusAccepted)
+			},
+			wantLocation: "/apikeys/" + id.String(),
+		},
+		{
+			name: "empty body with absolute Location",
+			respond: func(w http.ResponseWriter, srvURL string) {
+				w.Header().Set("Location", srvURL+"/apikeys/"+id.String())
+				w.WriteHeader(http.StatusCreated)
+			},
+			wantLocation: "/apikeys/" + id.String(),
+		},
+		{
+			name: "Location on another host",
+			respond: func(w http.ResponseWriter, _ string) {
+				w.Header().Set("Location", foreign.URL+"/apikeys/"+id.String())
+				w.WriteHeader(http.StatusCreated)
+			},
+			wantErr: apikeysclient.ErrForeignLocation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/apikeys":
+					tt.respond(w, srv.URL)
+				case r.Method == http.MethodGet && r.URL.Path == "/apikeys/"+id.String():
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(created)
+				case r.Method == http.MethodGet && r.URL.Path == "/operations/1":
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(apikeysclient.Operation{
+						ID:       "1",
+						Status:   apikeysclient.OperationSucceeded,
+						Resource: "/apikeys/" + id.String(),
+					})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			client, err := apikeysclient.NewClient(srv.URL, apikeysclient.WithBearerToken("secret-token"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := client.CreateAPIKey(context.Background(), apikeysclient.APIKey{Name: "created"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateAPIKey error = %v, want %v", err, tt.wantErr)
+				}
+				if n := foreignHits.Load(); n != 0 {
+					t.Errorf("foreign Location was requested %d times", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.ID != id || key.APIKey != created.APIKey {
+				t.Errorf("CreateAPIKey = %+v, want ID %s with its material", key, id)
+			}
+			if tt.wantLocation != "" && key.Location != tt.wantLocation && key.Location != srv.URL+tt.wantLocation {
+				t.Errorf("Location = %q, want %q", key.Location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+// validResponder answers every request with a valid validation, without a
+// network round trip, so benchmarks measure the client alone.
+type validResponder struct{}
+
+func (validResponder) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"is_valid":true}`)),
+		Request:    r,
+	}, nil
+}
+
+func BenchmarkValidateAPIKey(b *testing.B) {
+	const key = "ak_live_0123456789abcdef0123456789abcdef"
+	benchmarks := []struct {
+		name string
+		opts []apikeysclient.Option
+	}{
+		{"cached", []apikeysclient.Option{apikeysclient.WithValidationCache(apikeysclient.ValidationCacheConfig{TTL: time.Hour})}},
+		{"uncached", nil},
+		{"uncached hashed", []apikeysclient.Option{apikeysclient.WithHashedKeys()}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			opts := append([]apikeysclient.Option{apikeysclient.WithHTTPClient(&http.Client{Transport: validResponder{}})}, bm.opts...)
+			client, err := apikeysclient.NewClient("http://keys.invalid/v1", opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx := context.Background()
+			if valid, err := client.ValidateAPIKey(ctx, key); !valid || err != nil {
+				b.Fatalf("ValidateAPIKey = %v, %v", valid, err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := client.ValidateAPIKey(ctx, key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyJSON(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	key := apikeysclient.APIKey{
+		ID:               uuid.New(),
+		ServiceAccountID: uuid.New(),
+		APIKey:           "ak_test_material",
+		CreatedAt:        created,
+		UpdatedAt:        created,
+		ServiceName:      "billing",
+		Status:           apikeysclient.KeyActive,
+		Valid:            true,
+		IsActive:         true,
+		KeyPrefix:        "ak_test_",
+		ExpiresAt:        &created,
+		RateLimit:        &apikeysclient.KeyRateLimit{RequestsPerSecond: 1},
+		LastUsedAt:       &created,
+		KeyHash:          "hash",
+		Labels:           map[string]string{"ServiceAccountID": "label"},
+		Location:         "/apikeys/1",
+	}
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"id", "service_account_id", "api_key", "created_at", "is_active", "key_prefix", "expires_at", "last_used_at"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("encoding %s has no %q field", b, name)
+		}
+	}
+	if _, ok := fields["Location"]; ok {
+		t.Errorf("encoding %s has the Location of the response", b)
+	}
+
+	want := key
+	want.Location = ""
+	var decoded apikeysclient.APIKey
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("round trip = %+v, want %+v", decoded, want)
+	}
+
+	// Older servers send Go field names.
+	type untagged struct {
+		ID                   uuid.UUID
+		ServiceAccountID     uuid.UUID
+		APIKey               string
+		CreatedAt, UpdatedAt time.Time
+		ServiceName          string
+		Status               apikeysclient.KeyStatus
+		Valid, IsActive      bool
+		KeyPrefix            string
+		Labels               map[string]string
+		ExpiresAt            *time.Time
+		RateLimit            *apikeysclient.KeyRateLimit
+		LastUsedAt           *time.Time
+		KeyHash              string
+	}
+	old := untagged{
+		ID: key.ID, ServiceAccountID: key.ServiceAccountID, APIKey: key.APIKey,
+		CreatedAt: created, UpdatedAt: created, ServiceName: key.ServiceName,
+		Status: key.Status, Valid: true, IsActive: true, KeyPrefix: key.KeyPrefix,
+		Labels: key.Labels, ExpiresAt: &created, RateLimit: key.RateLimit,
+		LastUsedAt: &created, KeyHash: key.KeyHash,
+	}
+	if b, err = json.Marshal(old); err != nil {
+		t.Fatal(err)
+	}
+	decoded = apikeysclient.APIKey{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decoding legacy %s = %+v, want %+v", b, decoded, want)
+	}
+}
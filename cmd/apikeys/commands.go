@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func newCreateCmd(c *cli) *cobra.Command {
+	var (
+		serviceAccount string
+		serviceName    string
+		name           string
+		description    string
+		labels         map[string]string
+		scopes         []string
+		expiresIn      time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key",
+		Long:  "Create an API key. The key material is only shown once, in the output of this command.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serviceAccountID, err := uuid.Parse(serviceAccount)
+			if err != nil {
+				return fmt.Errorf("invalid --service-account: %w", err)
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			req := apikeysclient.CreateAPIKeyRequest{
+				ServiceAccountID: serviceAccountID,
+				ServiceName:      serviceName,
+				Name:             name,
+				Description:      description,
+				Labels:           labels,
+				Scopes:           scopes,
+			}
+			if expiresIn > 0 {
+				expiresAt := time.Now().Add(expiresIn).UTC()
+				req.ExpiresAt = &expiresAt
+			}
+
+			created, err := client.CreateKey(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+
+			key := created.Key
+			key.APIKey = created.Secret
+			return c.printKey(cmd, key, true)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&serviceAccount, "service-account", "", "ID of the service account owning the key (required)")
+	flags.StringVar(&serviceName, "service-name", "", "name of the service using the key")
+	flags.StringVar(&name, "name", "", "human-readable name of the key")
+	flags.StringVar(&description, "description", "", "description of the key")
+	flags.StringToStringVar(&labels, "label", nil, "label of the key as key=value; repeatable")
+	flags.StringSliceVar(&scopes, "scope", nil, "scope granted to the key; repeatable")
+	flags.DurationVar(&expiresIn, "expires-in", 0, "lifetime of the key, e.g. 720h; no expiry when unset")
+	_ = cmd.MarkFlagRequired("service-account")
+
+	return cmd
+}
+
+func newGetCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <id>",
+		Short: "Show an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			key, err := client.GetAPIKeyByID(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			return c.printKey(cmd, *key, false)
+		},
+	}
+}
+
+func newListCmd(c *cli) *cobra.Command {
+	var (
+		serviceAccount string
+		activeOnly     bool
+		selector       string
+		perPage        int
+		statuses       []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := &apikeysclient.ListAPIKeysOptions{PerPage: perPage}
+			if serviceAccount != "" {
+				id, err := uuid.Parse(serviceAccount)
+				if err != nil {
+					return fmt.Errorf("invalid --service-account: %w", err)
+				}
+				opts.ServiceAccountID = id
+			}
+			if activeOnly {
+				opts.IsActive = &activeOnly
+			}
+			for _, s := range statuses {
+				opts.Status = append(opts.Status, apikeysclient.KeyStatus(s))
+			}
+			labels, err := apikeysclient.ParseLabelSelector(selector)
+			if err != nil {
+				return err
+			}
+			opts.Labels = labels
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			var keys []apikeysclient.APIKey
+			it := client.ListAPIKeysIter(opts)
+			for it.Next(cmd.Context()) {
+				keys = append(keys, it.APIKey())
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+
+			return c.printKeys(cmd, keys)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&serviceAccount, "service-account", "", "only list keys of this service account")
+	flags.BoolVar(&activeOnly, "active", false, "only list active keys")
+	flags.StringSliceVar(&statuses, "status", nil, "only list keys in these statuses, e.g. suspended,deleted")
+	flags.StringVarP(&selector, "selector", "l", "", "only list keys matching the label selector, e.g. env=prod,team=payments")
+	flags.IntVar(&perPage, "per-page", 100, "number of keys fetched per request")
+
+	return cmd
+}
+
+func newSearchCmd(c *cli) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search API keys",
+		Long:  "Search API keys by free text over their name and description, and by filters such as status:active, label:team=infra and created:>2024-01-01. The most relevant keys are listed first.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			results, err := client.SearchAPIKeys(cmd.Context(), args[0], &apikeysclient.SearchOptions{PerPage: limit})
+			if err != nil {
+				return err
+			}
+
+			keys := make([]apikeysclient.APIKey, len(results.Results))
+			for i, r := range results.Results {
+				keys[i] = r.Key
+			}
+			return c.printKeys(cmd, keys)
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 50, "maximum number of keys listed")
+
+	return cmd
+}
+
+func newUpdateCmd(c *cli) *cobra.Command {
+	var (
+		name         string
+		description  string
+		serviceName  string
+		labels       map[string]string
+		removeLabels []string
+		scopes       []string
+		expiresIn    time.Duration
+		noExpiry     bool
+		ifVersion    int64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update <id>",
+		Short: "Change some fields of an API key",
+		Long:  "Change the fields of an API key given by flags, leaving the others as they are.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			flags := cmd.Flags()
+			patch := apikeysclient.APIKeyPatch{
+				Labels:       labels,
+				RemoveLabels: removeLabels,
+				ClearExpiry:  noExpiry,
+				Version:      ifVersion,
+			}
+			if flags.Changed("name") {
+				patch.Name = &name
+			}
+			if flags.Changed("description") {
+				patch.Description = &description
+			}
+			if flags.Changed("service-name") {
+				patch.ServiceName = &serviceName
+			}
+			if flags.Changed("scope") {
+				patch.Scopes = append([]string{}, scopes...)
+			}
+			if expiresIn > 0 {
+				expiresAt := time.Now().Add(expiresIn).UTC()
+				patch.ExpiresAt = &expiresAt
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			key, err := client.PatchAPIKey(cmd.Context(), id, patch)
+			if err != nil {
+				return err
+			}
+
+			return c.printKey(cmd, *key, false)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&name, "name", "", "human-readable name of the key")
+	flags.StringVar(&description, "description", "", "description of the key")
+	flags.StringVar(&serviceName, "service-name", "", "name of the service using the key")
+	flags.StringToStringVar(&labels, "label", nil, "label to set as key=value; repeatable")
+	flags.StringSliceVar(&removeLabels, "remove-label", nil, "key of a label to remove; repeatable")
+	flags.StringSliceVar(&scopes, "scope", nil, "scope granted to the key, replacing the current ones; repeatable")
+	flags.DurationVar(&expiresIn, "expires-in", 0, "new lifetime of the key from now, e.g. 720h")
+	flags.BoolVar(&noExpiry, "no-expiry", false, "remove the expiry of the key")
+	flags.Int64Var(&ifVersion, "if-version", 0, "only update the key if it is still at this version")
+	cmd.MarkFlagsMutuallyExclusive("expires-in", "no-expiry")
+
+	return cmd
+}
+
+func newRotateCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate <id>",
+		Short: "Replace an API key with a new one",
+		Long:  "Replace an API key with a new one. The old key keeps working until the grace period ends.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			rotated, err := client.RotateAPIKey(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			if c.output == outputJSON {
+				return printJSON(cmd.OutOrStdout(), rotated)
+			}
+			if err := c.printKey(cmd, rotated.NewKey, true); err != nil {
+				return err
+			}
+			if !rotated.GracePeriodEndsAt.IsZero() {
+				fmt.Fprintf(cmd.OutOrStdout(), "\nOld key %s works until %s.\n", rotated.OldKey.ID, rotated.GracePeriodEndsAt.Format(time.RFC3339))
+			}
+			return nil
+		},
+	}
+}
+
+func newRevokeCmd(c *cli) *cobra.Command {
+	return newKeyStateCmd(c, "revoke", "Permanently deactivate an API key", (*apikeysclient.Client).RevokeAPIKey)
+}
+
+func newSuspendCmd(c *cli) *cobra.Command {
+	return newKeyStateCmd(c, "suspend", "Suspend an API key until it is resumed", (*apikeysclient.Client).SuspendAPIKey)
+}
+
+func newResumeCmd(c *cli) *cobra.Command {
+	return newKeyStateCmd(c, "resume", "Resume a suspended API key", (*apikeysclient.Client).ResumeAPIKey)
+}
+
+// newKeyStateCmd returns the command changing the status of a key with the
+// lifecycle call change.
+func newKeyStateCmd(c *cli, name, short string, change func(*apikeysclient.Client, context.Context, uuid.UUID) (*apikeysclient.APIKey, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " <id>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			key, err := change(client, cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			return c.printKey(cmd, *key, false)
+		},
+	}
+}
+
+func newRevealCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reveal <id>",
+		Short: "Print the key material of an API key",
+		Long:  "Print the key material of an API key. The server only allows this with elevated privileges.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			material, err := client.RevealAPIKey(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			if c.output == outputJSON {
+				return printJSON(cmd.OutOrStdout(), map[string]string{"id": id.String(), "api_key": material})
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), material)
+			return nil
+		},
+	}
+}
+
+func newValidateCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [key]",
+		Short: "Check whether an API key is valid",
+		Long: "Check whether an API key is valid. The key is read from standard input when no argument " +
+			"is given, which keeps it out of the shell history. Exits with status 2 when the key is invalid.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var key string
+			if len(args) == 1 {
+				key = args[0]
+			} else {
+				line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("read key from stdin: %w", err)
+				}
+				key = strings.TrimSpace(line)
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			valid, err := client.ValidateAPIKey(cmd.Context(), key)
+			if err != nil {
+				return err
+			}
+
+			if c.output == outputJSON {
+				if err := printJSON(cmd.OutOrStdout(), map[string]bool{"valid": valid}); err != nil {
+					return err
+				}
+			} else if valid {
+				fmt.Fprintln(cmd.OutOrStdout(), "valid")
+			} else {
+				fmt.Fprintln(cmd.OutOrStdout(), "invalid")
+			}
+
+			if !valid {
+				return exitCode(2)
+			}
+			return nil
+		},
+	}
+}
+
+func newDeleteCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <id>...",
+		Short: "Delete API keys",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]uuid.UUID, len(args))
+			for i, arg := range args {
+				id, err := parseID(arg)
+				if err != nil {
+					return err
+				}
+				ids[i] = id
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			results, err := client.DeleteAPIKeys(cmd.Context(), ids)
+			if err != nil {
+				return err
+			}
+
+			var failed int
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+					fmt.Fprintf(cmd.ErrOrStderr(), "delete %s: %v\n", r.ID, r.Err)
+					continue
+				}
+				if c.output == outputTable {
+					fmt.Fprintf(cmd.OutOrStdout(), "deleted %s\n", r.ID)
+				}
+			}
+			if c.output == outputJSON {
+				if err := printJSON(cmd.OutOrStdout(), deleteResults(results)); err != nil {
+					return err
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d deletions failed", failed, len(results))
+			}
+			return nil
+		},
+	}
+}
+
+func newPurgeCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "purge <id>",
+		Short: "Permanently remove a deleted API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			if err := client.PurgeAPIKey(cmd.Context(), id); err != nil {
+				return err
+			}
+			if c.output == outputTable {
+				fmt.Fprintf(cmd.OutOrStdout(), "purged %s\n", id)
+			}
+			return nil
+		},
+	}
+}
+
+func parseID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid key ID %q: %w", s, err)
+	}
+	return id, nil
+}
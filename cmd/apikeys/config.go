@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// loadConfig reads profile from the config file at path, or at the default
+// location when path is empty. A missing default config file is not an
+// error.
+func loadConfig(path, profile string) (*apikeysclient.Config, error) {
+	explicit := path != ""
+	if !explicit {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return &apikeysclient.Config{}, nil
+		}
+		path = filepath.Join(dir, "apikeys", "config.json")
+	}
+
+	cfg, err := apikeysclient.LoadConfig(path, profile)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &apikeysclient.Config{}, nil
+	}
+	return cfg, err
+}
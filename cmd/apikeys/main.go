@@ -0,0 +1,144 @@
+// Command apikeys manages API keys on a keys server from the command line.
+//
+// The server and credentials are taken, in order of precedence, from the
+// --base-url and --token flags, the APIKEYS_BASE_URL and APIKEYS_TOKEN
+// environment variables, and the JSON config file given by --config
+// (default $XDG_CONFIG_HOME/apikeys/config.json):
+//
+//	{"base_url": "https://keys.example.com", "token": "..."}
+//
+// Output is a table by default; pass --output json for machine-readable
+// output.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := newRootCmd().ExecuteContext(ctx)
+	stop()
+
+	var code exitCode
+	switch {
+	case err == nil:
+	case errors.As(err, &code):
+		os.Exit(int(code))
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// exitCode is returned by commands to exit with a status other than 1
+// without printing an error.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
+// cli holds the global flags shared by all subcommands.
+type cli struct {
+	configPath string
+	profile    string
+	baseURL    string
+	token      string
+	output     string
+	dryRun     bool
+	actor      string
+}
+
+func newRootCmd() *cobra.Command {
+	c := &cli{}
+
+	root := &cobra.Command{
+		Use:           "apikeys",
+		Short:         "Manage API keys on a keys server",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if c.output != outputTable && c.output != outputJSON {
+				return fmt.Errorf("invalid --output %q: must be %q or %q", c.output, outputTable, outputJSON)
+			}
+			return nil
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&c.configPath, "config", "", "config file (default $XDG_CONFIG_HOME/apikeys/config.json)")
+	flags.StringVar(&c.profile, "profile", "", "config file profile (env APIKEYS_PROFILE)")
+	flags.StringVar(&c.baseURL, "base-url", "", "keys server base URL (env APIKEYS_BASE_URL)")
+	flags.StringVar(&c.token, "token", "", "bearer token (env APIKEYS_TOKEN)")
+	flags.StringVarP(&c.output, "output", "o", outputTable, "output format: table or json")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "have the server check changes without applying them")
+	flags.StringVar(&c.actor, "actor", "", "operator to attribute changes to in the audit log")
+
+	root.AddCommand(
+		newCreateCmd(c),
+		newGetCmd(c),
+		newListCmd(c),
+		newSearchCmd(c),
+		newUpdateCmd(c),
+		newRotateCmd(c),
+		newRevokeCmd(c),
+		newSuspendCmd(c),
+		newResumeCmd(c),
+		newRevealCmd(c),
+		newValidateCmd(c),
+		newDeleteCmd(c),
+		newPurgeCmd(c),
+	)
+
+	return root
+}
+
+// client returns a client configured from the flags, environment and config
+// file.
+func (c *cli) client() (*apikeysclient.Client, error) {
+	cfg, err := loadConfig(c.configPath, firstNonEmpty(c.profile, os.Getenv(apikeysclient.ProfileEnv)))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.BaseURL = firstNonEmpty(c.baseURL, os.Getenv(apikeysclient.BaseURLEnv), cfg.BaseURL)
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("no keys server configured: set --base-url, APIKEYS_BASE_URL or base_url in the config file")
+	}
+	cfg.Token = firstNonEmpty(c.token, os.Getenv(apikeysclient.TokenEnv), cfg.Token)
+	cfg.UserAgent = firstNonEmpty(cfg.UserAgent, "apikeys-cli")
+	if cfg.Retry == nil {
+		// Retry with the default policy unless configured otherwise.
+		cfg.Retry = &apikeysclient.RetryConfig{}
+	}
+
+	opts := []apikeysclient.Option{apikeysclient.WithRedactedSecrets()}
+	if c.dryRun {
+		opts = append(opts, apikeysclient.WithDryRun())
+	}
+	if c.actor != "" {
+		opts = append(opts, apikeysclient.WithActor(c.actor))
+	}
+
+	return cfg.NewClient(opts...)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// Output formats accepted by --output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printKey prints a single key. Key material is only shown in tables when
+// showSecret is set, i.e. for newly issued keys.
+func (c *cli) printKey(cmd *cobra.Command, key apikeysclient.APIKey, showSecret bool) error {
+	if c.output == outputJSON {
+		return printJSON(cmd.OutOrStdout(), key)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	if showSecret && key.APIKey != "" {
+		fmt.Fprintf(w, "API KEY:\t%s\n", key.APIKey)
+	}
+	fmt.Fprintf(w, "ID:\t%s\n", key.ID)
+	if key.KeyPrefix != "" {
+		fmt.Fprintf(w, "PREFIX:\t%s\n", key.KeyPrefix)
+	}
+	if key.Name != "" {
+		fmt.Fprintf(w, "NAME:\t%s\n", key.Name)
+	}
+	if key.Description != "" {
+		fmt.Fprintf(w, "DESCRIPTION:\t%s\n", key.Description)
+	}
+	fmt.Fprintf(w, "SERVICE ACCOUNT:\t%s\n", key.ServiceAccountID)
+	fmt.Fprintf(w, "SERVICE:\t%s\n", key.ServiceName)
+	fmt.Fprintf(w, "STATUS:\t%s\n", key.EffectiveStatus())
+	fmt.Fprintf(w, "SCOPES:\t%s\n", strings.Join(key.Scopes, ","))
+	fmt.Fprintf(w, "LABELS:\t%s\n", apikeysclient.LabelSelector(key.Labels))
+	fmt.Fprintf(w, "CREATED:\t%s\n", formatTime(&key.CreatedAt))
+	fmt.Fprintf(w, "EXPIRES:\t%s\n", formatTime(key.ExpiresAt))
+	if key.Version != 0 {
+		fmt.Fprintf(w, "VERSION:\t%d\n", key.Version)
+	}
+	return w.Flush()
+}
+
+// printKeys prints a listing of keys, never including key material in
+// tables.
+func (c *cli) printKeys(cmd *cobra.Command, keys []apikeysclient.APIKey) error {
+	if c.output == outputJSON {
+		if keys == nil {
+			keys = []apikeysclient.APIKey{}
+		}
+		return printJSON(cmd.OutOrStdout(), keys)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPREFIX\tNAME\tSERVICE ACCOUNT\tSERVICE\tSTATUS\tEXPIRES\tSCOPES")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.KeyPrefix, key.Name, key.ServiceAccountID, key.ServiceName, key.EffectiveStatus(),
+			formatTime(key.ExpiresAt), strings.Join(key.Scopes, ","))
+	}
+	return w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type deleteResult struct {
+	ID    uuid.UUID `json:"id"`
+	Error string    `json:"error,omitempty"`
+}
+
+func deleteResults(results []apikeysclient.DeleteAPIKeyResult) []deleteResult {
+	out := make([]deleteResult, len(results))
+	for i, r := range results {
+		out[i].ID = r.ID
+		if r.Err != nil {
+			out[i].Error = r.Err.Error()
+		}
+	}
+	return out
+}
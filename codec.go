@@ -0,0 +1,167 @@
+package apikeysclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types of response bodies.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeMsgpack  = "application/msgpack"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// ErrUnsupportedContentType is returned by NewClient when WithContentType
+// names a media type no codec handles.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Codec encodes and decodes bodies of one media type. Decoders receive the
+// same output types as for JSON responses, such as *APIKey or *[]APIKey.
+type Codec interface {
+	// ContentType returns the media type handled by the codec.
+	ContentType() string
+
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// MsgpackCodec encodes values as MessagePack, using the same field names as
+// their JSON encoding. It is built into every Client.
+type MsgpackCodec struct{}
+
+// ContentType implements Codec.
+func (MsgpackCodec) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+// Marshal implements Codec.
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Codec.
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// WithCodec makes codec available to WithContentType and decodes responses
+// of its media type with it. Package protocodec provides a Protocol Buffers
+// codec.
+func WithCodec(codec Codec) Option {
+	return func(_ *Client, o *options) {
+		o.codecs = append(o.codecs, codec)
+	}
+}
+
+// WithContentType asks the server for responses of mediaType, such as
+// ContentTypeMsgpack, which are cheaper to decode than JSON for large
+// listings. JSON stays acceptable, so servers without support for
+// mediaType keep working, and responses are decoded according to the
+// Content-Type the server picks. Request bodies are always sent as JSON.
+// MessagePack is built in; other media types need a codec registered with
+// WithCodec, or NewClient fails with ErrUnsupportedContentType.
+func WithContentType(mediaType string) Option {
+	return func(_ *Client, o *options) {
+		o.contentType = mediaType
+	}
+}
+
+// applyCodecs sets up the codecs of c from the collected options.
+func (o *options) applyCodecs(c *Client) error {
+	c.codecs = map[string]Codec{ContentTypeMsgpack: MsgpackCodec{}}
+	for _, codec := range o.codecs {
+		c.codecs[codec.ContentType()] = codec
+	}
+
+	if o.contentType == "" || o.contentType == ContentTypeJSON {
+		return nil
+	}
+	codec, ok := c.codecs[o.contentType]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnsupportedContentType, o.contentType)
+	}
+	c.codec = codec
+	return nil
+}
+
+// acceptHeader returns the Accept header of r: its own media type, JSON by
+// default, preceded by the preferred codec's when r can be answered in it.
+func (c *Client) acceptHeader(r *request) string {
+	accept := r.accept
+	if accept == "" {
+		accept = ContentTypeJSON
+	}
+	if c.codec != nil && (r.accept == "" || r.accept == MediaTypeV2) {
+		accept = c.codec.ContentType() + ", " + accept + ";q=0.9"
+	}
+	return accept
+}
+
+// parseMediaType is mime.ParseMediaType without the parameters. Plain
+// lowercase types, which most responses have, are returned without being
+// parsed, saving its allocations on every call.
+func parseMediaType(v string) (string, error) {
+	if isPlainMediaType(v) {
+		return v, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(v)
+	return mediaType, err
+}
+
+// isPlainMediaType reports whether v is a lowercase type/subtype without
+// parameters or spaces.
+func isPlainMediaType(v string) bool {
+	slash := strings.IndexByte(v, '/')
+	if slash <= 0 || slash == len(v)-1 {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		b := v[i]
+		switch {
+		case 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '.', b == '+', b == '-':
+		case b == '/' && i == slash:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// decodeResponse decodes the body of resp into out according to its
+// Content-Type, unwrapping it if it is enveloped. Bodies of media types
+// without a codec are decoded as JSON.
+func (c *Client) decodeResponse(resp *http.Response, out any) error {
+	mediaType, _ := parseMediaType(resp.Header.Get("Content-Type"))
+	codec, ok := c.codecs[mediaType]
+	if !ok {
+		return decodeJSONResponse(resp, out)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if len(data) == 0 {
+		return errEmptyBody
+	}
+	if err := codec.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", mediaType, err)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package apikeysclient
+
+import (
+	"cmp"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Environment variables read by ConfigFromEnv and NewClientFromEnv.
+// ProfileEnv also selects the profile of NewClientFromConfig.
+const (
+	BaseURLEnv             = "APIKEYS_BASE_URL"
+	TokenEnv               = "APIKEYS_TOKEN"
+	APIKeyEnv              = "APIKEYS_API_KEY"
+	APIKeyHeaderEnv        = "APIKEYS_API_KEY_HEADER"
+	TimeoutEnv             = "APIKEYS_TIMEOUT"
+	UserAgentEnv           = "APIKEYS_USER_AGENT"
+	OrgIDEnv               = "APIKEYS_ORG_ID"
+	ProjectIDEnv           = "APIKEYS_PROJECT_ID"
+	CAFileEnv              = "APIKEYS_CA_FILE"
+	CertFileEnv            = "APIKEYS_CERT_FILE"
+	KeyFileEnv             = "APIKEYS_KEY_FILE"
+	RetryMaxAttemptsEnv    = "APIKEYS_RETRY_MAX_ATTEMPTS"
+	RetryInitialBackoffEnv = "APIKEYS_RETRY_INITIAL_BACKOFF"
+	RetryMaxBackoffEnv     = "APIKEYS_RETRY_MAX_BACKOFF"
+	ProfileEnv             = "APIKEYS_PROFILE"
+)
+
+// ErrUnknownProfile is returned by LoadConfig for profiles the config file
+// does not define.
+var ErrUnknownProfile = errors.New("unknown config profile")
+
+// Config holds the settings of a client as read from the environment or a
+// config file. Zero fields keep the defaults of NewClient.
+type Config struct {
+	BaseURL string `json:"base_url,omitempty"`
+
+	// Token is a bearer token. APIKey is sent in APIKeyHeader, X-API-Key
+	// by default.
+	Token        string `json:"token,omitempty"`
+	APIKey       string `json:"api_key,omitempty"`
+	APIKeyHeader string `json:"api_key_header,omitempty"`
+
+	Timeout   Duration `json:"timeout,omitempty"`
+	UserAgent string   `json:"user_agent,omitempty"`
+
+	OrgID     string `json:"org_id,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+
+	TLS   *TLSFileConfig `json:"tls,omitempty"`
+	Retry *RetryConfig   `json:"retry,omitempty"`
+}
+
+// TLSFileConfig names the PEM files of a Config's TLS settings.
+type TLSFileConfig struct {
+	// CAFile holds the certificate authorities trusted instead of the
+	// system's.
+	CAFile string `json:"ca_file,omitempty"`
+
+	// CertFile and KeyFile hold the client certificate for mutual TLS.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// RetryConfig overrides fields of DefaultRetryPolicy. A MaxAttempts of 1
+// disables retries.
+type RetryConfig struct {
+	MaxAttempts    int      `json:"max_attempts,omitempty"`
+	InitialBackoff Duration `json:"initial_backoff,omitempty"`
+	MaxBackoff     Duration `json:"max_backoff,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "10s" in config
+// files.
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// configFile is the layout of a config file: settings shared by every
+// profile, the named profiles overriding them and the profile used when
+// none is asked for.
+type configFile struct {
+	Config
+
+	DefaultProfile string            `json:"default_profile,omitempty"`
+	Profiles       map[string]Config `json:"profiles,omitempty"`
+}
+
+// ConfigFromEnv returns the Config set by the APIKEYS_* environment
+// variables, such as BaseURLEnv and TokenEnv.
+func ConfigFromEnv() (*Config, error) {
+	cfg := &Config{
+		BaseURL:      os.Getenv(BaseURLEnv),
+		Token:        os.Getenv(TokenEnv),
+		APIKey:       os.Getenv(APIKeyEnv),
+		APIKeyHeader: os.Getenv(APIKeyHeaderEnv),
+		UserAgent:    os.Getenv(UserAgentEnv),
+		OrgID:        os.Getenv(OrgIDEnv),
+		ProjectID:    os.Getenv(ProjectIDEnv),
+	}
+	if err := envDuration(TimeoutEnv, &cfg.Timeout); err != nil {
+		return nil, err
+	}
+
+	tls := TLSFileConfig{
+		CAFile:   os.Getenv(CAFileEnv),
+		CertFile: os.Getenv(CertFileEnv),
+		KeyFile:  os.Getenv(KeyFileEnv),
+	}
+	if tls != (TLSFileConfig{}) {
+		cfg.TLS = &tls
+	}
+
+	var retry RetryConfig
+	if v := os.Getenv(RetryMaxAttemptsEnv); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", RetryMaxAttemptsEnv, err)
+		}
+		retry.MaxAttempts = n
+	}
+	if err := envDuration(RetryInitialBackoffEnv, &retry.InitialBackoff); err != nil {
+		return nil, err
+	}
+	if err := envDuration(RetryMaxBackoffEnv, &retry.MaxBackoff); err != nil {
+		return nil, err
+	}
+	if retry != (RetryConfig{}) {
+		cfg.Retry = &retry
+	}
+
+	return cfg, nil
+}
+
+func envDuration(name string, d *Duration) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	if err := d.UnmarshalText([]byte(v)); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadConfig reads the Config of profile from the YAML or JSON file at
+// path. The file's top-level settings apply to every profile, which
+// override them:
+//
+//	timeout: 5s
+//	default_profile: dev
+//	profiles:
+//	  dev:
+//	    base_url: http://localhost:8080
+//	  prod:
+//	    base_url: https://keys.example.com
+//	    token: ${APIKEYS_PROD_TOKEN}
+//	    tls:
+//	      ca_file: /etc/ssl/internal-ca.pem
+//
+// An empty profile selects the file's default_profile, or its top-level
+// settings alone when it has none. String settings may reference
+// environment variables as ${NAME}, so secrets need not be stored in the
+// file. Relative TLS file paths are relative to the directory of the file.
+func LoadConfig(path, profile string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	// YAML is a superset of JSON, so both decode alike.
+	var file configFile
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	cfg := file.Config
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	if profile != "" {
+		p, ok := file.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("%w %q in %s", ErrUnknownProfile, profile, path)
+		}
+		cfg.merge(&p)
+	}
+
+	expandEnv(reflect.ValueOf(&cfg).Elem())
+	if cfg.TLS != nil {
+		dir := filepath.Dir(path)
+		for _, f := range []*string{&cfg.TLS.CAFile, &cfg.TLS.CertFile, &cfg.TLS.KeyFile} {
+			if *f != "" && !filepath.IsAbs(*f) {
+				*f = filepath.Join(dir, *f)
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// merge sets the fields of c that are set in over. Nested settings are
+// merged field by field too.
+func (c *Config) merge(over *Config) {
+	mergeValue(reflect.ValueOf(c).Elem(), reflect.ValueOf(over).Elem())
+}
+
+func mergeValue(dst, src reflect.Value) {
+	for i := range dst.NumField() {
+		d, s := dst.Field(i), src.Field(i)
+		switch {
+		case s.IsZero():
+		case s.Kind() == reflect.Pointer && !d.IsNil():
+			merged := reflect.New(d.Elem().Type())
+			merged.Elem().Set(d.Elem())
+			mergeValue(merged.Elem(), s.Elem())
+			d.Set(merged)
+		default:
+			d.Set(s)
+		}
+	}
+}
+
+// expandEnv replaces ${NAME} references in the string fields of v, a
+// struct, and of the structs it points to.
+func expandEnv(v reflect.Value) {
+	for i := range v.NumField() {
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.String:
+			f.SetString(os.ExpandEnv(f.String()))
+		case f.Kind() == reflect.Pointer && !f.IsNil():
+			expandEnv(f.Elem())
+		}
+	}
+}
+
+// Options returns the options configuring a client as c says.
+func (c *Config) Options() ([]Option, error) {
+	var opts []Option
+	if c.Token != "" {
+		opts = append(opts, WithBearerToken(c.Token))
+	}
+	if c.APIKey != "" {
+		opts = append(opts, WithAPIKeyHeader(cmp.Or(c.APIKeyHeader, DefaultKeyHeader), c.APIKey))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, WithTimeout(time.Duration(c.Timeout)))
+	}
+	if c.UserAgent != "" {
+		opts = append(opts, WithUserAgent(c.UserAgent))
+	}
+	if c.OrgID != "" || c.ProjectID != "" {
+		opts = append(opts, WithTenant(Tenant{OrgID: c.OrgID, ProjectID: c.ProjectID}))
+	}
+
+	if tls := c.TLS; tls != nil {
+		if tls.CAFile != "" {
+			pem, err := os.ReadFile(tls.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("CA file %s holds no PEM certificates", tls.CAFile)
+			}
+			opts = append(opts, WithRootCAs(pool))
+		}
+		if tls.CertFile != "" || tls.KeyFile != "" {
+			opts = append(opts, WithClientCertificate(tls.CertFile, tls.KeyFile))
+		}
+	}
+
+	if r := c.Retry; r != nil {
+		policy := DefaultRetryPolicy()
+		if r.MaxAttempts > 0 {
+			policy.MaxAttempts = r.MaxAttempts
+		}
+		if r.InitialBackoff > 0 {
+			policy.InitialBackoff = time.Duration(r.InitialBackoff)
+		}
+		if r.MaxBackoff > 0 {
+			policy.MaxBackoff = time.Duration(r.MaxBackoff)
+		}
+		opts = append(opts, WithRetry(policy))
+	}
+
+	return opts, nil
+}
+
+// NewClient returns a client configured by c, then by opts, which take
+// precedence.
+func (c *Config) NewClient(opts ...Option) (*Client, error) {
+	configured, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c.BaseURL, slices.Concat(configured, opts)...)
+}
+
+// NewClientFromEnv returns a client configured by the environment, as read
+// by ConfigFromEnv, then by opts.
+func NewClientFromEnv(opts ...Option) (*Client, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewClient(opts...)
+}
+
+// NewClientFromConfig returns a client configured by the config file at
+// path, then by opts. The profile is the one named by the APIKEYS_PROFILE
+// environment variable, or the file's default; see LoadConfig.
+func NewClientFromConfig(path string, opts ...Option) (*Client, error) {
+	cfg, err := LoadConfig(path, os.Getenv(ProfileEnv))
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewClient(opts...)
+}
@@ -0,0 +1,156 @@
+package apikeysclient_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    apikeysclient.Config
+		wantErr bool
+	}{
+		{"empty", nil, apikeysclient.Config{}, false},
+		{"settings", map[string]string{
+			apikeysclient.BaseURLEnv:          "https://keys.example.com",
+			apikeysclient.TokenEnv:            "token",
+			apikeysclient.APIKeyEnv:           "key",
+			apikeysclient.APIKeyHeaderEnv:     "X-Key",
+			apikeysclient.TimeoutEnv:          "5s",
+			apikeysclient.OrgIDEnv:            "org",
+			apikeysclient.CAFileEnv:           "/ca.pem",
+			apikeysclient.RetryMaxAttemptsEnv: "3",
+			apikeysclient.RetryMaxBackoffEnv:  "1m",
+		}, apikeysclient.Config{
+			BaseURL:      "https://keys.example.com",
+			Token:        "token",
+			APIKey:       "key",
+			APIKeyHeader: "X-Key",
+			Timeout:      apikeysclient.Duration(5 * time.Second),
+			OrgID:        "org",
+			TLS:          &apikeysclient.TLSFileConfig{CAFile: "/ca.pem"},
+			Retry:        &apikeysclient.RetryConfig{MaxAttempts: 3, MaxBackoff: apikeysclient.Duration(time.Minute)},
+		}, false},
+		{"bad timeout", map[string]string{apikeysclient.TimeoutEnv: "5"}, apikeysclient.Config{}, true},
+		{"bad attempts", map[string]string{apikeysclient.RetryMaxAttemptsEnv: "many"}, apikeysclient.Config{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{
+				apikeysclient.BaseURLEnv, apikeysclient.TokenEnv, apikeysclient.APIKeyEnv,
+				apikeysclient.APIKeyHeaderEnv, apikeysclient.TimeoutEnv, apikeysclient.UserAgentEnv,
+				apikeysclient.OrgIDEnv, apikeysclient.ProjectIDEnv, apikeysclient.CAFileEnv,
+				apikeysclient.CertFileEnv, apikeysclient.KeyFileEnv, apikeysclient.RetryMaxAttemptsEnv,
+				apikeysclient.RetryInitialBackoffEnv, apikeysclient.RetryMaxBackoffEnv,
+			} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			cfg, err := apikeysclient.ConfigFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ConfigFromEnv = %+v, want error", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*cfg, tt.want) {
+				t.Errorf("ConfigFromEnv = %+v, want %+v", *cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "apikeys.yaml")
+	err := os.WriteFile(path, []byte(`
+timeout: 5s
+org_id: shared
+retry:
+  max_attempts: 2
+default_profile: dev
+profiles:
+  dev:
+    base_url: http://localhost:8080
+  prod:
+    base_url: https://keys.example.com
+    token: ${APIKEYS_TEST_TOKEN}
+    retry:
+      max_backoff: 1m
+    tls:
+      ca_file: ca.pem
+      cert_file: /etc/client.pem
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APIKEYS_TEST_TOKEN", "secret")
+
+	tests := []struct {
+		profile string
+		want    apikeysclient.Config
+		wantErr error
+	}{
+		{"", apikeysclient.Config{
+			BaseURL: "http://localhost:8080",
+			Timeout: apikeysclient.Duration(5 * time.Second),
+			OrgID:   "shared",
+			Retry:   &apikeysclient.RetryConfig{MaxAttempts: 2},
+		}, nil},
+		{"prod", apikeysclient.Config{
+			BaseURL: "https://keys.example.com",
+			Token:   "secret",
+			Timeout: apikeysclient.Duration(5 * time.Second),
+			OrgID:   "shared",
+			TLS:     &apikeysclient.TLSFileConfig{CAFile: filepath.Join(dir, "ca.pem"), CertFile: "/etc/client.pem"},
+			Retry:   &apikeysclient.RetryConfig{MaxAttempts: 2, MaxBackoff: apikeysclient.Duration(time.Minute)},
+		}, nil},
+		{"staging", apikeysclient.Config{}, apikeysclient.ErrUnknownProfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			cfg, err := apikeysclient.LoadConfig(path, tt.profile)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("LoadConfig = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*cfg, tt.want) {
+				t.Errorf("LoadConfig = %+v, want %+v", *cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	if err := os.WriteFile(path, []byte(`{"base_url": "https://keys.example.com", "timeout": "2s"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := apikeysclient.LoadConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := apikeysclient.Config{BaseURL: "https://keys.example.com", Timeout: apikeysclient.Duration(2 * time.Second)}
+	if !reflect.DeepEqual(*cfg, want) {
+		t.Errorf("LoadConfig = %+v, want %+v", *cfg, want)
+	}
+}
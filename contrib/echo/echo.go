@@ -0,0 +1,136 @@
+// Package apikeysecho authenticates Echo requests with API keys checked by
+// an apikeysclient.Client. It is the Echo equivalent of Client.Middleware:
+//
+//	e := echo.New()
+//	e.Use(apikeysecho.Middleware(client, apikeysecho.WithScopes("keys:read")))
+//	e.GET("/keys", func(c echo.Context) error {
+//		key, _ := apikeysecho.APIKey(c)
+//		...
+//	})
+package apikeysecho
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// ContextKey is the echo.Context key under which Middleware stores the
+// *apikeysclient.APIKey of an accepted request. The key is also stored in
+// the request context for apikeysclient.APIKeyFromContext.
+const ContextKey = "apikeysclient.apikey"
+
+// ErrorHandler returns the error for a request the middleware rejected, with
+// status as suggested by apikeysclient.AuthErrorStatus.
+type ErrorHandler func(c echo.Context, status int, err error) error
+
+// Option configures Middleware.
+type Option func(*config)
+
+type config struct {
+	extractor    apikeysclient.Extractor
+	scopes       []string
+	errorHandler ErrorHandler
+}
+
+// WithExtractor sets how the API key is found in requests. The default reads
+// the apikeysclient.DefaultKeyHeader header.
+func WithExtractor(e apikeysclient.Extractor) Option {
+	return func(cfg *config) {
+		cfg.extractor = e
+	}
+}
+
+// WithScopes rejects keys not granted all of scopes with 403.
+func WithScopes(scopes ...string) Option {
+	return func(cfg *config) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithErrorHandler sets the handler for rejected requests. The default
+// returns an *echo.HTTPError with the status code, leaving the response to
+// Echo's HTTPErrorHandler.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(cfg *config) {
+		cfg.errorHandler = h
+	}
+}
+
+func defaultErrorHandler(_ echo.Context, status int, err error) error {
+	return echo.NewHTTPError(status, http.StatusText(status)).SetInternal(err)
+}
+
+// Middleware returns Echo middleware that authenticates requests with
+// client.Authenticate, so the validation cache applies, and rejects those
+// without a valid key, lacking a scope set with WithScopes or not allowed by
+// the key's apikeysclient.Restrictions. Uses of accepted keys are recorded
+// with the reporter attached by client.ReportUsage, if any.
+func Middleware(client *apikeysclient.Client, opts ...Option) echo.MiddlewareFunc {
+	cfg := &config{
+		extractor:    apikeysclient.HeaderExtractor(apikeysclient.DefaultKeyHeader),
+		errorHandler: defaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			key, err := client.Authenticate(r.Context(), cfg.extractor.Extract(r))
+			if err == nil && !key.HasAllScopes(cfg.scopes...) {
+				err = apikeysclient.ErrInsufficientScope
+			}
+			meta := requestMeta(c)
+			if err == nil {
+				err = apikeysclient.CheckRestrictions(key, meta)
+			}
+			if err != nil {
+				return cfg.errorHandler(c, apikeysclient.AuthErrorStatus(err), err)
+			}
+
+			client.RecordUsage(key, meta)
+			c.Set(ContextKey, key)
+			c.SetRequest(r.WithContext(apikeysclient.ContextWithAPIKey(r.Context(), key)))
+			return next(c)
+		}
+	}
+}
+
+// requestMeta describes c for apikeysclient.CheckRestrictions, with the
+// client address resolved by Echo's IP extractor.
+func requestMeta(c echo.Context) apikeysclient.RequestMeta {
+	meta := apikeysclient.RequestMetaFromHTTP(c.Request())
+	if ip, err := netip.ParseAddr(c.RealIP()); err == nil {
+		meta.RemoteIP = ip.Unmap()
+	}
+	return meta
+}
+
+// RequireScopes returns Echo middleware rejecting requests whose key, stored
+// by Middleware, lacks any of scopes, for routes needing more scopes than
+// the group they belong to.
+func RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, ok := APIKey(c)
+			if !ok {
+				return defaultErrorHandler(c, http.StatusUnauthorized, apikeysclient.ErrMissingAPIKey)
+			}
+			if !key.HasAllScopes(scopes...) {
+				return defaultErrorHandler(c, http.StatusForbidden, apikeysclient.ErrInsufficientScope)
+			}
+			return next(c)
+		}
+	}
+}
+
+// APIKey returns the key stored in c by Middleware.
+func APIKey(c echo.Context) (*apikeysclient.APIKey, bool) {
+	key, ok := c.Get(ContextKey).(*apikeysclient.APIKey)
+	return key, ok
+}
@@ -0,0 +1,152 @@
+// Package apikeysfiber authenticates Fiber requests with API keys checked by
+// an apikeysclient.Client. It is the Fiber equivalent of Client.Middleware:
+//
+//	app := fiber.New()
+//	app.Use(apikeysfiber.Middleware(client, apikeysfiber.WithScopes("keys:read")))
+//	app.Get("/keys", func(c *fiber.Ctx) error {
+//		key, _ := apikeysfiber.APIKey(c)
+//		...
+//	})
+package apikeysfiber
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// ContextKey is the fiber.Ctx Locals key under which Middleware stores the
+// *apikeysclient.APIKey of an accepted request. The key is also stored in
+// the user context for apikeysclient.APIKeyFromContext.
+const ContextKey = "apikeysclient.apikey"
+
+// ErrorHandler returns the error for a request the middleware rejected, with
+// status as suggested by apikeysclient.AuthErrorStatus.
+type ErrorHandler func(c *fiber.Ctx, status int, err error) error
+
+// Option configures Middleware.
+type Option func(*config)
+
+type config struct {
+	extractor    apikeysclient.Extractor
+	scopes       []string
+	errorHandler ErrorHandler
+}
+
+// WithExtractor sets how the API key is found in requests. The default reads
+// the apikeysclient.DefaultKeyHeader header. Fiber requests are not
+// net/http requests: extractors are handed one carrying only the headers and
+// URL of the request, which is all the apikeysclient extractors read.
+func WithExtractor(e apikeysclient.Extractor) Option {
+	return func(cfg *config) {
+		cfg.extractor = e
+	}
+}
+
+// WithScopes rejects keys not granted all of scopes with 403.
+func WithScopes(scopes ...string) Option {
+	return func(cfg *config) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithErrorHandler sets the handler for rejected requests. The default
+// returns a *fiber.Error with the status code, leaving the response to the
+// app's ErrorHandler.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(cfg *config) {
+		cfg.errorHandler = h
+	}
+}
+
+func defaultErrorHandler(_ *fiber.Ctx, status int, _ error) error {
+	return fiber.NewError(status, http.StatusText(status))
+}
+
+// Middleware returns a Fiber handler that authenticates requests with
+// client.Authenticate, so the validation cache applies, and rejects those
+// without a valid key, lacking a scope set with WithScopes or not allowed by
+// the key's apikeysclient.Restrictions. Uses of accepted keys are recorded
+// with the reporter attached by client.ReportUsage, if any.
+func Middleware(client *apikeysclient.Client, opts ...Option) fiber.Handler {
+	cfg := &config{
+		extractor:    apikeysclient.HeaderExtractor(apikeysclient.DefaultKeyHeader),
+		errorHandler: defaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		key, err := client.Authenticate(ctx, cfg.extractor.Extract(extractorRequest(c)))
+		if err == nil && !key.HasAllScopes(cfg.scopes...) {
+			err = apikeysclient.ErrInsufficientScope
+		}
+		meta := requestMeta(c)
+		if err == nil {
+			err = apikeysclient.CheckRestrictions(key, meta)
+		}
+		if err != nil {
+			return cfg.errorHandler(c, apikeysclient.AuthErrorStatus(err), err)
+		}
+
+		client.RecordUsage(key, meta)
+		c.Locals(ContextKey, key)
+		c.SetUserContext(apikeysclient.ContextWithAPIKey(ctx, key))
+		return c.Next()
+	}
+}
+
+// extractorRequest returns the headers and URL of c as an *http.Request for
+// extractors.
+func extractorRequest(c *fiber.Ctx) *http.Request {
+	r := &http.Request{
+		Header: make(http.Header),
+		URL:    &url.URL{Path: c.Path(), RawQuery: string(c.Request().URI().QueryString())},
+	}
+	c.Request().Header.VisitAll(func(k, v []byte) {
+		r.Header.Add(string(k), string(v))
+	})
+	return r
+}
+
+// requestMeta describes c for apikeysclient.CheckRestrictions, with the
+// client address resolved by Fiber according to its proxy settings.
+func requestMeta(c *fiber.Ctx) apikeysclient.RequestMeta {
+	meta := apikeysclient.RequestMeta{
+		Origin:   c.Get(fiber.HeaderOrigin),
+		Referrer: c.Get(fiber.HeaderReferer),
+		Method:   c.Method(),
+	}
+	if ip, err := netip.ParseAddr(c.IP()); err == nil {
+		meta.RemoteIP = ip.Unmap()
+	}
+	return meta
+}
+
+// RequireScopes returns a Fiber handler rejecting requests whose key, stored
+// by Middleware, lacks any of scopes, for routes needing more scopes than
+// the group they belong to.
+func RequireScopes(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := APIKey(c)
+		if !ok {
+			return defaultErrorHandler(c, http.StatusUnauthorized, apikeysclient.ErrMissingAPIKey)
+		}
+		if !key.HasAllScopes(scopes...) {
+			return defaultErrorHandler(c, http.StatusForbidden, apikeysclient.ErrInsufficientScope)
+		}
+		return c.Next()
+	}
+}
+
+// APIKey returns the key stored in c by Middleware.
+func APIKey(c *fiber.Ctx) (*apikeysclient.APIKey, bool) {
+	key, ok := c.Locals(ContextKey).(*apikeysclient.APIKey)
+	return key, ok
+}
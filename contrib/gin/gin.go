@@ -0,0 +1,138 @@
+// Package apikeysgin authenticates Gin requests with API keys checked by an
+// apikeysclient.Client. It is the Gin equivalent of Client.Middleware:
+//
+//	r := gin.New()
+//	r.Use(apikeysgin.Middleware(client, apikeysgin.WithScopes("keys:read")))
+//	r.GET("/keys", func(c *gin.Context) {
+//		key, _ := apikeysgin.APIKey(c)
+//		...
+//	})
+package apikeysgin
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// ContextKey is the gin.Context key under which Middleware stores the
+// *apikeysclient.APIKey of an accepted request. The key is also stored in
+// the request context for apikeysclient.APIKeyFromContext.
+const ContextKey = "apikeysclient.apikey"
+
+// ErrorHandler writes the response for a request the middleware rejected,
+// with status as suggested by apikeysclient.AuthErrorStatus. It must abort
+// c.
+type ErrorHandler func(c *gin.Context, status int, err error)
+
+// Option configures Middleware.
+type Option func(*config)
+
+type config struct {
+	extractor    apikeysclient.Extractor
+	scopes       []string
+	errorHandler ErrorHandler
+}
+
+// WithExtractor sets how the API key is found in requests. The default reads
+// the apikeysclient.DefaultKeyHeader header.
+func WithExtractor(e apikeysclient.Extractor) Option {
+	return func(cfg *config) {
+		cfg.extractor = e
+	}
+}
+
+// WithScopes rejects keys not granted all of scopes with 403.
+func WithScopes(scopes ...string) Option {
+	return func(cfg *config) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithErrorHandler sets the handler for rejected requests. The default
+// aborts with the status code and a JSON {"error": ...} body.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(cfg *config) {
+		cfg.errorHandler = h
+	}
+}
+
+func defaultErrorHandler(c *gin.Context, status int, _ error) {
+	c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status)})
+}
+
+// Middleware returns a Gin handler that authenticates requests with
+// client.Authenticate, so the validation cache applies, and rejects those
+// without a valid key, lacking a scope set with WithScopes or not allowed by
+// the key's apikeysclient.Restrictions. Uses of accepted keys are recorded
+// with the reporter attached by client.ReportUsage, if any.
+func Middleware(client *apikeysclient.Client, opts ...Option) gin.HandlerFunc {
+	cfg := &config{
+		extractor:    apikeysclient.HeaderExtractor(apikeysclient.DefaultKeyHeader),
+		errorHandler: defaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(c *gin.Context) {
+		key, err := client.Authenticate(c.Request.Context(), cfg.extractor.Extract(c.Request))
+		if err == nil && !key.HasAllScopes(cfg.scopes...) {
+			err = apikeysclient.ErrInsufficientScope
+		}
+		meta := requestMeta(c)
+		if err == nil {
+			err = apikeysclient.CheckRestrictions(key, meta)
+		}
+		if err != nil {
+			cfg.errorHandler(c, apikeysclient.AuthErrorStatus(err), err)
+			return
+		}
+
+		client.RecordUsage(key, meta)
+		c.Set(ContextKey, key)
+		c.Request = c.Request.WithContext(apikeysclient.ContextWithAPIKey(c.Request.Context(), key))
+		c.Next()
+	}
+}
+
+// requestMeta describes c for apikeysclient.CheckRestrictions, with the
+// client address resolved by Gin according to its trusted proxies.
+func requestMeta(c *gin.Context) apikeysclient.RequestMeta {
+	meta := apikeysclient.RequestMetaFromHTTP(c.Request)
+	if ip, err := netip.ParseAddr(c.ClientIP()); err == nil {
+		meta.RemoteIP = ip.Unmap()
+	}
+	return meta
+}
+
+// RequireScopes returns a Gin handler rejecting requests whose key, stored
+// by Middleware, lacks any of scopes, for routes needing more scopes than
+// the group they belong to.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := APIKey(c)
+		if !ok {
+			defaultErrorHandler(c, http.StatusUnauthorized, apikeysclient.ErrMissingAPIKey)
+			return
+		}
+		if !key.HasAllScopes(scopes...) {
+			defaultErrorHandler(c, http.StatusForbidden, apikeysclient.ErrInsufficientScope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// APIKey returns the key stored in c by Middleware.
+func APIKey(c *gin.Context) (*apikeysclient.APIKey, bool) {
+	v, ok := c.Get(ContextKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := v.(*apikeysclient.APIKey)
+	return key, ok
+}
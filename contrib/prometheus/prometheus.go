@@ -0,0 +1,186 @@
+// Package apikeysprom exposes the activity of apikeysclient.Client as
+// Prometheus metrics. A Collector is both the client's Observer and a
+// prometheus.Collector:
+//
+//	metrics := apikeysprom.NewCollector()
+//	prometheus.MustRegister(metrics)
+//	client, err := apikeysclient.NewClient(baseURL, apikeysclient.WithObserver(metrics))
+//
+// It exports, under the "apikeys_client" prefix by default:
+//
+//	validations_total{outcome}               ValidateAPIKey results: valid, invalid or error
+//	cache_lookups_total{cache,result}        validation and key cache hits and misses
+//	request_duration_seconds{method,result}  calls to the keys server, including retries
+//	circuit_breaker_state{state}             1 for the current breaker state, 0 otherwise
+package apikeysprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// Option configures a Collector.
+type Option func(*config)
+
+type config struct {
+	namespace   string
+	subsystem   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// WithNamespace sets the namespace of the metric names, "apikeys" by
+// default.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets the subsystem of the metric names, "client" by default.
+func WithSubsystem(subsystem string) Option {
+	return func(c *config) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels adds labels to every metric, e.g. to tell apart the
+// collectors of several clients registered together.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the request duration histogram, in
+// seconds. It defaults to prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// circuitStates are the values of the state label of circuit_breaker_state.
+var circuitStates = []apikeysclient.CircuitState{
+	apikeysclient.CircuitClosed,
+	apikeysclient.CircuitOpen,
+	apikeysclient.CircuitHalfOpen,
+}
+
+// Collector records the events of the clients it observes. It is safe for
+// concurrent use and may observe several clients at once.
+type Collector struct {
+	validations *prometheus.CounterVec
+	cache       *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	circuit     *prometheus.GaugeVec
+}
+
+var _ apikeysclient.Observer = (*Collector)(nil)
+
+// NewCollector returns a Collector with no recorded events and the circuit
+// breaker closed.
+func NewCollector(opts ...Option) *Collector {
+	cfg := &config{namespace: "apikeys", subsystem: "client", buckets: prometheus.DefBuckets}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	c := &Collector{
+		validations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "validations_total",
+			Help:        "Number of API key validations by outcome.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"outcome"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "cache_lookups_total",
+			Help:        "Number of client cache lookups by cache and result.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"cache", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Duration of calls to the keys server, including retries.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"method", "result"}),
+		circuit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   cfg.namespace,
+			Subsystem:   cfg.subsystem,
+			Name:        "circuit_breaker_state",
+			Help:        "Current state of the circuit breaker, 1 for the current state and 0 otherwise.",
+			ConstLabels: cfg.constLabels,
+		}, []string{"state"}),
+	}
+
+	// Export every series from the start so rates and alerts work before
+	// the first event.
+	for _, outcome := range []apikeysclient.ValidationOutcome{apikeysclient.ValidationValid, apikeysclient.ValidationInvalid, apikeysclient.ValidationError} {
+		c.validations.WithLabelValues(string(outcome))
+	}
+	for _, cache := range []string{apikeysclient.CacheValidation, apikeysclient.CacheKey} {
+		c.cache.WithLabelValues(cache, "hit")
+		c.cache.WithLabelValues(cache, "miss")
+	}
+	c.ObserveCircuitState(apikeysclient.CircuitClosed)
+
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.validations.Describe(ch)
+	c.cache.Describe(ch)
+	c.duration.Describe(ch)
+	c.circuit.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.validations.Collect(ch)
+	c.cache.Collect(ch)
+	c.duration.Collect(ch)
+	c.circuit.Collect(ch)
+}
+
+// ObserveRequest implements apikeysclient.Observer.
+func (c *Collector) ObserveRequest(op string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	c.duration.WithLabelValues(op, result).Observe(d.Seconds())
+}
+
+// ObserveValidation implements apikeysclient.Observer.
+func (c *Collector) ObserveValidation(outcome apikeysclient.ValidationOutcome) {
+	c.validations.WithLabelValues(string(outcome)).Inc()
+}
+
+// ObserveCache implements apikeysclient.Observer.
+func (c *Collector) ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.cache.WithLabelValues(cache, result).Inc()
+}
+
+// ObserveCircuitState implements apikeysclient.Observer.
+func (c *Collector) ObserveCircuitState(state apikeysclient.CircuitState) {
+	for _, s := range circuitStates {
+		v := 0.0
+		if s == state {
+			v = 1
+		}
+		c.circuit.WithLabelValues(s.String()).Set(v)
+	}
+}
@@ -0,0 +1,102 @@
+// Package apikeysredis implements apikeysclient.Cache on Redis, so that
+// the replicas of a service share their validation and key caches:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	cache := apikeysredis.New(rdb)
+//	client, err := apikeysclient.NewClient(baseURL,
+//		apikeysclient.WithValidationCache(apikeysclient.ValidationCacheConfig{TTL: time.Minute, Cache: cache}),
+//		apikeysclient.WithKeyCache(apikeysclient.KeyCacheConfig{TTL: time.Minute, Cache: cache}),
+//	)
+//
+// A key revoked or invalidated through any of the replicas is then dropped
+// from the cache of all of them.
+package apikeysredis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// DefaultPrefix is prepended to the Redis keys of cache entries unless
+// configured otherwise.
+const DefaultPrefix = "apikeys:"
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithPrefix sets the prefix of the Redis keys of cache entries,
+// DefaultPrefix by default, so that several caches can share a database.
+func WithPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithMaxTTL bounds the lifetime of entries stored without a TTL, such as
+// key cache records, which are otherwise kept until deleted or evicted by
+// Redis' maxmemory policy.
+func WithMaxTTL(d time.Duration) Option {
+	return func(c *Cache) {
+		c.maxTTL = d
+	}
+}
+
+// Cache is an apikeysclient.Cache storing entries in Redis. It is safe for
+// concurrent use.
+type Cache struct {
+	rdb    redis.UniversalClient
+	prefix string
+	maxTTL time.Duration
+}
+
+var _ apikeysclient.Cache = (*Cache)(nil)
+
+// New returns a Cache storing entries through rdb, which may be a single
+// node, cluster or sentinel client.
+func New(rdb redis.UniversalClient, opts ...Option) *Cache {
+	c := &Cache{rdb: rdb, prefix: DefaultPrefix}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get implements apikeysclient.Cache.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := c.rdb.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Set implements apikeysclient.Cache.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 || (c.maxTTL > 0 && ttl > c.maxTTL) {
+		ttl = c.maxTTL
+	}
+	return c.rdb.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+// Delete implements apikeysclient.Cache. Keys are deleted one by one in a
+// pipeline, as they may hash to different slots of a cluster.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, c.prefix+key)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,102 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCreateRequest is returned by CreateKey for requests the server
+// would reject, such as those without a service account.
+var ErrInvalidCreateRequest = errors.New("invalid create API key request")
+
+// CreateAPIKeyRequest describes a key to create with CreateKey. It holds only
+// the fields callers choose; IDs, timestamps and statuses are set by the
+// server.
+type CreateAPIKeyRequest struct {
+	// ServiceAccountID is the service account owning the key. It is
+	// required.
+	ServiceAccountID uuid.UUID
+
+	// ServiceName is the name of the service using the key.
+	ServiceName string
+
+	// Name and Description are free-form, human-readable details of the key.
+	Name        string
+	Description string
+
+	// Labels are key/value pairs used to organize keys and to select them
+	// in listings.
+	Labels map[string]string
+
+	// Scopes lists the permissions granted to the key.
+	Scopes []string
+
+	// ExpiresAt is when the key stops being valid, nil for no expiry.
+	ExpiresAt *time.Time
+
+	// Restrictions, RateLimit and Quota bound where and how much the key
+	// may be used, nil for none.
+	Restrictions *Restrictions
+	RateLimit    *KeyRateLimit
+	Quota        *Quota
+
+	// Secret is key material to create the key with, for keys imported
+	// from another system. The server generates it when empty. With
+	// WithHashedKeys only its HashAPIKey digest is sent.
+	Secret string
+}
+
+// apiKey returns the APIKey sent to create the key of r.
+func (r *CreateAPIKeyRequest) apiKey() APIKey {
+	return APIKey{
+		ServiceAccountID: r.ServiceAccountID,
+		ServiceName:      r.ServiceName,
+		Name:             r.Name,
+		Description:      r.Description,
+		Labels:           maps.Clone(r.Labels),
+		Scopes:           slices.Clone(r.Scopes),
+		ExpiresAt:        r.ExpiresAt,
+		Restrictions:     r.Restrictions,
+		RateLimit:        r.RateLimit,
+		Quota:            r.Quota,
+		APIKey:           r.Secret,
+		Status:           KeyActive,
+		IsActive:         true,
+		Valid:            true,
+	}
+}
+
+// CreateAPIKeyResponse is the result of CreateKey.
+type CreateAPIKeyResponse struct {
+	// Key is the created key as later reads return it, without its
+	// material.
+	Key APIKey
+
+	// Secret is the material of the key. It is only returned on creation;
+	// store it or hand it over now.
+	Secret string
+}
+
+// CreateKey creates the key described by req. The server's responses are
+// handled as for CreateAPIKey, including asynchronous creation. The key
+// material is returned in the response's Secret only.
+func (c *Client) CreateKey(ctx context.Context, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	if req.ServiceAccountID == uuid.Nil {
+		return nil, fmt.Errorf("%w: missing service account", ErrInvalidCreateRequest)
+	}
+
+	key, err := c.createAPIKey(ctx, req.apiKey())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CreateAPIKeyResponse{Key: key, Secret: key.APIKey}
+	resp.Key.APIKey = ""
+	return resp, nil
+}
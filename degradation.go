@@ -0,0 +1,141 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// errValidationTimeout is the error of validations given up after the
+// Timeout of the DegradationPolicy. It counts as the server being
+// unavailable.
+var errValidationTimeout = errors.New("validation timed out")
+
+// DegradationPolicy decides how ValidateAPIKey, and so the middleware,
+// behaves when the keys server is unavailable, as described by
+// WithValidationFailurePolicy, so that a blip of the keys service does not
+// fail all traffic.
+type DegradationPolicy struct {
+	// MaxStaleness is how long past its TTL a cached result may be served
+	// while the server is unavailable. Stale results are never served
+	// otherwise. It needs WithValidationCache, and applies to the records
+	// of WithKeyCache the middleware resolves too. Zero serves none.
+	MaxStaleness time.Duration
+
+	// Timeout bounds how long validations wait for the server when they
+	// have something to degrade to: a stale result, or a Fallback other
+	// than FailWithError. Once it has passed the validation degrades,
+	// while the request goes on in the background to refresh the cache.
+	// Zero waits for the request, within the client's timeouts.
+	Timeout time.Duration
+
+	// Fallback is the result when no stale result can be served,
+	// FailWithError by default. ContextWithFailurePolicy overrides it.
+	Fallback FailurePolicy
+}
+
+// WithDegradationPolicy sets how validation degrades when the server is
+// unavailable. It replaces the policy set by WithValidationFailurePolicy,
+// which is short for a policy with only a Fallback.
+func WithDegradationPolicy(p DegradationPolicy) Option {
+	return func(c *Client, _ *options) {
+		c.degradation = p
+	}
+}
+
+type failurePolicyContextKey struct{}
+
+// ContextWithFailurePolicy returns a context making the validations made
+// with it fall back to p, instead of the client's DegradationPolicy
+// Fallback, when no stale result can be served. The middleware sets it for
+// the routes it guards with WithRouteFailurePolicy.
+func ContextWithFailurePolicy(ctx context.Context, p FailurePolicy) context.Context {
+	return context.WithValue(ctx, failurePolicyContextKey{}, p)
+}
+
+// WithRouteFailurePolicy makes the middleware fall back to p when the
+// server is unavailable, overriding the client's DegradationPolicy
+// Fallback for the routes it guards. With FailOpen, requests whose key
+// record cannot be resolved are let through without one, and restrictions
+// are not enforced.
+func WithRouteFailurePolicy(p FailurePolicy) MiddlewareOption {
+	return func(m *middlewareConfig) {
+		m.failurePolicy = &p
+	}
+}
+
+// degradationPolicy returns the policy of the validations made with ctx.
+func (c *Client) degradationPolicy(ctx context.Context) DegradationPolicy {
+	p := c.degradation
+	if fallback, ok := ctx.Value(failurePolicyContextKey{}).(FailurePolicy); ok {
+		p.Fallback = fallback
+	}
+	return p
+}
+
+// validateWithin validates apikey, refreshing the cache, and gives up with
+// errValidationTimeout once p.Timeout has passed if degrade is set. The
+// validation then goes on in the background.
+func (c *Client) validateWithin(ctx context.Context, apikey, hash string, p DegradationPolicy, degrade bool) (ValidateResponse, error) {
+	if p.Timeout <= 0 || !degrade {
+		r := c.validateRequest(ctx, apikey, hash)
+		defer releaseValidateRequest(r)
+		return c.refreshValidation(ctx, r, hash)
+	}
+
+	type result struct {
+		validation ValidateResponse
+		err        error
+	}
+	done := make(chan result, 1)
+	// The request is built in the goroutine too, since it may need the
+	// server's capabilities, which counts against p.Timeout. The goroutine
+	// returns r to the pool once the request is done, however long after
+	// the caller gave up.
+	go func() {
+		ctx := context.WithoutCancel(ctx)
+		r := c.validateRequest(ctx, apikey, hash)
+		defer releaseValidateRequest(r)
+		validation, err := c.refreshValidation(ctx, r, hash)
+		done <- result{validation, err}
+	}()
+
+	timer := time.NewTimer(p.Timeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.validation, res.err
+	case <-timer.C:
+		return ValidateResponse{}, errValidationTimeout
+	case <-ctx.Done():
+		return ValidateResponse{}, ctx.Err()
+	}
+}
+
+// refreshValidation sends the validation r of the key with the given hash
+// and caches its result.
+func (c *Client) refreshValidation(ctx context.Context, r *request, hash string) (ValidateResponse, error) {
+	validation, err := c.validate(ctx, r)
+	if err != nil {
+		return ValidateResponse{}, err
+	}
+
+	if c.validationCache != nil {
+		c.validationCache.set(ctx, hash, validation.IsValid, validation.ExpiresAt)
+	}
+	c.recordQuota(hash, validation.Quota)
+	return validation, nil
+}
+
+// logDegraded logs a validation answered with result, "stale" or a
+// FailurePolicy, because of err.
+func (c *Client) logDegraded(ctx context.Context, result string, err error) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelWarn, "apikeys validation degraded",
+		slog.String("result", result),
+		slog.String("error", err.Error()),
+	)
+}
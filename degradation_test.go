@@ -0,0 +1,113 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// TestValidateTimeoutRefreshesInBackground checks that validations given up
+// after the DegradationPolicy Timeout complete in the background with their
+// own key, and cache its result.
+func TestValidateTimeoutRefreshesInBackground(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		var body struct {
+			APIKey string `json:"api_key"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		key := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/apikeys/key/"), "/validate")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apikeysclient.ValidateResponse{IsValid: strings.HasPrefix(key, "valid-")})
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL,
+		apikeysclient.WithValidationCache(apikeysclient.ValidationCacheConfig{TTL: time.Hour, NegativeTTL: time.Hour}),
+		apikeysclient.WithDegradationPolicy(apikeysclient.DegradationPolicy{
+			Timeout:  time.Millisecond,
+			Fallback: apikeysclient.FailClosed,
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	keys := make([]string, 40)
+	for i := range keys {
+		prefix := "invalid-"
+		if i%2 == 0 {
+			prefix = "valid-"
+		}
+		keys[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if valid, err := client.ValidateAPIKey(ctx, key); valid || err != nil {
+				t.Errorf("ValidateAPIKey(%s) before the server answered = %v, %v; want fail closed", key, valid, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, key := range keys {
+		want := strings.HasPrefix(key, "valid-")
+		for {
+			valid, err := client.ValidateAPIKey(ctx, key)
+			if err == nil && valid == want {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("ValidateAPIKey(%s) = %v, %v; want %v from the cache", key, valid, err, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+// TestValidateTimeoutCoversCapabilities checks that the DegradationPolicy
+// Timeout also bounds the discovery of the server's capabilities made for
+// the first validation.
+func TestValidateTimeoutCoversCapabilities(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/capabilities" {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apikeysclient.ValidateResponse{IsValid: true})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, err := apikeysclient.NewClient(srv.URL,
+		apikeysclient.WithDegradationPolicy(apikeysclient.DegradationPolicy{
+			Timeout:  20 * time.Millisecond,
+			Fallback: apikeysclient.FailClosed,
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	valid, err := client.ValidateAPIKey(context.Background(), "key")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ValidateAPIKey took %v, want it bounded by the policy Timeout", elapsed)
+	}
+	if valid || err != nil {
+		t.Errorf("ValidateAPIKey = %v, %v; want fail closed", valid, err)
+	}
+}
@@ -0,0 +1,55 @@
+package apikeysclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// unixSocketBaseURL is the base URL of clients dialing a unix socket
+// without one. The host only fills the Host header of requests.
+const unixSocketBaseURL = "http://localhost"
+
+// DialContextFunc opens connections to the keys server, as
+// net.Dialer.DialContext does.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// WithDialContext sets the function opening connections to the keys server,
+// for instance to go through a tunnel or a custom resolver. As with the TLS
+// options, the http.Client in use is copied with a copy of its transport.
+func WithDialContext(dial DialContextFunc) Option {
+	return func(_ *Client, o *options) {
+		o.dialContext = dial
+	}
+}
+
+// WithUnixSocket connects to the keys server over the unix domain socket at
+// path, such as a node-local validation sidecar, whatever the host of the
+// base URL. The base URL may then be empty; see NewClient. It takes
+// precedence over WithDialContext.
+func WithUnixSocket(path string) Option {
+	return func(_ *Client, o *options) {
+		o.unixSocket = path
+	}
+}
+
+// hasDialer reports whether o configures how connections are opened.
+func (o *options) hasDialer() bool {
+	return o.dialContext != nil || o.unixSocket != ""
+}
+
+// configureDialer installs the dialing settings of o on t.
+func (o *options) configureDialer(t *http.Transport) {
+	switch {
+	case o.unixSocket != "":
+		var d net.Dialer
+		path := o.unixSocket
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", path)
+		}
+		// A proxy would be dialed through the socket too.
+		t.Proxy = nil
+	case o.dialContext != nil:
+		t.DialContext = o.dialContext
+	}
+}
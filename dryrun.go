@@ -0,0 +1,35 @@
+package apikeysclient
+
+import "context"
+
+// DryRunHeader is the request header asking the server to validate a
+// mutating call, including the caller's permissions, without applying it.
+const DryRunHeader = "X-Dry-Run"
+
+type dryRunContextKey struct{}
+
+// ContextWithDryRun returns a context making the mutating calls made with it
+// dry runs: the server checks them and returns the result they would have,
+// but persists nothing.
+func ContextWithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, true)
+}
+
+// WithDryRun makes every mutating call of the client a dry run, as with
+// ContextWithDryRun. Reads are unaffected, so a dry-run client can plan
+// changes against the server's current state.
+func WithDryRun() Option {
+	return func(c *Client, _ *options) {
+		c.dryRun = true
+	}
+}
+
+// isDryRun reports whether r, made with ctx, is a dry run. Only mutations
+// are marked.
+func (c *Client) isDryRun(ctx context.Context, r *request) bool {
+	if !r.mutates() {
+		return false
+	}
+	dry, _ := ctx.Value(dryRunContextKey{}).(bool)
+	return dry || c.dryRun
+}
@@ -0,0 +1,55 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidTTL is returned by CreateEphemeralKey for a TTL that is not
+// positive.
+var ErrInvalidTTL = errors.New("ephemeral key TTL must be positive")
+
+// ephemeralKeyRequest is the body of a CreateEphemeralKey call.
+type ephemeralKeyRequest struct {
+	ServiceAccountID uuid.UUID `json:"service_account_id"`
+	TTLSeconds       int64     `json:"ttl_seconds"`
+	Scopes           []string  `json:"scopes,omitempty"`
+}
+
+// CreateEphemeralKey mints a key for the given service account that the
+// server expires on its own after ttl, for CI jobs and one-off scripts that
+// should not leave keys behind. The returned key carries its material and
+// the ExpiresAt set by the server. ttl is sent in whole seconds, rounded up.
+func (c *Client) CreateEphemeralKey(ctx context.Context, serviceAccountID uuid.UUID, ttl time.Duration, scopes []string) (*APIKey, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+
+	in := EphemeralKeyInput{ServiceAccountID: serviceAccountID, TTL: ttl, Scopes: scopes}
+	var key APIKey
+	_, err := c.do(ctx, &request{
+		op:     "CreateEphemeralKey",
+		method: http.MethodPost,
+		url:    c.endpoint("apikeys", "ephemeral"),
+		body: ephemeralKeyRequest{
+			ServiceAccountID: serviceAccountID,
+			TTLSeconds:       int64((ttl + time.Second - 1) / time.Second),
+			Scopes:           scopes,
+		},
+		in:             in,
+		idempotencyKey: c.idempotencyKey(ctx, "CreateEphemeralKey", in),
+		secret:         true,
+	}, &key, http.StatusCreated, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	if key.ExpiresAt == nil {
+		return nil, errors.New("create ephemeral key: server returned no expiry")
+	}
+
+	return &key, nil
+}
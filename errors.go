@@ -0,0 +1,93 @@
+package apikeysclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by APIError through errors.Is. ErrConflict also
+// matches 412 Precondition Failed, the answer to changes conditional on a
+// version the key is no longer at.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+)
+
+// maxErrorBodySize bounds how much of an error response body is read.
+const maxErrorBodySize = 64 << 10
+
+// APIError is returned when the server answers with an unexpected status code.
+// Code and Message are decoded from the JSON error body when the server sends
+// one.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Is reports whether the error matches one of the package's sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
+
+// errorBody is the JSON error payload returned by the server. Older servers
+// send a bare {"error": "..."} object.
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// newAPIError builds an APIError from resp, decoding its body if possible.
+func newAPIError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil || len(data) == 0 {
+		return apiErr
+	}
+
+	var body errorBody
+	if err := json.Unmarshal(data, &body); err != nil {
+		apiErr.Message = strings.TrimSpace(string(data))
+		return apiErr
+	}
+
+	apiErr.Code = body.Code
+	apiErr.Message = body.Message
+	if apiErr.Message == "" {
+		apiErr.Message = body.Error
+	}
+
+	return apiErr
+}
@@ -0,0 +1,395 @@
+package apikeysclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportFormat is the file format of a key inventory.
+type ExportFormat string
+
+// Formats supported by ExportKeys and ImportKeys.
+const (
+	// ExportJSON is a JSON array of key records.
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV is a CSV file with a header row naming the columns.
+	// Scopes are space separated, labels are written as a label selector,
+	// e.g. "env=prod,team=payments", and restrictions, rate limits and
+	// quotas as JSON.
+	ExportCSV ExportFormat = "csv"
+)
+
+// ErrUnsupportedFormat is returned by ExportKeys and ImportKeys for formats
+// other than ExportJSON and ExportCSV.
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// ExportOptions controls ExportKeys.
+type ExportOptions struct {
+	// ExcludeSecrets leaves key material out of the export. Key hashes are
+	// kept, so imported keys keep working on servers accepting them.
+	ExcludeSecrets bool
+
+	// List filters the exported keys. All keys are exported when it is nil.
+	List *ListAPIKeysOptions
+}
+
+// ImportOptions controls ImportKeys.
+type ImportOptions struct {
+	// Format is the format of the inventory, ExportJSON by default.
+	Format ExportFormat
+
+	// ServiceAccountIDs maps the service account IDs of the inventory to
+	// those of the target environment. Unmapped IDs are kept.
+	ServiceAccountIDs map[uuid.UUID]uuid.UUID
+}
+
+// ImportResult is the outcome of importing one key record. Exactly one of
+// Key and Err is set.
+type ImportResult struct {
+	// SourceID is the ID of the key in the inventory. The server assigns
+	// imported keys new IDs.
+	SourceID uuid.UUID
+
+	Key *APIKey
+	Err error
+}
+
+// exportRecord is the form of a key in an inventory.
+type exportRecord struct {
+	ID               uuid.UUID         `json:"id"`
+	ServiceAccountID uuid.UUID         `json:"service_account_id"`
+	ServiceName      string            `json:"service_name,omitempty"`
+	Name             string            `json:"name,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	APIKey           string            `json:"api_key,omitempty"`
+	KeyHash          string            `json:"key_hash,omitempty"`
+	KeyPrefix        string            `json:"key_prefix,omitempty"`
+	Scopes           []string          `json:"scopes,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Restrictions     *Restrictions     `json:"restrictions,omitempty"`
+	RateLimit        *KeyRateLimit     `json:"rate_limit,omitempty"`
+	Quota            *Quota            `json:"quota,omitempty"`
+	Status           KeyStatus         `json:"status,omitempty"`
+	IsActive         bool              `json:"is_active"`
+	Valid            bool              `json:"valid"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+	ExpiresAt        *time.Time        `json:"expires_at,omitempty"`
+}
+
+// csvColumns are the columns of ExportCSV, in order.
+var csvColumns = []string{
+	"id", "service_account_id", "service_name", "name", "description",
+	"api_key", "key_hash", "key_prefix", "scopes", "labels",
+	"is_active", "valid", "created_at", "updated_at", "expires_at",
+	"restrictions", "rate_limit", "quota", "status",
+}
+
+func newExportRecord(k *APIKey, excludeSecrets bool) exportRecord {
+	rec := exportRecord{
+		ID:               k.ID,
+		ServiceAccountID: k.ServiceAccountID,
+		ServiceName:      k.ServiceName,
+		Name:             k.Name,
+		Description:      k.Description,
+		APIKey:           k.APIKey,
+		KeyHash:          k.KeyHash,
+		KeyPrefix:        k.KeyPrefix,
+		Scopes:           k.Scopes,
+		Labels:           k.Labels,
+		Restrictions:     k.Restrictions,
+		RateLimit:        k.RateLimit,
+		Quota:            k.Quota,
+		Status:           k.Status,
+		IsActive:         k.IsActive,
+		Valid:            k.Valid,
+		CreatedAt:        k.CreatedAt,
+		UpdatedAt:        k.UpdatedAt,
+		ExpiresAt:        k.ExpiresAt,
+	}
+	if rec.KeyHash == "" && rec.APIKey != "" {
+		rec.KeyHash = HashAPIKey(rec.APIKey)
+	}
+	if excludeSecrets {
+		rec.APIKey = ""
+	}
+	return rec
+}
+
+// apiKey returns the key to create for the record.
+func (rec *exportRecord) apiKey() APIKey {
+	return APIKey{
+		ServiceAccountID: rec.ServiceAccountID,
+		ServiceName:      rec.ServiceName,
+		Name:             rec.Name,
+		Description:      rec.Description,
+		APIKey:           rec.APIKey,
+		KeyHash:          rec.KeyHash,
+		Scopes:           rec.Scopes,
+		Labels:           rec.Labels,
+		Restrictions:     rec.Restrictions,
+		RateLimit:        rec.RateLimit,
+		Quota:            rec.Quota,
+		Status:           rec.Status,
+		IsActive:         rec.IsActive,
+		Valid:            rec.Valid,
+		ExpiresAt:        rec.ExpiresAt,
+	}
+}
+
+func (rec *exportRecord) csvRow() []string {
+	expiresAt := ""
+	if rec.ExpiresAt != nil {
+		expiresAt = rec.ExpiresAt.Format(time.RFC3339Nano)
+	}
+	restrictions := ""
+	if !rec.Restrictions.IsZero() {
+		// Restrictions only hold strings and prefixes, which always encode.
+		b, _ := json.Marshal(rec.Restrictions)
+		restrictions = string(b)
+	}
+	rateLimit := ""
+	if rec.RateLimit != nil {
+		b, _ := json.Marshal(rec.RateLimit)
+		rateLimit = string(b)
+	}
+	quota := ""
+	if !rec.Quota.IsZero() {
+		b, _ := json.Marshal(rec.Quota)
+		quota = string(b)
+	}
+	return []string{
+		rec.ID.String(),
+		rec.ServiceAccountID.String(),
+		rec.ServiceName,
+		rec.Name,
+		rec.Description,
+		rec.APIKey,
+		rec.KeyHash,
+		rec.KeyPrefix,
+		strings.Join(rec.Scopes, " "),
+		LabelSelector(rec.Labels).String(),
+		strconv.FormatBool(rec.IsActive),
+		strconv.FormatBool(rec.Valid),
+		rec.CreatedAt.Format(time.RFC3339Nano),
+		rec.UpdatedAt.Format(time.RFC3339Nano),
+		expiresAt,
+		restrictions,
+		rateLimit,
+		quota,
+		string(rec.Status),
+	}
+}
+
+// parseCSVRow decodes a row whose columns are named by header.
+func parseCSVRow(header, row []string) (exportRecord, error) {
+	var rec exportRecord
+	for i, col := range header {
+		v := row[i]
+		if v == "" {
+			continue
+		}
+
+		var err error
+		switch col {
+		case "id":
+			rec.ID, err = uuid.Parse(v)
+		case "service_account_id":
+			rec.ServiceAccountID, err = uuid.Parse(v)
+		case "service_name":
+			rec.ServiceName = v
+		case "name":
+			rec.Name = v
+		case "description":
+			rec.Description = v
+		case "api_key":
+			rec.APIKey = v
+		case "key_hash":
+			rec.KeyHash = v
+		case "key_prefix":
+			rec.KeyPrefix = v
+		case "scopes":
+			rec.Scopes = strings.Fields(v)
+		case "labels":
+			var labels LabelSelector
+			labels, err = ParseLabelSelector(v)
+			rec.Labels = labels
+		case "is_active":
+			rec.IsActive, err = strconv.ParseBool(v)
+		case "valid":
+			rec.Valid, err = strconv.ParseBool(v)
+		case "created_at":
+			rec.CreatedAt, err = time.Parse(time.RFC3339Nano, v)
+		case "updated_at":
+			rec.UpdatedAt, err = time.Parse(time.RFC3339Nano, v)
+		case "expires_at":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339Nano, v)
+			rec.ExpiresAt = &t
+		case "restrictions":
+			rec.Restrictions = &Restrictions{}
+			err = json.Unmarshal([]byte(v), rec.Restrictions)
+		case "rate_limit":
+			rec.RateLimit = &KeyRateLimit{}
+			err = json.Unmarshal([]byte(v), rec.RateLimit)
+		case "quota":
+			rec.Quota = &Quota{}
+			err = json.Unmarshal([]byte(v), rec.Quota)
+		case "status":
+			rec.Status = KeyStatus(v)
+		}
+		if err != nil {
+			return exportRecord{}, fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	return rec, nil
+}
+
+// ExportKeys writes the keys selected by opts to w in format and returns how
+// many were written. Keys are fetched page by page and written as they
+// arrive, so large inventories are not held in memory. Clients created
+// with WithRedactedSecrets never export key material. opts may be nil.
+func (c *Client) ExportKeys(ctx context.Context, w io.Writer, format ExportFormat, opts *ExportOptions) (int, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+
+	var (
+		write  func(rec *exportRecord) error
+		finish func() error
+	)
+	bw := bufio.NewWriter(w)
+	switch format {
+	case ExportJSON:
+		n := 0
+		write = func(rec *exportRecord) error {
+			sep := ",\n"
+			if n == 0 {
+				sep = "[\n"
+			}
+			n++
+			b, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if _, err := bw.WriteString(sep); err != nil {
+				return err
+			}
+			_, err = bw.Write(b)
+			return err
+		}
+		finish = func() error {
+			end := "\n]\n"
+			if n == 0 {
+				end = "[]\n"
+			}
+			if _, err := bw.WriteString(end); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+	case ExportCSV:
+		cw := csv.NewWriter(bw)
+		if err := cw.Write(csvColumns); err != nil {
+			return 0, err
+		}
+		write = func(rec *exportRecord) error {
+			return cw.Write(rec.csvRow())
+		}
+		finish = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+	default:
+		return 0, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+
+	count := 0
+	it := c.ListAPIKeysIter(opts.List)
+	for it.Next(ctx) {
+		key := it.APIKey()
+		rec := newExportRecord(&key, opts.ExcludeSecrets)
+		if err := write(&rec); err != nil {
+			return count, fmt.Errorf("write export: %w", err)
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		return count, fmt.Errorf("list keys: %w", err)
+	}
+
+	if err := finish(); err != nil {
+		return count, fmt.Errorf("write export: %w", err)
+	}
+	return count, nil
+}
+
+// ImportKeys creates the keys of the inventory read from r, as written by
+// ExportKeys, and returns one result per record, in order. Keys keep their
+// material or hash when the inventory has them, so existing credentials
+// keep working; records with neither get new material from the server.
+// Keys are created concurrently as for CreateAPIKeys. The returned error is
+// only set when the inventory cannot be read or ctx is done; per-key
+// failures are reported in the results. opts may be nil.
+func (c *Client) ImportKeys(ctx context.Context, r io.Reader, opts *ImportOptions) ([]ImportResult, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	var recs []exportRecord
+	switch opts.Format {
+	case ExportJSON, "":
+		if err := json.NewDecoder(r).Decode(&recs); err != nil {
+			return nil, fmt.Errorf("decode inventory: %w", err)
+		}
+	case ExportCSV:
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("read inventory header: %w", err)
+		}
+		for line := 2; ; line++ {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("read inventory: %w", err)
+			}
+			rec, err := parseCSVRow(header, row)
+			if err != nil {
+				return nil, fmt.Errorf("inventory line %d: %w", line, err)
+			}
+			recs = append(recs, rec)
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, opts.Format)
+	}
+
+	results := make([]ImportResult, len(recs))
+	err := forEach(ctx, len(recs), c.batchConcurrency, func(i int) {
+		rec := &recs[i]
+		if id, ok := opts.ServiceAccountIDs[rec.ServiceAccountID]; ok {
+			rec.ServiceAccountID = id
+		}
+
+		key, err := c.createAPIKey(itemContext(ctx, i), rec.apiKey())
+		results[i] = ImportResult{SourceID: rec.ID, Key: keyOrNil(key, err), Err: err}
+	})
+
+	return results, err
+}
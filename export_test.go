@@ -0,0 +1,142 @@
+package apikeysclient_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeysclienttest"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	account, targetAccount := uuid.New(), uuid.New()
+	expiresAt := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+
+	source := apikeysclienttest.NewFake()
+	seeded := source.Seed(
+		apikeysclient.APIKey{
+			ServiceAccountID: account,
+			Name:             "web, frontend",
+			Description:      "has \"quotes\"\nand lines",
+			Scopes:           []string{"keys:read", "keys:write"},
+			Labels:           map[string]string{"env": "prod", "team": "payments"},
+			Restrictions: &apikeysclient.Restrictions{
+				AllowedCIDRs:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+				AllowedReferrers: []string{"*.example.com"},
+			},
+			RateLimit: &apikeysclient.KeyRateLimit{RequestsPerSecond: 5, Burst: 10},
+			Quota:     &apikeysclient.Quota{Limit: 1000, Period: apikeysclient.QuotaPerDay},
+			Status:    apikeysclient.KeyActive,
+			IsActive:  true,
+			Valid:     true,
+			ExpiresAt: &expiresAt,
+		},
+		apikeysclient.APIKey{ServiceAccountID: account, Name: "suspended", Status: apikeysclient.KeySuspended, Valid: true},
+	)
+
+	tests := []struct {
+		format         apikeysclient.ExportFormat
+		excludeSecrets bool
+	}{
+		{apikeysclient.ExportJSON, false},
+		{apikeysclient.ExportJSON, true},
+		{apikeysclient.ExportCSV, false},
+		{apikeysclient.ExportCSV, true},
+	}
+
+	for _, tt := range tests {
+		name := string(tt.format)
+		if tt.excludeSecrets {
+			name += " without secrets"
+		}
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := source.Client().ExportKeys(ctx, &buf, tt.format, &apikeysclient.ExportOptions{ExcludeSecrets: tt.excludeSecrets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(seeded) {
+				t.Fatalf("exported %d keys, want %d", n, len(seeded))
+			}
+			if got := strings.Contains(buf.String(), seeded[0].APIKey); got == tt.excludeSecrets {
+				t.Errorf("export contains key material = %v, want %v", got, !tt.excludeSecrets)
+			}
+
+			target := apikeysclienttest.NewFake()
+			client := target.Client()
+			results, err := client.ImportKeys(ctx, &buf, &apikeysclient.ImportOptions{
+				Format:            tt.format,
+				ServiceAccountIDs: map[uuid.UUID]uuid.UUID{account: targetAccount},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(seeded) {
+				t.Fatalf("got %d results, want %d", len(results), len(seeded))
+			}
+
+			for i, res := range results {
+				want := seeded[i]
+				if res.Err != nil {
+					t.Fatalf("import of %s: %v", want.Name, res.Err)
+				}
+				if res.SourceID != want.ID {
+					t.Errorf("SourceID = %s, want %s", res.SourceID, want.ID)
+				}
+
+				got := *res.Key
+				if got.ServiceAccountID != targetAccount {
+					t.Errorf("%s: ServiceAccountID = %s, want the mapped %s", want.Name, got.ServiceAccountID, targetAccount)
+				}
+				if got.KeyHash != want.KeyHash {
+					t.Errorf("%s: KeyHash = %q, want %q", want.Name, got.KeyHash, want.KeyHash)
+				}
+				if got.Name != want.Name || got.Description != want.Description || got.Status != want.Status ||
+					!reflect.DeepEqual(got.Scopes, want.Scopes) || !reflect.DeepEqual(got.Labels, want.Labels) ||
+					!reflect.DeepEqual(got.Restrictions, want.Restrictions) || !reflect.DeepEqual(got.RateLimit, want.RateLimit) ||
+					!reflect.DeepEqual(got.Quota, want.Quota) || !reflect.DeepEqual(got.ExpiresAt, want.ExpiresAt) {
+					t.Errorf("imported key = %+v, want the fields of %+v", got, want)
+				}
+			}
+
+			// Imported keys keep their credentials, with or without material.
+			valid, err := client.ValidateAPIKey(ctx, seeded[0].APIKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !valid {
+				t.Error("imported key does not validate with its original material")
+			}
+		})
+	}
+}
+
+func TestExportImportUnsupportedFormat(t *testing.T) {
+	client := apikeysclienttest.NewFake().Client()
+
+	if _, err := client.ExportKeys(context.Background(), &bytes.Buffer{}, "xml", nil); !errors.Is(err, apikeysclient.ErrUnsupportedFormat) {
+		t.Errorf("ExportKeys = %v, want ErrUnsupportedFormat", err)
+	}
+	if _, err := client.ImportKeys(context.Background(), strings.NewReader(""), &apikeysclient.ImportOptions{Format: "xml"}); !errors.Is(err, apikeysclient.ErrUnsupportedFormat) {
+		t.Errorf("ImportKeys = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	client := apikeysclienttest.NewFake().Client()
+	inventory := "id,name,is_active\n" + uuid.NewString() + ",ok,true\n" + uuid.NewString() + ",bad,maybe\n"
+
+	_, err := client.ImportKeys(context.Background(), strings.NewReader(inventory), &apikeysclient.ImportOptions{Format: apikeysclient.ExportCSV})
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "is_active") {
+		t.Errorf("ImportKeys = %v, want an error naming line 3 and column is_active", err)
+	}
+}
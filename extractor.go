@@ -0,0 +1,84 @@
+package apikeysclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Extractor finds the API key carried by an inbound request for the
+// middleware. Extract returns "" when the request carries no key in the
+// place the extractor looks.
+type Extractor interface {
+	Extract(r *http.Request) string
+}
+
+// ExtractorFunc adapts a function to the Extractor interface.
+type ExtractorFunc func(r *http.Request) string
+
+// Extract implements Extractor.
+func (f ExtractorFunc) Extract(r *http.Request) string {
+	return f(r)
+}
+
+// HeaderExtractor reads the key from the header with the given name.
+func HeaderExtractor(name string) Extractor {
+	return ExtractorFunc(func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	})
+}
+
+// BearerExtractor reads the key from a Bearer token in the Authorization
+// header.
+func BearerExtractor() Extractor {
+	return ExtractorFunc(func(r *http.Request) string {
+		scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	})
+}
+
+// QueryExtractor reads the key from the URL query parameter with the given
+// name. Keys in URLs end up in access logs and browser histories, so prefer
+// headers where clients allow it.
+func QueryExtractor(param string) Extractor {
+	return ExtractorFunc(func(r *http.Request) string {
+		return strings.TrimSpace(r.URL.Query().Get(param))
+	})
+}
+
+// BasicAuthExtractor reads the key from the username of HTTP basic
+// authentication, as sent by clients that only support basic auth. The
+// password is ignored.
+func BasicAuthExtractor() Extractor {
+	return ExtractorFunc(func(r *http.Request) string {
+		user, _, ok := r.BasicAuth()
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(user)
+	})
+}
+
+// ChainExtractors returns an Extractor trying each of extractors in order
+// and returning the first key found.
+func ChainExtractors(extractors ...Extractor) Extractor {
+	return ExtractorFunc(func(r *http.Request) string {
+		for _, e := range extractors {
+			if key := e.Extract(r); key != "" {
+				return key
+			}
+		}
+		return ""
+	})
+}
+
+// keyHeaderExtractor returns the extractor of WithKeyHeader: a Bearer token
+// for the Authorization header and the raw value for any other.
+func keyHeaderExtractor(name string) Extractor {
+	if strings.EqualFold(name, "Authorization") {
+		return BearerExtractor()
+	}
+	return HeaderExtractor(name)
+}
@@ -0,0 +1,55 @@
+package apikeysclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestExtractors(t *testing.T) {
+	chain := apikeysclient.ChainExtractors(
+		apikeysclient.HeaderExtractor("X-API-Key"),
+		apikeysclient.BearerExtractor(),
+		apikeysclient.QueryExtractor("api_key"),
+	)
+
+	tests := []struct {
+		name      string
+		extractor apikeysclient.Extractor
+		target    string
+		header    http.Header
+		basicUser string
+		want      string
+	}{
+		{"header", apikeysclient.HeaderExtractor("X-API-Key"), "/", http.Header{"X-Api-Key": {" key "}}, "", "key"},
+		{"header missing", apikeysclient.HeaderExtractor("X-API-Key"), "/", nil, "", ""},
+		{"bearer", apikeysclient.BearerExtractor(), "/", http.Header{"Authorization": {"Bearer key"}}, "", "key"},
+		{"bearer scheme case", apikeysclient.BearerExtractor(), "/", http.Header{"Authorization": {"bearer  key"}}, "", "key"},
+		{"bearer other scheme", apikeysclient.BearerExtractor(), "/", http.Header{"Authorization": {"Token key"}}, "", ""},
+		{"bearer no token", apikeysclient.BearerExtractor(), "/", http.Header{"Authorization": {"Bearer"}}, "", ""},
+		{"query", apikeysclient.QueryExtractor("api_key"), "/?api_key=key", nil, "", "key"},
+		{"query other param", apikeysclient.QueryExtractor("api_key"), "/?key=key", nil, "", ""},
+		{"basic auth", apikeysclient.BasicAuthExtractor(), "/", nil, "key", "key"},
+		{"basic auth missing", apikeysclient.BasicAuthExtractor(), "/", nil, "", ""},
+		{"chain first", chain, "/?api_key=query", http.Header{"X-Api-Key": {"header"}, "Authorization": {"Bearer bearer"}}, "", "header"},
+		{"chain falls through", chain, "/?api_key=query", http.Header{"Authorization": {"Basic abc"}}, "", "query"},
+		{"chain none", chain, "/", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			if tt.basicUser != "" {
+				r.SetBasicAuth(tt.basicUser, "ignored")
+			}
+			if got := tt.extractor.Extract(r); got != tt.want {
+				t.Errorf("Extract = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Package grpcauth authenticates inbound gRPC calls with API keys checked by
+// an apikeysclient.Client. It is the gRPC counterpart of Client.Middleware:
+//
+//	srv := grpc.NewServer(
+//		grpc.UnaryInterceptor(grpcauth.UnaryServerInterceptor(client)),
+//		grpc.StreamInterceptor(grpcauth.StreamServerInterceptor(client)),
+//	)
+//
+// Handlers read the accepted key with apikeysclient.APIKeyFromContext and
+// the caller's identity with apikeysclient.ServiceAccountIDFromContext.
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// DefaultMetadataKey is the metadata key the API key is read from unless
+// configured otherwise. A Bearer token in the authorization metadata is
+// accepted as well.
+const DefaultMetadataKey = "x-api-key"
+
+// Option configures the interceptors.
+type Option func(*config)
+
+type config struct {
+	metadataKey string
+	scopes      []string
+	skip        func(fullMethod string) bool
+}
+
+// WithMetadataKey sets the metadata key the API key is read from, instead of
+// DefaultMetadataKey or a Bearer token. When it is authorization, the key is
+// expected as a Bearer token.
+func WithMetadataKey(key string) Option {
+	return func(cfg *config) {
+		cfg.metadataKey = strings.ToLower(key)
+	}
+}
+
+// WithScopes rejects keys not granted all of scopes with PermissionDenied.
+func WithScopes(scopes ...string) Option {
+	return func(cfg *config) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithSkip exempts the methods for which skip returns true from
+// authentication, such as health checks and reflection. skip is called with
+// the full method name, e.g. "/grpc.health.v1.Health/Check".
+func WithSkip(skip func(fullMethod string) bool) Option {
+	return func(cfg *config) {
+		cfg.skip = skip
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{metadataKey: DefaultMetadataKey}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// UnaryServerInterceptor returns an interceptor authenticating unary calls
+// with client.Authenticate, so the validation cache applies. Uses of
+// accepted keys are recorded with the reporter attached by
+// client.ReportUsage, if any.
+func UnaryServerInterceptor(client *apikeysclient.Client, opts ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := cfg.authenticate(ctx, client, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor authenticating streaming
+// calls with client.Authenticate when the stream is opened.
+func StreamServerInterceptor(client *apikeysclient.Client, opts ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := cfg.authenticate(ss.Context(), client, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate checks the API key of the call to fullMethod made with ctx
+// and returns ctx carrying the accepted key, or a gRPC status error.
+func (cfg *config) authenticate(ctx context.Context, client *apikeysclient.Client, fullMethod string) (context.Context, error) {
+	if cfg.skip != nil && cfg.skip(fullMethod) {
+		return ctx, nil
+	}
+
+	key, err := client.Authenticate(ctx, cfg.extract(ctx))
+	if err == nil && !key.HasAllScopes(cfg.scopes...) {
+		err = apikeysclient.ErrInsufficientScope
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	client.RecordUsage(key, apikeysclient.RequestMeta{RemoteIP: peerIP(ctx)})
+	return apikeysclient.ContextWithAPIKey(ctx, key), nil
+}
+
+// peerIP returns the address of the peer of the call made with ctx, the zero
+// Addr if it is unknown or not an IP address.
+func peerIP(ctx context.Context) netip.Addr {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}
+	}
+	addr, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Addr().Unmap()
+}
+
+// extract returns the API key in the incoming metadata of ctx, or "" if
+// there is none.
+func (cfg *config) extract(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if cfg.metadataKey != "authorization" {
+		if v := md.Get(cfg.metadataKey); len(v) > 0 && strings.TrimSpace(v[0]) != "" {
+			return strings.TrimSpace(v[0])
+		}
+		if cfg.metadataKey != DefaultMetadataKey {
+			return ""
+		}
+	}
+
+	for _, v := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(strings.TrimSpace(v), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// statusError maps an authentication error to a gRPC status, as
+// apikeysclient.AuthErrorStatus does to HTTP status codes.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, apikeysclient.ErrMissingAPIKey):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, apikeysclient.ErrInvalidAPIKey), errors.Is(err, apikeysclient.ErrInsufficientScope):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Unavailable, "API key could not be validated")
+}
@@ -0,0 +1,252 @@
+// Package grpctransport carries apikeysclient calls over the keys server's
+// gRPC API defined in proto/apikeys/v1. Calls without an RPC there, such as
+// PatchAPIKey, CreateEphemeralKey and the service account and webhook
+// calls, fail with apikeysclient.ErrUnsupportedOperation. CreateAPIKeys and
+// DeleteAPIKeys fall back to one RPC per key.
+package grpctransport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeyspb"
+	"github.com/PiccoloMondoC/apikeysclient/protocodec"
+)
+
+// idempotencyKeyMetadata is the request metadata key carrying
+// Call.IdempotencyKey, the gRPC counterpart of the Idempotency-Key header.
+const idempotencyKeyMetadata = "idempotency-key"
+
+// dryRunMetadata is the request metadata key carrying Call.DryRun, the gRPC
+// counterpart of the apikeysclient.DryRunHeader header.
+const dryRunMetadata = "x-dry-run"
+
+// actorMetadata is the request metadata key carrying Call.Actor, the gRPC
+// counterpart of the apikeysclient.ActorHeader header.
+const actorMetadata = "x-on-behalf-of"
+
+// Request metadata keys carrying Call.Tenant, the gRPC counterparts of the
+// apikeysclient.OrgIDHeader and apikeysclient.ProjectIDHeader headers.
+const (
+	orgIDMetadata     = "x-org-id"
+	projectIDMetadata = "x-project-id"
+)
+
+// Transport is an apikeysclient.Transport backed by a gRPC connection.
+type Transport struct {
+	client apikeyspb.APIKeysClient
+}
+
+// New returns a Transport sending calls over conn.
+func New(conn grpc.ClientConnInterface) *Transport {
+	return &Transport{client: apikeyspb.NewAPIKeysClient(conn)}
+}
+
+// NewGRPCClient returns an apikeysclient.Client that talks to the keys server
+// over conn. opts configure the client as for apikeysclient.NewClient.
+func NewGRPCClient(conn grpc.ClientConnInterface, opts ...apikeysclient.Option) *apikeysclient.Client {
+	opts = append(opts, apikeysclient.WithTransport(New(conn)))
+	// NewClient only fails on base URLs, which a Transport does not need.
+	client, _ := apikeysclient.NewClient("", opts...)
+	return client
+}
+
+// RoundTrip implements apikeysclient.Transport.
+func (t *Transport) RoundTrip(ctx context.Context, call *apikeysclient.Call) error {
+	if call.IdempotencyKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, idempotencyKeyMetadata, call.IdempotencyKey)
+	}
+	if call.DryRun {
+		ctx = metadata.AppendToOutgoingContext(ctx, dryRunMetadata, "true")
+	}
+	if call.Actor != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, actorMetadata, call.Actor)
+	}
+	if call.Tenant.OrgID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, orgIDMetadata, call.Tenant.OrgID)
+	}
+	if call.Tenant.ProjectID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, projectIDMetadata, call.Tenant.ProjectID)
+	}
+
+	err := t.roundTrip(ctx, call)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if s, ok := status.FromError(err); ok && err != nil {
+		return &apikeysclient.APIError{
+			StatusCode: httpStatus(s.Code()),
+			Code:       s.Code().String(),
+			Message:    s.Message(),
+		}
+	}
+	return err
+}
+
+func (t *Transport) roundTrip(ctx context.Context, call *apikeysclient.Call) error {
+	switch call.Op {
+	case "CreateAPIKey":
+		in := call.Input.(apikeysclient.APIKey)
+		key, err := t.client.CreateAPIKey(ctx, &apikeyspb.CreateAPIKeyRequest{ApiKey: protocodec.ToProto(&in)})
+		return setKey(call, key, err)
+	case "GetAPIKeyByID":
+		key, err := t.client.GetAPIKey(ctx, &apikeyspb.GetAPIKeyRequest{Id: call.Input.(uuid.UUID).String()})
+		return setKey(call, key, err)
+	case "GetAPIKeyByAPIKey", "LookupAPIKey":
+		key, err := t.client.GetAPIKeyByKey(ctx, &apikeyspb.GetAPIKeyByKeyRequest{ApiKey: call.Input.(string)})
+		return setKey(call, key, err)
+	case "GetAPIKeyByHash":
+		key, err := t.client.GetAPIKeyByKey(ctx, &apikeyspb.GetAPIKeyByKeyRequest{KeyHash: call.Input.(string)})
+		return setKey(call, key, err)
+	case "UpdateAPIKey":
+		key, err := t.client.UpdateAPIKey(ctx, &apikeyspb.UpdateAPIKeyRequest{ApiKey: protocodec.ToProto(call.Input.(*apikeysclient.APIKey))})
+		return setKey(call, key, err)
+	case "DeleteAPIKey", "PurgeAPIKey":
+		_, err := t.client.DeleteAPIKey(ctx, &apikeyspb.DeleteAPIKeyRequest{
+			Id:    call.Input.(uuid.UUID).String(),
+			Purge: call.Op == "PurgeAPIKey",
+		})
+		return err
+	case "ListAPIKeys":
+		return t.list(ctx, call, nil, uuid.Nil)
+	case "ListAPIKeysPage":
+		return t.list(ctx, call, call.Input.(*apikeysclient.ListAPIKeysOptions), uuid.Nil)
+	case "ListAPIKeysByServiceAccount":
+		in := call.Input.(apikeysclient.ServiceAccountListInput)
+		return t.list(ctx, call, in.Options, in.ServiceAccountID)
+	case "ValidateAPIKey", "ValidateAPIKeyPOST", "ValidateAPIKeyHash":
+		req := &apikeyspb.ValidateAPIKeyRequest{ApiKey: call.Input.(string)}
+		if call.Op == "ValidateAPIKeyHash" {
+			req = &apikeyspb.ValidateAPIKeyRequest{KeyHash: call.Input.(string)}
+		}
+		resp, err := t.client.ValidateAPIKey(ctx, req)
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.ValidateResponse) = protocodec.ValidationFromProto(resp)
+		return nil
+	case "RotateAPIKey":
+		resp, err := t.client.RotateAPIKey(ctx, &apikeyspb.RotateAPIKeyRequest{Id: call.Input.(uuid.UUID).String()})
+		if err != nil {
+			return err
+		}
+		*call.Output.(*apikeysclient.RotateAPIKeyResponse) = apikeysclient.RotateAPIKeyResponse{
+			NewKey:            protocodec.FromProto(resp.GetNewKey()),
+			OldKey:            protocodec.FromProto(resp.GetOldKey()),
+			GracePeriodEndsAt: fromTimestamp(resp.GetGracePeriodEndsAt()),
+		}
+		return nil
+	case "RevokeAPIKey":
+		key, err := t.client.RevokeAPIKey(ctx, &apikeyspb.RevokeAPIKeyRequest{Id: call.Input.(uuid.UUID).String()})
+		return setKey(call, key, err)
+	case "ActivateAPIKey":
+		key, err := t.client.ActivateAPIKey(ctx, &apikeyspb.ActivateAPIKeyRequest{Id: call.Input.(uuid.UUID).String()})
+		return setKey(call, key, err)
+	case "SuspendAPIKey":
+		key, err := t.client.SuspendAPIKey(ctx, &apikeyspb.SuspendAPIKeyRequest{Id: call.Input.(uuid.UUID).String()})
+		return setKey(call, key, err)
+	case "ResumeAPIKey":
+		key, err := t.client.ResumeAPIKey(ctx, &apikeyspb.ResumeAPIKeyRequest{Id: call.Input.(uuid.UUID).String()})
+		return setKey(call, key, err)
+	case "ExtendExpiry":
+		in := call.Input.(apikeysclient.ExtendExpiryInput)
+		key, err := t.client.ExtendExpiry(ctx, &apikeyspb.ExtendExpiryRequest{
+			Id:        in.ID.String(),
+			ExpiresAt: timestamppb.New(in.ExpiresAt),
+		})
+		return setKey(call, key, err)
+	}
+
+	return fmt.Errorf("%s: %w", call.Op, apikeysclient.ErrUnsupportedOperation)
+}
+
+func (t *Transport) list(ctx context.Context, call *apikeysclient.Call, opts *apikeysclient.ListAPIKeysOptions, serviceAccountID uuid.UUID) error {
+	req := &apikeyspb.ListAPIKeysRequest{}
+	if opts != nil {
+		req.Page = int32(opts.Page)
+		req.PerPage = int32(opts.PerPage)
+		req.Cursor = opts.Cursor
+		req.IsActive = opts.IsActive
+		req.Sort = string(opts.Sort)
+		req.LabelSelector = opts.Labels.String()
+		if opts.ServiceAccountID != uuid.Nil {
+			req.ServiceAccountId = opts.ServiceAccountID.String()
+		}
+		if !opts.CreatedAfter.IsZero() {
+			req.CreatedAfter = timestamppb.New(opts.CreatedAfter)
+		}
+	}
+	if serviceAccountID != uuid.Nil {
+		req.ServiceAccountId = serviceAccountID.String()
+	}
+
+	resp, err := t.client.ListAPIKeys(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]apikeysclient.APIKey, len(resp.GetApiKeys()))
+	for i, key := range resp.GetApiKeys() {
+		keys[i] = protocodec.FromProto(key)
+	}
+	*call.Output.(*[]apikeysclient.APIKey) = keys
+
+	call.Header.Set("X-Total-Count", strconv.FormatInt(resp.GetTotalCount(), 10))
+	if resp.GetNextCursor() != "" {
+		call.Header.Set("X-Next-Cursor", resp.GetNextCursor())
+	}
+
+	return nil
+}
+
+func setKey(call *apikeysclient.Call, key *apikeyspb.APIKey, err error) error {
+	if err != nil {
+		return err
+	}
+	*call.Output.(*apikeysclient.APIKey) = protocodec.FromProto(key)
+	return nil
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// httpStatus maps a gRPC status code to the HTTP status the REST API would
+// have returned, so errors match the same apikeysclient sentinels.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
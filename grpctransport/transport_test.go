@@ -0,0 +1,331 @@
+package grpctransport_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeyspb"
+	"github.com/PiccoloMondoC/apikeysclient/grpctransport"
+	"github.com/PiccoloMondoC/apikeysclient/protocodec"
+)
+
+// keysServer serves a single key over gRPC.
+type keysServer struct {
+	apikeyspb.UnimplementedAPIKeysServer
+
+	key   apikeysclient.APIKey
+	quota *apikeyspb.QuotaUsage
+
+	// md is the metadata of the last mutation.
+	md metadata.MD
+
+	// deleted is the last DeleteAPIKeyRequest.
+	deleted *apikeyspb.DeleteAPIKeyRequest
+}
+
+// matches reports whether the material or hash looked up is that of the key.
+func (s *keysServer) matches(apiKey, hash string) bool {
+	if hash != "" {
+		return hash == apikeysclient.HashAPIKey(s.key.APIKey)
+	}
+	return apiKey == s.key.APIKey
+}
+
+func (s *keysServer) CreateAPIKey(ctx context.Context, req *apikeyspb.CreateAPIKeyRequest) (*apikeyspb.APIKey, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	return req.GetApiKey(), nil
+}
+
+func (s *keysServer) UpdateAPIKey(_ context.Context, req *apikeyspb.UpdateAPIKeyRequest) (*apikeyspb.APIKey, error) {
+	if v := req.GetApiKey().GetVersion(); v != 0 && v != s.key.Version {
+		return nil, status.Errorf(codes.Aborted, "key is at version %d", s.key.Version)
+	}
+	s.key = protocodec.FromProto(req.GetApiKey())
+	s.key.Version++
+	return protocodec.ToProto(&s.key), nil
+}
+
+func (s *keysServer) RevokeAPIKey(ctx context.Context, _ *apikeyspb.RevokeAPIKeyRequest) (*apikeyspb.APIKey, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	return protocodec.ToProto(&s.key), nil
+}
+
+func (s *keysServer) ValidateAPIKey(_ context.Context, req *apikeyspb.ValidateAPIKeyRequest) (*apikeyspb.ValidateAPIKeyResponse, error) {
+	return &apikeyspb.ValidateAPIKeyResponse{IsValid: s.matches(req.GetApiKey(), req.GetKeyHash()), Quota: s.quota}, nil
+}
+
+func (s *keysServer) GetAPIKeyByKey(_ context.Context, req *apikeyspb.GetAPIKeyByKeyRequest) (*apikeyspb.APIKey, error) {
+	if !s.matches(req.GetApiKey(), req.GetKeyHash()) {
+		return nil, status.Error(codes.NotFound, "no such key")
+	}
+	return protocodec.ToProto(&s.key), nil
+}
+
+func (s *keysServer) SuspendAPIKey(context.Context, *apikeyspb.SuspendAPIKeyRequest) (*apikeyspb.APIKey, error) {
+	s.key.SetStatus(apikeysclient.KeySuspended)
+	return protocodec.ToProto(&s.key), nil
+}
+
+func (s *keysServer) ResumeAPIKey(context.Context, *apikeyspb.ResumeAPIKeyRequest) (*apikeyspb.APIKey, error) {
+	s.key.SetStatus(apikeysclient.KeyActive)
+	return protocodec.ToProto(&s.key), nil
+}
+
+func (s *keysServer) DeleteAPIKey(_ context.Context, req *apikeyspb.DeleteAPIKeyRequest) (*emptypb.Empty, error) {
+	s.deleted = req
+	return &emptypb.Empty{}, nil
+}
+
+// newClient returns a client talking to srv over an in-memory gRPC
+// connection.
+func newClient(t *testing.T, srv apikeyspb.APIKeysServer, opts ...apikeysclient.Option) *apikeysclient.Client {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	apikeyspb.RegisterAPIKeysServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return grpctransport.NewGRPCClient(conn, opts...)
+}
+
+func TestMiddlewareEnforcesRestrictions(t *testing.T) {
+	srv := &keysServer{key: apikeysclient.APIKey{
+		ID:     uuid.New(),
+		APIKey: "ak_restricted",
+		Restrictions: &apikeysclient.Restrictions{
+			AllowedCIDRs:   []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			AllowedMethods: []string{http.MethodGet},
+		},
+	}}
+	client := newClient(t, srv)
+
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name       string
+		method     string
+		remoteAddr string
+		want       int
+	}{
+		{"allowed", http.MethodGet, "10.1.2.3:1234", http.StatusOK},
+		{"other network", http.MethodGet, "192.0.2.1:1234", http.StatusForbidden},
+		{"other method", http.MethodPost, "10.1.2.3:1234", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(apikeysclient.DefaultKeyHeader, srv.key.APIKey)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []apikeysclient.Option
+		ctx  func(context.Context) context.Context
+		want []string
+	}{
+		{"default", nil, nil, nil},
+		{"WithDryRun", []apikeysclient.Option{apikeysclient.WithDryRun()}, nil, []string{"true"}},
+		{"ContextWithDryRun", nil, apikeysclient.ContextWithDryRun, []string{"true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &keysServer{key: apikeysclient.APIKey{ID: uuid.New()}}
+			client := newClient(t, srv, tt.opts...)
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+
+			if _, err := client.CreateKey(ctx, apikeysclient.CreateAPIKeyRequest{ServiceAccountID: uuid.New()}); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.md.Get("x-dry-run"); !slices.Equal(got, tt.want) {
+				t.Errorf("create x-dry-run = %q, want %q", got, tt.want)
+			}
+
+			if _, err := client.RevokeAPIKey(ctx, srv.key.ID); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.md.Get("x-dry-run"); !slices.Equal(got, tt.want) {
+				t.Errorf("revoke x-dry-run = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSendsVersion(t *testing.T) {
+	srv := &keysServer{key: apikeysclient.APIKey{ID: uuid.New(), Name: "before", Version: 3}}
+	client := newClient(t, srv)
+	ctx := context.Background()
+
+	stale := srv.key
+	stale.Version = 2
+	stale.Name = "stale"
+	if _, err := client.UpdateAPIKey(ctx, &stale); !errors.Is(err, apikeysclient.ErrConflict) {
+		t.Fatalf("UpdateAPIKey(stale) error = %v, want ErrConflict", err)
+	}
+
+	current := srv.key
+	current.Name = "after"
+	updated, err := client.UpdateAPIKey(ctx, &current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "after" || updated.Version != 4 {
+		t.Errorf("updated key = %q at version %d, want %q at version 4", updated.Name, updated.Version, "after")
+	}
+}
+
+func TestMiddlewareEnforcesQuota(t *testing.T) {
+	srv := &keysServer{
+		key: apikeysclient.APIKey{ID: uuid.New(), APIKey: "ak_metered"},
+		quota: &apikeyspb.QuotaUsage{
+			Limit:    10,
+			Period:   string(apikeysclient.QuotaPerHour),
+			Used:     11,
+			ResetsAt: timestamppb.New(time.Now().Add(time.Hour)),
+		},
+	}
+	client := newClient(t, srv)
+
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), apikeysclient.WithQuotaEnforcement(true))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(apikeysclient.DefaultKeyHeader, srv.key.APIKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestActorMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []apikeysclient.Option
+		ctx  func(context.Context) context.Context
+		want []string
+	}{
+		{"none", nil, nil, nil},
+		{"WithActor", []apikeysclient.Option{apikeysclient.WithActor("alice@example.com")}, nil, []string{"alice@example.com"}},
+		{"ContextWithActor", []apikeysclient.Option{apikeysclient.WithActor("alice@example.com")}, func(ctx context.Context) context.Context {
+			return apikeysclient.ContextWithActor(ctx, "bob@example.com")
+		}, []string{"bob@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &keysServer{key: apikeysclient.APIKey{ID: uuid.New()}}
+			client := newClient(t, srv, tt.opts...)
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+
+			if _, err := client.RevokeAPIKey(ctx, srv.key.ID); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.md.Get(strings.ToLower(apikeysclient.ActorHeader)); !slices.Equal(got, tt.want) {
+				t.Errorf("actor metadata = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashedKeys(t *testing.T) {
+	srv := &keysServer{key: apikeysclient.APIKey{ID: uuid.New(), APIKey: "ak_hashed"}}
+	client := newClient(t, srv, apikeysclient.WithHashedKeys())
+
+	handler := client.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"known key", srv.key.APIKey, http.StatusOK},
+		{"unknown key", "ak_unknown", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(apikeysclient.DefaultKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyStatusCalls(t *testing.T) {
+	srv := &keysServer{key: apikeysclient.APIKey{ID: uuid.New(), APIKey: "ak_status", Status: apikeysclient.KeyActive}}
+	client := newClient(t, srv)
+	ctx := context.Background()
+
+	key, err := client.SuspendAPIKey(ctx, srv.key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Status != apikeysclient.KeySuspended {
+		t.Errorf("suspended key has status %q", key.Status)
+	}
+	if key, err = client.ResumeAPIKey(ctx, srv.key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if key.Status != apikeysclient.KeyActive {
+		t.Errorf("resumed key has status %q", key.Status)
+	}
+
+	for _, purge := range []bool{false, true} {
+		remove := client.DeleteAPIKey
+		if purge {
+			remove = client.PurgeAPIKey
+		}
+		if err := remove(ctx, srv.key.ID); err != nil {
+			t.Fatal(err)
+		}
+		if srv.deleted.GetId() != srv.key.ID.String() || srv.deleted.GetPurge() != purge {
+			t.Errorf("delete request = %v, want purge %v", srv.deleted, purge)
+		}
+	}
+}
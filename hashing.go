@@ -0,0 +1,52 @@
+package apikeysclient
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// generatedKeyBytes is the amount of randomness in keys from GenerateAPIKey.
+const generatedKeyBytes = 32
+
+// HashAPIKey returns the hex-encoded SHA-256 digest of apiKey. It is the form
+// in which keys are sent in hashed-key mode and matched in revocations, and
+// the client's caches are keyed by it so plaintext secrets are not retained
+// in memory.
+func HashAPIKey(apiKey string) string {
+	// Typical keys are hashed and encoded in stack buffers, so the digest
+	// string is the only allocation of a validation served from the cache.
+	var in [128]byte
+	sum := sha256.Sum256(append(in[:0], apiKey...))
+	var out [2 * sha256.Size]byte
+	hex.Encode(out[:], sum[:])
+	return string(out[:])
+}
+
+// GenerateAPIKey returns new random key material, for creating keys whose
+// secret never leaves the client. Pass it in CreateAPIKeyRequest.Secret to
+// CreateKey on a client using WithHashedKeys; only its hash is sent.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, generatedKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// WithHashedKeys makes the client send the HashAPIKey digest of key material
+// instead of the key itself. Lookups and validations are POSTed to
+// /apikeys/lookup and /apikeys/validate with the hash in the body, so secrets
+// never appear in URLs, and CreateKey sends the hash of the supplied key as
+// KeyHash for the server to store in place of the secret.
+func WithHashedKeys() Option {
+	return func(c *Client, _ *options) {
+		c.hashedKeys = true
+	}
+}
+
+type keyHashRequest struct {
+	KeyHash string `json:"key_hash"`
+}
@@ -0,0 +1,89 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrUnhealthy is returned by Health and Ping when the server reports that
+// it or one of its dependencies is not healthy.
+var ErrUnhealthy = errors.New("keys server unhealthy")
+
+// HealthOK is the Status of a healthy server or dependency.
+const HealthOK = "ok"
+
+// HealthStatus is the state reported by the server's health endpoint.
+type HealthStatus struct {
+	// Status is HealthOK when the server can serve requests.
+	Status  string `json:"status"`
+	Version string `json:"version,omitempty"`
+
+	// UptimeSeconds is how long the server has been running.
+	UptimeSeconds float64 `json:"uptime_seconds,omitempty"`
+
+	// Dependencies reports the health of the services the server relies
+	// on, such as its database, by name.
+	Dependencies map[string]DependencyHealth `json:"dependencies,omitempty"`
+}
+
+// DependencyHealth is the health of one dependency of the server.
+type DependencyHealth struct {
+	Status string `json:"status"`
+
+	// Error describes the failure of an unhealthy dependency.
+	Error string `json:"error,omitempty"`
+}
+
+// Uptime returns UptimeSeconds as a duration.
+func (s *HealthStatus) Uptime() time.Duration {
+	return time.Duration(s.UptimeSeconds * float64(time.Second))
+}
+
+// Healthy reports whether the server and all its dependencies are healthy.
+func (s *HealthStatus) Healthy() bool {
+	if s.Status != HealthOK {
+		return false
+	}
+	for _, dep := range s.Dependencies {
+		if dep.Status != HealthOK {
+			return false
+		}
+	}
+	return true
+}
+
+// Health fetches the server's status from its /healthz endpoint. When the
+// server answers but is not healthy, the call fails with ErrUnhealthy and
+// returns the reported status if there is one; other errors mean the server
+// could not be reached.
+func (c *Client) Health(ctx context.Context) (*HealthStatus, error) {
+	var status HealthStatus
+	_, err := c.do(ctx, &request{
+		op:     "Health",
+		method: http.MethodGet,
+		url:    c.endpoint("healthz"),
+	}, &status, http.StatusOK, http.StatusServiceUnavailable)
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable:
+		// Reported by transports, which have no body to decode.
+		return nil, fmt.Errorf("%w: %w", ErrUnhealthy, err)
+	case err != nil && !errors.Is(err, errEmptyBody):
+		return nil, err
+	}
+
+	if !status.Healthy() {
+		return &status, fmt.Errorf("%w: status %q", ErrUnhealthy, status.Status)
+	}
+	return &status, nil
+}
+
+// Ping reports whether the keys server is reachable and healthy, for use in
+// readiness probes.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Health(ctx)
+	return err
+}
@@ -0,0 +1,96 @@
+package apikeysclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader is the request header carrying idempotency keys.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyContextKey struct{}
+
+// idempotencyScope is the idempotency key set on a context and the calls
+// made with it.
+type idempotencyScope struct {
+	key string
+
+	mu sync.Mutex
+	// calls identifies the distinct mutating calls made with the context,
+	// in order; see idempotencyKey.
+	calls []string
+}
+
+// keyFor returns the idempotency key of call: the scope's key for its first
+// call, and the key suffixed with the position of the call for later ones.
+// Repeating a call gets it the same key again.
+func (s *idempotencyScope) keyFor(call string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.calls, call)
+	if i < 0 {
+		i = len(s.calls)
+		s.calls = append(s.calls, call)
+	}
+	if i == 0 {
+		return s.key
+	}
+	// The dot keeps these apart from the keys of itemContext.
+	return fmt.Sprintf("%s.%d", s.key, i)
+}
+
+// ContextWithIdempotencyKey returns a context making the next create, rotate
+// or delete call made with it use key as its idempotency key instead of a
+// generated one. Repeating that call with the context, from your own code,
+// sends the same key again, which lets the server recognize the retry and
+// return the original result instead of applying the mutation twice.
+//
+// The key belongs to that one call: any other mutating call made with the
+// context, identified by its method and arguments, is sent with a key derived
+// from key, so it is not taken for a retry of the first. Batch calls such as
+// CreateAPIKeys likewise derive one key per item.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, &idempotencyScope{key: key})
+}
+
+// WithIdempotencyKeys sets whether the client generates an idempotency key for
+// each create, rotate and delete call that has none in its context. It is
+// enabled by default. Calls with an idempotency key are retried by the retry
+// policy even when their method is not idempotent, since the server
+// deduplicates them.
+func WithIdempotencyKeys(enabled bool) Option {
+	return func(c *Client, _ *options) {
+		c.noIdempotencyKeys = !enabled
+	}
+}
+
+// idempotencyKey returns the idempotency key of the mutating call op made
+// with ctx and input in, or "" if it should be sent without one.
+func (c *Client) idempotencyKey(ctx context.Context, op string, in any) string {
+	if scope, ok := ctx.Value(idempotencyKeyContextKey{}).(*idempotencyScope); ok {
+		call, err := json.Marshal(in)
+		if err != nil {
+			call = fmt.Appendf(nil, "%#v", in)
+		}
+		return scope.keyFor(op + " " + string(call))
+	}
+	if c.noIdempotencyKeys {
+		return ""
+	}
+	return uuid.NewString()
+}
+
+// itemContext returns the context for the i-th call of a batch made with
+// ctx. A caller-supplied idempotency key is suffixed with the item index so
+// the calls are not mistaken for retries of each other.
+func itemContext(ctx context.Context, i int) context.Context {
+	if scope, ok := ctx.Value(idempotencyKeyContextKey{}).(*idempotencyScope); ok {
+		return ContextWithIdempotencyKey(ctx, fmt.Sprintf("%s-%d", scope.key, i))
+	}
+	return ctx
+}
@@ -0,0 +1,62 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// TestContextIdempotencyKeyScopedToOneCall checks that a context's
+// idempotency key is sent with the first call made with it and its retries
+// only, not with other calls made with the same context.
+func TestContextIdempotencyKeyScopedToOneCall(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var key apikeysclient.APIKey
+		json.NewDecoder(r.Body).Decode(&key)
+		mu.Lock()
+		keys = append(keys, r.Header.Get(apikeysclient.IdempotencyKeyHeader))
+		mu.Unlock()
+		key.ID = uuid.New()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key)
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := apikeysclient.ContextWithIdempotencyKey(context.Background(), "create")
+	account := uuid.New()
+
+	for _, name := range []string{"first", "second", "first"} {
+		if _, err := client.CreateAPIKey(ctx, apikeysclient.APIKey{Name: name, ServiceAccountID: account}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(keys) != 3 {
+		t.Fatalf("got %d requests, want 3", len(keys))
+	}
+	if keys[0] != "create" {
+		t.Errorf("first call sent key %q, want %q", keys[0], "create")
+	}
+	if keys[1] == "" || keys[1] == keys[0] {
+		t.Errorf("second call sent key %q, want one of its own", keys[1])
+	}
+	if keys[2] != keys[0] {
+		t.Errorf("repeated first call sent key %q, want %q", keys[2], keys[0])
+	}
+}
@@ -0,0 +1,49 @@
+package apikeysclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequestInterceptor is called with every outgoing request, after the client
+// has set its own headers and before the request is sent. Returning an error
+// aborts the call.
+type RequestInterceptor func(req *http.Request) error
+
+// ResponseInterceptor is called with every response before the client
+// inspects it. Returning an error aborts the call.
+type ResponseInterceptor func(resp *http.Response) error
+
+// WithRequestInterceptor appends interceptors to the chain run on outgoing
+// requests, in order. Interceptors run again for every retry attempt.
+func WithRequestInterceptor(interceptors ...RequestInterceptor) Option {
+	return func(c *Client, _ *options) {
+		c.requestInterceptors = append(c.requestInterceptors, interceptors...)
+	}
+}
+
+// WithResponseInterceptor appends interceptors to the chain run on
+// responses, in order.
+func WithResponseInterceptor(interceptors ...ResponseInterceptor) Option {
+	return func(c *Client, _ *options) {
+		c.responseInterceptors = append(c.responseInterceptors, interceptors...)
+	}
+}
+
+func (c *Client) interceptRequest(req *http.Request) error {
+	for _, intercept := range c.requestInterceptors {
+		if err := intercept(req); err != nil {
+			return fmt.Errorf("request interceptor: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) interceptResponse(resp *http.Response) error {
+	for _, intercept := range c.responseInterceptors {
+		if err := intercept(resp); err != nil {
+			return fmt.Errorf("response interceptor: %w", err)
+		}
+	}
+	return nil
+}
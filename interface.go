@@ -0,0 +1,82 @@
+package apikeysclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeysClient is the set of keys service calls made by Client. Depend on it
+// instead of *Client to substitute a stub in tests; apikeysclienttest.Fake
+// provides a ready-made in-memory implementation through its Client method.
+//
+// Local helpers that do not call the service, such as Middleware,
+// ListAPIKeysIter, WatchRevocations and ReportUsage, are only available on *Client.
+type APIKeysClient interface {
+	CreateKey(ctx context.Context, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	CreateAPIKey(ctx context.Context, apiKey APIKey) (APIKey, error)
+	CreateAPIKeyWithExpiry(ctx context.Context, apiKey APIKey, expiresAt time.Time) (APIKey, error)
+	CreateAPIKeys(ctx context.Context, reqs []APIKeyRequest) ([]CreateAPIKeyResult, error)
+	CreateEphemeralKey(ctx context.Context, serviceAccountID uuid.UUID, ttl time.Duration, scopes []string) (*APIKey, error)
+
+	GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	GetAPIKeysByIDs(ctx context.Context, ids []uuid.UUID, concurrency int) (map[uuid.UUID]GetAPIKeyResult, error)
+	GetAPIKeyByAPIKey(ctx context.Context, apiKey string) (*APIKey, error)
+	RevealAPIKey(ctx context.Context, id uuid.UUID) (string, error)
+	LookupAPIKey(ctx context.Context, apiKey string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	ListAPIKeysPage(ctx context.Context, opts *ListAPIKeysOptions) (*APIKeyPage, error)
+	ListAPIKeysStream(ctx context.Context, opts *ListAPIKeysOptions) (*APIKeyStream, error)
+	ListAPIKeysByServiceAccount(ctx context.Context, serviceAccountID uuid.UUID, opts *ListAPIKeysOptions) (*APIKeyPage, error)
+	SearchAPIKeys(ctx context.Context, query string, opts *SearchOptions) (*SearchResults, error)
+
+	UpdateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error)
+	PatchAPIKey(ctx context.Context, id uuid.UUID, patch APIKeyPatch) (*APIKey, error)
+	RotateAPIKey(ctx context.Context, id uuid.UUID) (*RotateAPIKeyResponse, error)
+	RotateAPIKeys(ctx context.Context, ids []uuid.UUID) (*Operation, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	ActivateAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	SuspendAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	ResumeAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	ExtendExpiry(ctx context.Context, id uuid.UUID, newExpiry time.Time) (*APIKey, error)
+	GetRestrictions(ctx context.Context, id uuid.UUID) (*Restrictions, error)
+	SetRestrictions(ctx context.Context, id uuid.UUID, restrictions Restrictions) (*APIKey, error)
+	SetKeyQuota(ctx context.Context, id uuid.UUID, quota Quota) (*APIKey, error)
+	GetKeyQuotaUsage(ctx context.Context, id uuid.UUID) (*QuotaUsage, error)
+
+	GetAPIKeyUsage(ctx context.Context, id uuid.UUID, from, to time.Time) (*APIKeyUsage, error)
+	ListStaleKeys(ctx context.Context, olderThan time.Duration) ([]APIKey, error)
+	ListAuditEvents(ctx context.Context, opts *ListAuditEventsOptions) (*AuditEventPage, error)
+
+	DeleteAPIKey(ctx context.Context, id uuid.UUID) error
+	PurgeAPIKey(ctx context.Context, id uuid.UUID) error
+	DeleteAPIKeys(ctx context.Context, ids []uuid.UUID) ([]DeleteAPIKeyResult, error)
+
+	ExchangeForToken(ctx context.Context, apiKey string) (*Token, error)
+	VerifyToken(ctx context.Context, token string) (*TokenClaims, error)
+
+	CreateServiceAccount(ctx context.Context, account ServiceAccount) (*ServiceAccount, error)
+	GetServiceAccount(ctx context.Context, id uuid.UUID) (*ServiceAccount, error)
+	ListServiceAccounts(ctx context.Context) ([]ServiceAccount, error)
+	ListTenantServiceAccounts(ctx context.Context, tenant Tenant) ([]ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, id uuid.UUID, opts *DeleteServiceAccountOptions) error
+
+	Health(ctx context.Context) (*HealthStatus, error)
+	GetVersion(ctx context.Context) (*ServerVersion, error)
+	GetOperation(ctx context.Context, id string) (*Operation, error)
+	Ping(ctx context.Context) error
+
+	CreateWebhook(ctx context.Context, hook Webhook) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+
+	ValidateAPIKey(ctx context.Context, apiKey string) (bool, error)
+	ValidateAPIKeys(ctx context.Context, keys []string, concurrency int) (map[string]bool, error)
+	ValidateAPIKeyPOST(ctx context.Context, apiKey string) (bool, error)
+	ValidateAPIKeyWithScopes(ctx context.Context, apiKey string, requiredScopes ...string) (bool, error)
+
+	Capabilities(ctx context.Context) (*Capabilities, error)
+}
+
+var _ APIKeysClient = (*Client)(nil)
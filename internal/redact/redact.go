@@ -0,0 +1,127 @@
+// Package redact removes secret material from requests and responses before
+// they are logged, traced or recorded.
+package redact
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces secret material in logged or traced values.
+const Redacted = "REDACTED"
+
+// Path hides the key material in paths of the form .../apikeys/key/{key}/...
+func Path(p string) string {
+	segments := strings.Split(p, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1] == "key" && segments[i] != "" {
+			segments[i] = Redacted
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// IsSecretName reports whether a header, query parameter or JSON field
+// called name is likely to hold secret material.
+func IsSecretName(name string) bool {
+	n := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+	switch n {
+	case "authorization", "proxyauthorization", "cookie", "setcookie", "key":
+		return true
+	}
+	for _, word := range []string{"apikey", "secret", "token", "password", "signature"} {
+		if strings.Contains(n, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns a copy of u with key material removed from its path and
+// secret query parameters.
+func URL(u *url.URL) *url.URL {
+	r := *u
+	r.User = nil
+	r.RawPath = ""
+	r.Path = Path(u.Path)
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name := range q {
+			if IsSecretName(name) {
+				q[name] = []string{Redacted}
+			}
+		}
+		r.RawQuery = q.Encode()
+	}
+	return &r
+}
+
+// Header returns a copy of h with secret header values replaced.
+func Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		if IsSecretName(name) {
+			out[name] = []string{Redacted}
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// FlatHeader is Header with the values of each header joined, as logged.
+func FlatHeader(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range Header(h) {
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// Body returns body with the values of secret JSON fields replaced. Bodies
+// that are not JSON are dropped entirely since they cannot be inspected.
+func Body(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	out, ok := JSON(body)
+	if !ok {
+		return Redacted
+	}
+	return string(out)
+}
+
+// JSON returns the JSON document body with the values of secret fields
+// replaced, and whether body is JSON.
+func JSON(body []byte) ([]byte, bool) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, false
+	}
+
+	out, err := json.Marshal(value(v))
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, field := range v {
+			if _, isString := field.(string); isString && IsSecretName(name) {
+				v[name] = Redacted
+				continue
+			}
+			v[name] = value(field)
+		}
+	case []any:
+		for i := range v {
+			v[i] = value(v[i])
+		}
+	}
+	return v
+}
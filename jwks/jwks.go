@@ -0,0 +1,246 @@
+// Package jwks fetches and caches the JSON Web Key Set of a keys server and
+// verifies the tokens it signs. It has no dependency on the rest of the
+// module, so services that only consume tokens can use it on its own:
+//
+//	keys := jwks.New("https://keys.example.com/.well-known/jwks.json")
+//	payload, err := keys.Verify(ctx, token)
+//
+// Keys are refetched once the set is older than the refresh interval, and
+// early when a token names a key the cached set does not contain, so
+// servers can rotate signing keys without coordinating with consumers.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by Verify.
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnknownKey       = errors.New("token signed with unknown key")
+	ErrInvalidSignature = errors.New("invalid token signature")
+)
+
+// Default intervals of a Cache.
+const (
+	DefaultRefreshInterval    = time.Hour
+	DefaultMinRefreshInterval = time.Minute
+)
+
+// maxKeySetSize bounds the JWKS response body read by the HTTP fetcher.
+const maxKeySetSize = 1 << 20
+
+// FetchFunc retrieves the current key set.
+type FetchFunc func(ctx context.Context) (*KeySet, error)
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithHTTPClient fetches the key set with hc instead of
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Cache) {
+		c.httpClient = hc
+	}
+}
+
+// WithFetcher retrieves the key set with fetch instead of an HTTP GET of the
+// cache's URL.
+func WithFetcher(fetch FetchFunc) Option {
+	return func(c *Cache) {
+		c.fetch = fetch
+	}
+}
+
+// WithRefreshInterval sets how long a fetched key set is used before it is
+// fetched again. It defaults to DefaultRefreshInterval.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(c *Cache) {
+		c.refreshInterval = d
+	}
+}
+
+// WithMinRefreshInterval sets the minimum time between fetches triggered by
+// tokens naming unknown keys, so forged tokens cannot flood the server. It
+// defaults to DefaultMinRefreshInterval.
+func WithMinRefreshInterval(d time.Duration) Option {
+	return func(c *Cache) {
+		c.minRefreshInterval = d
+	}
+}
+
+type cachedKey struct {
+	public    crypto.PublicKey
+	algorithm string
+}
+
+// Cache holds the keys of a JWKS endpoint. It is safe for concurrent use.
+type Cache struct {
+	url                string
+	httpClient         *http.Client
+	fetch              FetchFunc
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]cachedKey
+	fetchedAt time.Time
+}
+
+// New returns a Cache of the key set served at url. Nothing is fetched
+// until a key is needed.
+func New(url string, opts ...Option) *Cache {
+	c := &Cache{
+		url:                url,
+		httpClient:         http.DefaultClient,
+		refreshInterval:    DefaultRefreshInterval,
+		minRefreshInterval: DefaultMinRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.fetch == nil {
+		c.fetch = c.fetchHTTP
+	}
+	return c
+}
+
+// Key returns the public key with the given ID and its algorithm, if the
+// set declares one. It fails with ErrUnknownKey when the set does not
+// contain the key, even after a refresh.
+func (c *Cache) Key(ctx context.Context, kid string) (crypto.PublicKey, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stale := c.keys == nil || now.Sub(c.fetchedAt) >= c.refreshInterval
+	key, ok := c.keys[kid]
+	if !ok && now.Sub(c.fetchedAt) >= c.minRefreshInterval {
+		stale = true
+	}
+
+	if stale {
+		if err := c.refresh(ctx); err != nil {
+			// A stale set is better than none while the server is down.
+			if c.keys == nil {
+				return nil, "", err
+			}
+		} else {
+			key, ok = c.keys[kid]
+		}
+	}
+
+	if !ok {
+		return nil, "", fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key.public, key.algorithm, nil
+}
+
+// Refresh fetches the key set now.
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.refresh(ctx)
+}
+
+// refresh fetches the key set. Keys it cannot decode are skipped. c.mu
+// must be held.
+func (c *Cache) refresh(ctx context.Context) error {
+	// Failed fetches count against the minimum interval too.
+	c.fetchedAt = time.Now()
+
+	set, err := c.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]cachedKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if public, err := k.PublicKey(); err == nil {
+			keys[k.KeyID] = cachedKey{public: public, algorithm: k.Algorithm}
+		}
+	}
+	c.keys = keys
+	return nil
+}
+
+func (c *Cache) fetchHTTP(ctx context.Context) (*KeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var set KeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxKeySetSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode key set: %w", err)
+	}
+	return &set, nil
+}
+
+// Verify checks the signature of the compact JWS token with the key named
+// by its kid header and returns its decoded payload. Claims such as the
+// expiry are not checked; that is up to the caller.
+func (c *Cache) Verify(ctx context.Context, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	b64 := base64.RawURLEncoding
+	rawHeader, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	key, alg, err := c.Key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if alg != "" && alg != header.Algorithm {
+		return nil, ErrInvalidSignature
+	}
+	if err := verifySignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
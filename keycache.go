@@ -0,0 +1,471 @@
+package apikeysclient
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyCacheConfig configures the cache of key records returned by
+// GetAPIKeyByID and GetAPIKeyByAPIKey.
+type KeyCacheConfig struct {
+	// TTL is how long a record is served from the cache without asking the
+	// server. When it is zero, or once it has passed, GET lookups are sent as
+	// conditional requests with the record's ETag, so an unchanged record
+	// costs a 304 response instead of a body.
+	TTL time.Duration
+
+	// MaxEntries bounds the number of cached records; the least recently
+	// used entry is evicted when it is exceeded. Zero means no limit.
+	MaxEntries int
+
+	// Cache stores the records instead of process memory when set, in which
+	// case MaxEntries does not apply. Records are kept past TTL for
+	// Retention, to be revalidated or served stale.
+	Cache Cache
+
+	// Retention is how long records are kept in Cache once TTL has passed.
+	// Zero keeps them for an hour, or for the MaxStaleness of the client's
+	// DegradationPolicy when that is longer.
+	Retention time.Duration
+}
+
+// defaultKeyCacheRetention is the default KeyCacheConfig Retention.
+const defaultKeyCacheRetention = time.Hour
+
+// WithKeyCache caches key records according to cfg. Records are dropped
+// when the client changes or deletes the key, and by Invalidate. Changes
+// made by other clients are seen once TTL has passed.
+func WithKeyCache(cfg KeyCacheConfig) Option {
+	return func(c *Client, _ *options) {
+		if cfg.Cache != nil {
+			c.keyCache = &sharedKeyCache{cfg: cfg, c: c}
+			return
+		}
+		c.keyCache = newKeyCache(cfg)
+	}
+}
+
+// keyStore holds the records of the key cache, each stored under the name
+// of the lookup that returned it ("id:<id>" or "hash:<key hash>").
+type keyStore interface {
+	// get returns a copy of the entry stored under name, if any, and
+	// whether it is fresh enough to be used without asking the server.
+	get(ctx context.Context, name string) (entry keyCacheEntry, fresh, ok bool)
+
+	// set stores key under name with its ETag.
+	set(ctx context.Context, name string, key APIKey, etag string)
+
+	// touch restarts the TTL of the entry stored under name after the
+	// server confirmed it is unchanged.
+	touch(ctx context.Context, name string)
+
+	// delete drops the entry stored under name.
+	delete(ctx context.Context, name string)
+
+	// deleteKey drops every entry holding the record of the key with the
+	// given id, whichever lookup stored it, and returns the key's hashes
+	// the entries were stored or found under.
+	deleteKey(ctx context.Context, id uuid.UUID) (hashes []string)
+
+	// deleteServiceAccount drops the records of every key of the service
+	// account with the given id and returns the keys' hashes, as
+	// deleteKey does.
+	deleteServiceAccount(ctx context.Context, id uuid.UUID) (hashes []string)
+}
+
+type keyCacheEntry struct {
+	name    string
+	key     APIKey
+	etag    string
+	expires time.Time
+}
+
+// keyCache is an in-memory LRU cache of key records. It is safe for
+// concurrent use.
+type keyCache struct {
+	cfg KeyCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newKeyCache(cfg KeyCacheConfig) *keyCache {
+	return &keyCache{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (kc *keyCache) get(_ context.Context, name string) (entry keyCacheEntry, fresh, ok bool) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	el, ok := kc.entries[name]
+	if !ok {
+		return keyCacheEntry{}, false, false
+	}
+	kc.lru.MoveToFront(el)
+
+	entry = *el.Value.(*keyCacheEntry)
+	entry.key = entry.key.clone()
+	return entry, time.Now().Before(entry.expires), true
+}
+
+func (kc *keyCache) set(_ context.Context, name string, key APIKey, etag string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	entry := &keyCacheEntry{name: name, key: key.clone(), etag: etag, expires: time.Now().Add(kc.cfg.TTL)}
+	if el, ok := kc.entries[name]; ok {
+		el.Value = entry
+		kc.lru.MoveToFront(el)
+		return
+	}
+
+	kc.entries[name] = kc.lru.PushFront(entry)
+	if kc.cfg.MaxEntries > 0 && kc.lru.Len() > kc.cfg.MaxEntries {
+		kc.removeElement(kc.lru.Back())
+	}
+}
+
+func (kc *keyCache) touch(_ context.Context, name string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if el, ok := kc.entries[name]; ok {
+		el.Value.(*keyCacheEntry).expires = time.Now().Add(kc.cfg.TTL)
+	}
+}
+
+func (kc *keyCache) delete(_ context.Context, name string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if el, ok := kc.entries[name]; ok {
+		kc.removeElement(el)
+	}
+}
+
+func (kc *keyCache) deleteKey(_ context.Context, id uuid.UUID) (hashes []string) {
+	return kc.deleteFunc(func(k *APIKey) bool { return k.ID == id })
+}
+
+func (kc *keyCache) deleteServiceAccount(_ context.Context, id uuid.UUID) (hashes []string) {
+	return kc.deleteFunc(func(k *APIKey) bool { return k.ServiceAccountID == id })
+}
+
+// deleteFunc drops every entry whose record matches del and returns the
+// hashes of the keys they were stored or found under.
+func (kc *keyCache) deleteFunc(del func(*APIKey) bool) (hashes []string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	for el := kc.lru.Front(); el != nil; {
+		next := el.Next()
+		if entry := el.Value.(*keyCacheEntry); del(&entry.key) {
+			kc.removeElement(el)
+			if hash, ok := strings.CutPrefix(entry.name, "hash:"); ok {
+				hashes = append(hashes, hash)
+			}
+			if entry.key.KeyHash != "" {
+				hashes = append(hashes, entry.key.KeyHash)
+			}
+		}
+		el = next
+	}
+	return hashes
+}
+
+func (kc *keyCache) removeElement(el *list.Element) {
+	kc.lru.Remove(el)
+	delete(kc.entries, el.Value.(*keyCacheEntry).name)
+}
+
+// sharedKeyCacheEntry is the form of a key record in a Cache.
+type sharedKeyCacheEntry struct {
+	Key        APIKey    `json:"key"`
+	ETag       string    `json:"etag,omitempty"`
+	FreshUntil time.Time `json:"fresh_until"`
+}
+
+// sharedKeyCache keeps key records in a Cache, under "key:<name>". The name
+// of the hash lookup of each key is kept under "key:ref:<id>" so that
+// deleteKey finds it, and the IDs of the keys of each service account under
+// "key:account:<id>" for deleteServiceAccount. Updates of the latter from
+// concurrent clients may be lost, leaving records of a deleted account to
+// expire with their TTL.
+type sharedKeyCache struct {
+	cfg KeyCacheConfig
+	c   *Client
+}
+
+func (kc *sharedKeyCache) get(ctx context.Context, name string) (entry keyCacheEntry, fresh, ok bool) {
+	shared, ok := kc.load(ctx, name)
+	if !ok {
+		return keyCacheEntry{}, false, false
+	}
+	entry = keyCacheEntry{name: name, key: shared.Key, etag: shared.ETag, expires: shared.FreshUntil}
+	return entry, time.Now().Before(entry.expires), true
+}
+
+func (kc *sharedKeyCache) load(ctx context.Context, name string) (sharedKeyCacheEntry, bool) {
+	var entry sharedKeyCacheEntry
+	b, ok, err := kc.cfg.Cache.Get(ctx, "key:"+name)
+	if err != nil {
+		kc.c.logCacheError(ctx, "get", err)
+		return entry, false
+	}
+	if !ok {
+		return entry, false
+	}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		kc.c.logCacheError(ctx, "decode", err)
+		return entry, false
+	}
+	return entry, true
+}
+
+func (kc *sharedKeyCache) store(ctx context.Context, name string, entry sharedKeyCacheEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		kc.c.logCacheError(ctx, "encode", err)
+		return
+	}
+	if err := kc.cfg.Cache.Set(ctx, "key:"+name, b, kc.ttl(ctx)); err != nil {
+		kc.c.logCacheError(ctx, "set", err)
+	}
+}
+
+// ttl returns how long entries are stored in the Cache.
+func (kc *sharedKeyCache) ttl(ctx context.Context) time.Duration {
+	retention := kc.cfg.Retention
+	if retention <= 0 {
+		retention = max(defaultKeyCacheRetention, kc.c.degradationPolicy(ctx).MaxStaleness)
+	}
+	return kc.cfg.TTL + retention
+}
+
+func (kc *sharedKeyCache) set(ctx context.Context, name string, key APIKey, etag string) {
+	kc.store(ctx, name, sharedKeyCacheEntry{Key: key, ETag: etag, FreshUntil: time.Now().Add(kc.cfg.TTL)})
+	if key.ID == uuid.Nil {
+		return
+	}
+	if name != "id:"+key.ID.String() {
+		if err := kc.cfg.Cache.Set(ctx, "key:ref:"+key.ID.String(), []byte(name), kc.ttl(ctx)); err != nil {
+			kc.c.logCacheError(ctx, "set", err)
+		}
+	}
+	if key.ServiceAccountID != uuid.Nil {
+		kc.index(ctx, key.ServiceAccountID, key.ID)
+	}
+}
+
+// index adds the key with the given id to the keys of its service account.
+func (kc *sharedKeyCache) index(ctx context.Context, account, id uuid.UUID) {
+	ids := kc.accountKeys(ctx, account)
+	if slices.Contains(ids, id) {
+		return
+	}
+	b, _ := json.Marshal(append(ids, id))
+	if err := kc.cfg.Cache.Set(ctx, "key:account:"+account.String(), b, kc.ttl(ctx)); err != nil {
+		kc.c.logCacheError(ctx, "set", err)
+	}
+}
+
+// accountKeys returns the IDs of the cached keys of the service account
+// with the given id.
+func (kc *sharedKeyCache) accountKeys(ctx context.Context, account uuid.UUID) []uuid.UUID {
+	b, ok, err := kc.cfg.Cache.Get(ctx, "key:account:"+account.String())
+	if err != nil {
+		kc.c.logCacheError(ctx, "get", err)
+	}
+	if !ok {
+		return nil
+	}
+	var ids []uuid.UUID
+	if err := json.Unmarshal(b, &ids); err != nil {
+		kc.c.logCacheError(ctx, "decode", err)
+		return nil
+	}
+	return ids
+}
+
+func (kc *sharedKeyCache) touch(ctx context.Context, name string) {
+	if entry, ok := kc.load(ctx, name); ok {
+		entry.FreshUntil = time.Now().Add(kc.cfg.TTL)
+		kc.store(ctx, name, entry)
+	}
+}
+
+func (kc *sharedKeyCache) delete(ctx context.Context, name string) {
+	kc.remove(ctx, name)
+}
+
+// remove drops the entries stored under names.
+func (kc *sharedKeyCache) remove(ctx context.Context, names ...string) {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = "key:" + name
+	}
+	if err := kc.cfg.Cache.Delete(ctx, keys...); err != nil {
+		kc.c.logCacheError(ctx, "delete", err)
+	}
+}
+
+func (kc *sharedKeyCache) deleteKey(ctx context.Context, id uuid.UUID) (hashes []string) {
+	names := []string{"id:" + id.String(), "ref:" + id.String()}
+	ref, ok, err := kc.cfg.Cache.Get(ctx, "key:ref:"+id.String())
+	if err != nil {
+		kc.c.logCacheError(ctx, "get", err)
+	}
+	if ok {
+		names = append(names, string(ref))
+		if hash, ok := strings.CutPrefix(string(ref), "hash:"); ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	kc.remove(ctx, names...)
+	return hashes
+}
+
+func (kc *sharedKeyCache) deleteServiceAccount(ctx context.Context, id uuid.UUID) (hashes []string) {
+	for _, key := range kc.accountKeys(ctx, id) {
+		hashes = append(hashes, kc.deleteKey(ctx, key)...)
+	}
+	kc.remove(ctx, "account:"+id.String())
+	return hashes
+}
+
+// getKey performs the lookup r, whose result is cached under name. Fresh
+// records are returned without a request; stale ones are revalidated with
+// If-None-Match when r is a GET sent over REST.
+func (c *Client) getKey(ctx context.Context, name string, r *request) (*APIKey, error) {
+	var key APIKey
+	if c.keyCache == nil {
+		if _, err := c.do(ctx, r, &key); err != nil {
+			return nil, err
+		}
+		return &key, nil
+	}
+
+	cached, fresh, ok := c.keyCache.get(ctx, name)
+	c.observeCache(CacheKey, ok && fresh)
+	if ok && fresh {
+		return &cached.key, nil
+	}
+
+	if c.transport != nil || r.method != http.MethodGet {
+		if _, err := c.do(ctx, r, &key); err != nil {
+			return c.staleKey(ctx, &cached, ok, err)
+		}
+		c.keyCache.set(ctx, name, key, "")
+		return &key, nil
+	}
+
+	if ok && cached.etag != "" {
+		r.ifNoneMatch = cached.etag
+	}
+	resp, err := c.open(ctx, r, http.StatusOK, http.StatusNotModified)
+	if err != nil {
+		return c.staleKey(ctx, &cached, ok, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if !ok {
+			return nil, errEmptyBody
+		}
+		c.keyCache.touch(ctx, name)
+		return &cached.key, nil
+	}
+
+	if err := c.decodeResponse(resp, &key); err != nil {
+		return nil, err
+	}
+	c.prepareKeys(r, &key)
+	c.keyCache.set(ctx, name, key, resp.Header.Get("ETag"))
+	return &key, nil
+}
+
+// staleKey returns the record of cached, if ok, when the lookup that was to
+// refresh it failed with err because the server is unavailable and the
+// record is within the MaxStaleness of the DegradationPolicy. It returns
+// err otherwise.
+func (c *Client) staleKey(ctx context.Context, cached *keyCacheEntry, ok bool, err error) (*APIKey, error) {
+	maxStale := c.degradation.MaxStaleness
+	if !ok || maxStale <= 0 || !isUnavailable(err) || time.Since(cached.expires) > maxStale {
+		return nil, err
+	}
+	c.logDegraded(ctx, "stale", err)
+	return &cached.key, nil
+}
+
+// forgetKey drops the cached records of the key with the given id after the
+// client changed or deleted it, along with the cached validation results of
+// the hashes they were found under.
+func (c *Client) forgetKey(ctx context.Context, id uuid.UUID) {
+	if c.keyCache != nil && id != uuid.Nil {
+		c.forgetHashes(ctx, c.keyCache.deleteKey(ctx, id))
+	}
+}
+
+// forgetServiceAccount drops the cached records of the keys of the service
+// account with the given id after the client deleted them, as forgetKey
+// does.
+func (c *Client) forgetServiceAccount(ctx context.Context, id uuid.UUID) {
+	if c.keyCache != nil {
+		c.forgetHashes(ctx, c.keyCache.deleteServiceAccount(ctx, id))
+	}
+}
+
+// forgetHashes drops the cached validation results of hashes.
+func (c *Client) forgetHashes(ctx context.Context, hashes []string) {
+	if c.validationCache == nil {
+		return
+	}
+	for _, hash := range hashes {
+		c.validationCache.delete(ctx, hash)
+	}
+}
+
+// clone returns a copy of k sharing no memory with it.
+func (k APIKey) clone() APIKey {
+	k.Scopes = slices.Clone(k.Scopes)
+	k.Labels = maps.Clone(k.Labels)
+	if k.ExpiresAt != nil {
+		t := *k.ExpiresAt
+		k.ExpiresAt = &t
+	}
+	if k.LastUsedAt != nil {
+		t := *k.LastUsedAt
+		k.LastUsedAt = &t
+	}
+	if k.RateLimit != nil {
+		l := *k.RateLimit
+		k.RateLimit = &l
+	}
+	if k.Quota != nil {
+		q := *k.Quota
+		k.Quota = &q
+	}
+	if k.Restrictions != nil {
+		r := Restrictions{
+			AllowedCIDRs:     slices.Clone(k.Restrictions.AllowedCIDRs),
+			AllowedReferrers: slices.Clone(k.Restrictions.AllowedReferrers),
+			AllowedMethods:   slices.Clone(k.Restrictions.AllowedMethods),
+		}
+		k.Restrictions = &r
+	}
+	return k
+}
@@ -0,0 +1,151 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"maps"
+	"net/netip"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeysclienttest"
+)
+
+// TestKeyCacheReturnsCopies checks that changing a key returned by the key
+// cache does not change the cached record.
+func TestKeyCacheReturnsCopies(t *testing.T) {
+	srv := apikeysclienttest.NewServer()
+	defer srv.Close()
+	seeded := srv.Fake.Seed(apikeysclient.APIKey{
+		ServiceAccountID: uuid.New(),
+		Status:           apikeysclient.KeyActive,
+		RateLimit:        &apikeysclient.KeyRateLimit{RequestsPerSecond: 5, Burst: 10},
+		Quota:            &apikeysclient.Quota{Limit: 100, Period: apikeysclient.QuotaPerDay},
+		Restrictions: &apikeysclient.Restrictions{
+			AllowedCIDRs:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			AllowedReferrers: []string{"app.example.com"},
+			AllowedMethods:   []string{"GET"},
+		},
+	})[0]
+	client := srv.Client(apikeysclient.WithKeyCache(apikeysclient.KeyCacheConfig{TTL: time.Hour}))
+	ctx := context.Background()
+
+	first, err := client.GetAPIKeyByID(ctx, seeded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *first
+	want.RateLimit = &apikeysclient.KeyRateLimit{RequestsPerSecond: 5, Burst: 10}
+	want.Quota = &apikeysclient.Quota{Limit: 100, Period: apikeysclient.QuotaPerDay}
+	want.Restrictions = &apikeysclient.Restrictions{
+		AllowedCIDRs:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		AllowedReferrers: []string{"app.example.com"},
+		AllowedMethods:   []string{"GET"},
+	}
+
+	first.RateLimit.Burst = 0
+	first.Quota.Limit = 0
+	first.Restrictions.AllowedCIDRs[0] = netip.MustParsePrefix("0.0.0.0/0")
+	first.Restrictions.AllowedReferrers[0] = "*"
+	first.Restrictions.AllowedMethods[0] = "POST"
+
+	second, err := client.GetAPIKeyByID(ctx, seeded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []struct {
+		name      string
+		got, want any
+	}{
+		{"RateLimit", second.RateLimit, want.RateLimit},
+		{"Quota", second.Quota, want.Quota},
+		{"Restrictions", second.Restrictions, want.Restrictions},
+	} {
+		if !reflect.DeepEqual(field.got, field.want) {
+			t.Errorf("cached %s = %+v, want %+v", field.name, field.got, field.want)
+		}
+	}
+}
+
+// mapCache is a Cache in a map, recording the TTL of each entry.
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{entries: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (c *mapCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.entries[key]
+	return b, ok, nil
+}
+
+func (c *mapCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key], c.ttls[key] = value, ttl
+	return nil
+}
+
+func (c *mapCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+		delete(c.ttls, key)
+	}
+	return nil
+}
+
+// TestSharedKeyCache checks that shared cache entries expire and that
+// deleting a service account with its keys drops their entries.
+func TestSharedKeyCache(t *testing.T) {
+	srv := apikeysclienttest.NewServer()
+	defer srv.Close()
+	cache := newMapCache()
+	client := srv.Client(
+		apikeysclient.WithKeyCache(apikeysclient.KeyCacheConfig{TTL: time.Hour, Cache: cache}),
+		apikeysclient.WithValidationCache(apikeysclient.ValidationCacheConfig{TTL: time.Hour, Cache: cache}),
+		apikeysclient.WithDegradationPolicy(apikeysclient.DegradationPolicy{MaxStaleness: 2 * time.Hour}))
+	ctx := context.Background()
+
+	created, err := client.CreateServiceAccount(ctx, apikeysclient.ServiceAccount{Name: "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := created.ID
+	key := srv.Fake.SeedKey(account)
+
+	if _, err := client.Authenticate(ctx, key.APIKey); err != nil {
+		t.Fatal(err)
+	}
+	cache.mu.Lock()
+	if len(cache.ttls) == 0 {
+		t.Error("nothing stored in the cache")
+	}
+	for name, ttl := range cache.ttls {
+		if ttl != 3*time.Hour {
+			t.Errorf("%s stored for %v, want TTL and MaxStaleness", name, ttl)
+		}
+	}
+	cache.mu.Unlock()
+
+	if err := client.DeleteServiceAccount(ctx, account, &apikeysclient.DeleteServiceAccountOptions{Cascade: true}); err != nil {
+		t.Fatal(err)
+	}
+	cache.mu.Lock()
+	if len(cache.entries) != 0 {
+		t.Errorf("entries left after deleting the account: %v", slices.Collect(maps.Keys(cache.entries)))
+	}
+	cache.mu.Unlock()
+}
@@ -0,0 +1,53 @@
+package apikeysclient
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// LabelSelector selects keys by their labels: a key matches when it has
+// every label of the selector with the same value.
+type LabelSelector map[string]string
+
+// ParseLabelSelector parses a selector written as comma-separated key=value
+// pairs, such as "env=prod,team=payments". The empty string selects every
+// key.
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	sel := LabelSelector{}
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label selector %q: want key=value pairs", s)
+		}
+		sel[k] = v
+	}
+
+	return sel, nil
+}
+
+// Matches reports whether labels has every label of s.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, v := range s {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns s in the form accepted by ParseLabelSelector, with keys in
+// sorted order.
+func (s LabelSelector) String() string {
+	pairs := make([]string, 0, len(s))
+	for _, k := range slices.Sorted(maps.Keys(s)) {
+		pairs = append(pairs, k+"="+s[k])
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,67 @@
+package apikeysclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     apikeysclient.LabelSelector
+		wantErr  bool
+	}{
+		{"", apikeysclient.LabelSelector{}, false},
+		{"  ", apikeysclient.LabelSelector{}, false},
+		{"env=prod", apikeysclient.LabelSelector{"env": "prod"}, false},
+		{" env = prod , team=payments", apikeysclient.LabelSelector{"env": "prod", "team": "payments"}, false},
+		{"flag=", apikeysclient.LabelSelector{"flag": ""}, false},
+		{"url=a=b", apikeysclient.LabelSelector{"url": "a=b"}, false},
+		{"env", nil, true},
+		{"=prod", nil, true},
+		{"env=prod,", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := apikeysclient.ParseLabelSelector(tt.selector)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("ParseLabelSelector = %v, %v, want error %v", got, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLabelSelector = %v, want %v", got, tt.want)
+			}
+			if err == nil {
+				if again, err := apikeysclient.ParseLabelSelector(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+					t.Errorf("ParseLabelSelector(%q) = %v, %v, want %v", got.String(), again, err, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "payments"}
+
+	tests := []struct {
+		selector apikeysclient.LabelSelector
+		want     bool
+	}{
+		{nil, true},
+		{apikeysclient.LabelSelector{"env": "prod"}, true},
+		{apikeysclient.LabelSelector{"env": "prod", "team": "payments"}, true},
+		{apikeysclient.LabelSelector{"env": "staging"}, false},
+		{apikeysclient.LabelSelector{"env": "prod", "region": "eu"}, false},
+		{apikeysclient.LabelSelector{"region": ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector.String(), func(t *testing.T) {
+			if got := tt.selector.Matches(labels); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
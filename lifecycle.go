@@ -0,0 +1,107 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RotateAPIKeyResponse is the result of rotating an API key.
+type RotateAPIKeyResponse struct {
+	// NewKey replaces the rotated key.
+	NewKey APIKey `json:"new_key"`
+
+	// OldKey is the rotated key. It keeps working until GracePeriodEndsAt so
+	// clients can switch over without failing requests.
+	OldKey APIKey `json:"old_key"`
+
+	GracePeriodEndsAt time.Time `json:"grace_period_ends_at"`
+}
+
+// RotateAPIKey replaces the key with the given id with a newly generated one.
+func (c *Client) RotateAPIKey(ctx context.Context, id uuid.UUID) (*RotateAPIKeyResponse, error) {
+	var rotated RotateAPIKeyResponse
+	_, err := c.do(ctx, &request{
+		op:             "RotateAPIKey",
+		keyID:          id,
+		method:         http.MethodPost,
+		url:            c.endpoint("apikeys", id.String(), "rotate"),
+		in:             id,
+		idempotencyKey: c.idempotencyKey(ctx, "RotateAPIKey", id),
+		secret:         true,
+	}, &rotated, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rotated, nil
+}
+
+// RevokeAPIKey permanently deactivates the key with the given id and returns
+// its updated state. The key is also dropped from the validation cache when the server
+// returns its material or hash.
+func (c *Client) RevokeAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	key, err := c.patchKeyState(ctx, "RevokeAPIKey", id, "revoke")
+	if err != nil {
+		return nil, err
+	}
+
+	c.invalidateKey(key)
+	return key, nil
+}
+
+// ActivateAPIKey makes the pending or suspended key with the given id active
+// and returns its updated state.
+func (c *Client) ActivateAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	return c.patchKeyState(ctx, "ActivateAPIKey", id, "activate")
+}
+
+// patchKeyState calls the PATCH endpoint /apikeys/{id}/{action}.
+func (c *Client) patchKeyState(ctx context.Context, op string, id uuid.UUID, action string) (*APIKey, error) {
+	var key APIKey
+	_, err := c.do(ctx, &request{
+		op:     op,
+		keyID:  id,
+		method: http.MethodPatch,
+		url:    c.endpoint("apikeys", id.String(), action),
+		in:     id,
+	}, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// CreateAPIKeyWithExpiry creates apiKey set to expire at expiresAt.
+//
+// Deprecated: Use CreateKey with CreateAPIKeyRequest.ExpiresAt.
+func (c *Client) CreateAPIKeyWithExpiry(ctx context.Context, apiKey APIKey, expiresAt time.Time) (APIKey, error) {
+	apiKey.ExpiresAt = &expiresAt
+	return c.createAPIKey(ctx, apiKey)
+}
+
+type extendExpiryRequest struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExtendExpiry moves the expiry of the key with the given id to newExpiry and
+// returns its updated state.
+func (c *Client) ExtendExpiry(ctx context.Context, id uuid.UUID, newExpiry time.Time) (*APIKey, error) {
+	var key APIKey
+	_, err := c.do(ctx, &request{
+		op:     "ExtendExpiry",
+		keyID:  id,
+		method: http.MethodPatch,
+		url:    c.endpoint("apikeys", id.String(), "expiry"),
+		body:   extendExpiryRequest{ExpiresAt: newExpiry},
+		in:     ExtendExpiryInput{ID: id, ExpiresAt: newExpiry},
+	}, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
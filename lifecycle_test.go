@@ -0,0 +1,105 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeysclienttest"
+)
+
+func TestRevokeInvalidatesCacheWithRedactedSecrets(t *testing.T) {
+	const secret = "ak_test_revoked"
+	id := uuid.New()
+
+	var revoked atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(apikeysclient.ValidateResponse{IsValid: !revoked.Load()})
+		case http.MethodPatch:
+			revoked.Store(true)
+			// The server returns the material, but no KeyHash.
+			json.NewEncoder(w).Encode(apikeysclient.APIKey{ID: id, APIKey: secret, Status: apikeysclient.KeyRevoked})
+		}
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL,
+		apikeysclient.WithRedactedSecrets(),
+		apikeysclient.WithValidationCache(apikeysclient.ValidationCacheConfig{TTL: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if valid, err := client.ValidateAPIKey(ctx, secret); !valid || err != nil {
+		t.Fatalf("ValidateAPIKey before revocation = %v, %v", valid, err)
+	}
+	key, err := client.RevokeAPIKey(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.APIKey != "" {
+		t.Errorf("revoked key carries its material")
+	}
+	if valid, err := client.ValidateAPIKey(ctx, secret); valid || err != nil {
+		t.Errorf("ValidateAPIKey after revocation = %v, %v; want false", valid, err)
+	}
+}
+
+// TestDeleteInvalidatesCache checks that deleted and purged keys whose
+// records were resolved by Authenticate stop validating from the cache.
+func TestDeleteInvalidatesCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		async  string
+		delete func(*apikeysclient.Client, context.Context, uuid.UUID) error
+	}{
+		{"delete", "", (*apikeysclient.Client).DeleteAPIKey},
+		{"async delete", "DeleteAPIKey", (*apikeysclient.Client).DeleteAPIKey},
+		{"purge", "", (*apikeysclient.Client).PurgeAPIKey},
+		{"async purge", "PurgeAPIKey", (*apikeysclient.Client).PurgeAPIKey},
+		{"bulk delete", "", func(c *apikeysclient.Client, ctx context.Context, id uuid.UUID) error {
+			results, err := c.DeleteAPIKeys(ctx, []uuid.UUID{id})
+			if err != nil {
+				return err
+			}
+			return results[0].Err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := apikeysclienttest.NewServer()
+			defer srv.Close()
+			if tt.async != "" {
+				srv.Fake.SetAsync(tt.async, true)
+			}
+			key := srv.Fake.SeedKey(uuid.New())
+			client := srv.Client(
+				apikeysclient.WithValidationCache(apikeysclient.ValidationCacheConfig{TTL: time.Hour, NegativeTTL: time.Hour}),
+				apikeysclient.WithKeyCache(apikeysclient.KeyCacheConfig{TTL: time.Hour}),
+				apikeysclient.WithOperationPollInterval(time.Millisecond))
+			ctx := context.Background()
+
+			if _, err := client.Authenticate(ctx, key.APIKey); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.delete(client, ctx, key.ID); err != nil {
+				t.Fatal(err)
+			}
+			if valid, err := client.ValidateAPIKey(ctx, key.APIKey); valid || err != nil {
+				t.Errorf("ValidateAPIKey after %s = %v, %v; want false", tt.name, valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SortOrder is the order in which listed keys are returned.
+type SortOrder string
+
+// Sort orders accepted by the server.
+const (
+	SortCreatedAsc  SortOrder = "created_at"
+	SortCreatedDesc SortOrder = "-created_at"
+)
+
+// ListAPIKeysOptions selects a page of API keys. Zero fields are not sent.
+// Cursor and Page are alternatives: servers that support cursors return a
+// NextCursor to pass back on the following call.
+type ListAPIKeysOptions struct {
+	Page    int
+	PerPage int
+	Cursor  string
+
+	ServiceAccountID uuid.UUID
+	IsActive         *bool
+	CreatedAfter     time.Time
+
+	// Status restricts the listing to keys in one of the statuses. Deleted
+	// keys are only listed when KeyDeleted is one of them.
+	Status []KeyStatus
+
+	// Labels restricts the listing to keys having all of its labels.
+	Labels LabelSelector
+
+	Sort SortOrder
+
+	// Tenant lists the keys of another tenant than the client's.
+	Tenant Tenant
+}
+
+func (o *ListAPIKeysOptions) tenant() Tenant {
+	if o == nil {
+		return Tenant{}
+	}
+	return o.Tenant
+}
+
+// values encodes o as query parameters.
+func (o *ListAPIKeysOptions) values() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.ServiceAccountID != uuid.Nil {
+		q.Set("service_account_id", o.ServiceAccountID.String())
+	}
+	if o.IsActive != nil {
+		q.Set("is_active", strconv.FormatBool(*o.IsActive))
+	}
+	if len(o.Status) > 0 {
+		statuses := make([]string, len(o.Status))
+		for i, s := range o.Status {
+			statuses[i] = string(s)
+		}
+		q.Set("status", strings.Join(statuses, ","))
+	}
+	if !o.CreatedAfter.IsZero() {
+		q.Set("created_after", o.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if len(o.Labels) > 0 {
+		q.Set("label_selector", o.Labels.String())
+	}
+	if o.Sort != "" {
+		q.Set("sort", string(o.Sort))
+	}
+
+	return q
+}
+
+// APIKeyPage is one page of a key listing.
+type APIKeyPage struct {
+	Keys []APIKey
+
+	// TotalCount is the number of keys matching the filters across all
+	// pages, or -1 when the server does not report it.
+	TotalCount int
+
+	// NextCursor is the cursor of the following page. It is empty on the
+	// last page and when the server paginates by page number.
+	NextCursor string
+}
+
+// ListAPIKeysPage retrieves the page of API keys selected by opts. The total
+// count and next cursor are read from the X-Total-Count and X-Next-Cursor
+// response headers.
+func (c *Client) ListAPIKeysPage(ctx context.Context, opts *ListAPIKeysOptions) (*APIKeyPage, error) {
+	return c.listAPIKeysPage(ctx, "ListAPIKeysPage", c.tenantEndpoint(opts.tenant(), "apikeys"), opts, opts)
+}
+
+func (c *Client) listAPIKeysPage(ctx context.Context, op, endpoint string, in any, opts *ListAPIKeysOptions) (*APIKeyPage, error) {
+	var keys []APIKey
+	resp, err := c.do(ctx, &request{
+		op:     op,
+		method: http.MethodGet,
+		url:    endpoint,
+		query:  opts.values(),
+		in:     in,
+		tenant: opts.tenant(),
+	}, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &APIKeyPage{
+		Keys:       keys,
+		TotalCount: -1,
+		NextCursor: resp.Header.Get("X-Next-Cursor"),
+	}
+	if total, err := strconv.Atoi(resp.Header.Get("X-Total-Count")); err == nil {
+		page.TotalCount = total
+	}
+
+	return page, nil
+}
+
+// APIKeyIterator walks every page of a key listing. Call Next until it
+// returns false, then check Err:
+//
+//	it := client.ListAPIKeysIter(opts)
+//	for it.Next(ctx) {
+//		key := it.APIKey()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type APIKeyIterator struct {
+	fetch func(ctx context.Context, opts *ListAPIKeysOptions) (*APIKeyPage, error)
+	opts  ListAPIKeysOptions
+
+	keys []APIKey
+	pos  int
+	seen int
+	done bool
+	err  error
+}
+
+// ListAPIKeysIter returns an iterator over all API keys matching opts,
+// fetching further pages as needed. opts.Page and opts.Cursor select where
+// iteration starts.
+func (c *Client) ListAPIKeysIter(opts *ListAPIKeysOptions) *APIKeyIterator {
+	return newAPIKeyIterator(c.ListAPIKeysPage, opts)
+}
+
+func newAPIKeyIterator(fetch func(context.Context, *ListAPIKeysOptions) (*APIKeyPage, error), opts *ListAPIKeysOptions) *APIKeyIterator {
+	it := &APIKeyIterator{fetch: fetch, pos: -1}
+	if opts != nil {
+		it.opts = *opts
+	}
+	return it
+}
+
+// Next advances to the next key, fetching the next page when the current one
+// is exhausted. It returns false when there are no more keys or an error
+// occurred.
+func (it *APIKeyIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.pos++
+	for it.pos >= len(it.keys) {
+		if it.done {
+			return false
+		}
+		if !it.fetchPage(ctx) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (it *APIKeyIterator) fetchPage(ctx context.Context) bool {
+	page, err := it.fetch(ctx, &it.opts)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.keys = page.Keys
+	it.pos = 0
+	it.seen += len(page.Keys)
+
+	switch {
+	case page.NextCursor != "":
+		it.opts.Cursor = page.NextCursor
+	case len(page.Keys) == 0,
+		it.opts.Cursor != "",
+		it.opts.PerPage > 0 && len(page.Keys) < it.opts.PerPage,
+		page.TotalCount >= 0 && it.seen >= page.TotalCount:
+		it.done = true
+	case it.opts.PerPage == 0 && page.TotalCount < 0:
+		// Without a page size or total the server cannot be paged through
+		// reliably, so the first page is assumed to hold everything.
+		it.done = true
+	default:
+		if it.opts.Page == 0 {
+			it.opts.Page = 1
+		}
+		it.opts.Page++
+	}
+
+	return true
+}
+
+// APIKey returns the current key. It is only valid after Next returned true.
+func (it *APIKeyIterator) APIKey() APIKey {
+	return it.keys[it.pos]
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *APIKeyIterator) Err() error {
+	return it.err
+}
+
+// ListAPIKeysByServiceAccount retrieves the page of keys belonging to the
+// service account with the given id. opts.ServiceAccountID is ignored.
+func (c *Client) ListAPIKeysByServiceAccount(ctx context.Context, serviceAccountID uuid.UUID, opts *ListAPIKeysOptions) (*APIKeyPage, error) {
+	if opts != nil && opts.ServiceAccountID != uuid.Nil {
+		o := *opts
+		o.ServiceAccountID = uuid.Nil
+		opts = &o
+	}
+
+	endpoint := c.tenantEndpoint(opts.tenant(), "serviceaccounts", serviceAccountID.String(), "apikeys")
+	in := ServiceAccountListInput{ServiceAccountID: serviceAccountID, Options: opts}
+	return c.listAPIKeysPage(ctx, "ListAPIKeysByServiceAccount", endpoint, in, opts)
+}
+
+// ListAPIKeysByServiceAccountIter returns an iterator over all keys of the
+// service account with the given id.
+func (c *Client) ListAPIKeysByServiceAccountIter(serviceAccountID uuid.UUID, opts *ListAPIKeysOptions) *APIKeyIterator {
+	return newAPIKeyIterator(func(ctx context.Context, opts *ListAPIKeysOptions) (*APIKeyPage, error) {
+		return c.ListAPIKeysByServiceAccount(ctx, serviceAccountID, opts)
+	}, opts)
+}
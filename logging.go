@@ -0,0 +1,83 @@
+package apikeysclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/PiccoloMondoC/apikeysclient/internal/redact"
+)
+
+// WithLogger logs every outgoing request and its response to logger at debug
+// level. Key material is redacted from URLs, headers and JSON bodies.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client, _ *options) {
+		c.logger = logger
+	}
+}
+
+// logRequest logs req, whose body is body, if debug logging is enabled.
+func (c *Client) logRequest(ctx context.Context, r *request, req *http.Request, body []byte, attempt int) {
+	if c.logger == nil || !c.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "apikeys request",
+		slog.String("op", r.op),
+		slog.Int("attempt", attempt),
+		slog.String("method", req.Method),
+		slog.String("url", redact.URL(req.URL).String()),
+		slog.Any("headers", redact.FlatHeader(req.Header)),
+		slog.String("body", redact.Body(body)),
+	)
+}
+
+// logResponse logs the outcome of an exchange if debug logging is enabled.
+// A logged response body is buffered and replaced so callers can still read
+// it, except for streamed responses whose body is not logged.
+func (c *Client) logResponse(ctx context.Context, r *request, resp *http.Response, err error, elapsed time.Duration) {
+	if c.logger == nil || !c.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	if err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelDebug, "apikeys request failed",
+			slog.String("op", r.op),
+			slog.Duration("elapsed", elapsed),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("op", r.op),
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("elapsed", elapsed),
+		slog.Any("headers", redact.FlatHeader(resp.Header)),
+	}
+
+	// Streamed bodies are read incrementally by the caller and never logged.
+	if !r.stream {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{readErr}))
+
+		attrs = append(attrs, slog.String("body", redact.Body(body)))
+	}
+
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "apikeys response", attrs...)
+}
+
+// errReader replays a read error after a buffered body, or EOF if there was
+// none.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,240 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// capabilitiesDiscoveryBackoff is how long a failed GET /capabilities is
+// remembered before it is tried again.
+const capabilitiesDiscoveryBackoff = 30 * time.Second
+
+// Capability names reported by servers in GET /capabilities.
+const (
+	// CapabilityBodyLookup means the server accepts key material in the
+	// body of POST /apikeys/lookup and POST /apikeys/validate.
+	CapabilityBodyLookup = "body_lookup"
+
+	// CapabilityHashedKeys means the server accepts key hashes, as sent by
+	// clients using WithHashedKeys.
+	CapabilityHashedKeys = "hashed_keys"
+)
+
+// capabilitiesFailure is a failed capabilities discovery.
+type capabilitiesFailure struct {
+	err     error
+	retryAt time.Time
+}
+
+// Capabilities lists the optional features a server supports.
+type Capabilities struct {
+	Features []string `json:"features"`
+}
+
+// Has reports whether feature is among the capabilities.
+func (c *Capabilities) Has(feature string) bool {
+	return c != nil && slices.Contains(c.Features, feature)
+}
+
+// WithCapabilities makes the client assume the server supports caps instead
+// of discovering them from GET /capabilities.
+func WithCapabilities(caps Capabilities) Option {
+	return func(c *Client, _ *options) {
+		c.capabilities.Store(&caps)
+	}
+}
+
+// Capabilities returns the server's capabilities, fetching them from
+// GET /capabilities on first use. Servers without the endpoint are reported
+// as supporting nothing. A failed fetch is retried after a backoff, and its
+// error returned meanwhile.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	if caps := c.capabilities.Load(); caps != nil {
+		return caps, nil
+	}
+	if failed := c.capabilitiesFailed.Load(); failed != nil && time.Now().Before(failed.retryAt) {
+		return nil, failed.err
+	}
+
+	var caps Capabilities
+	_, err := c.do(ctx, &request{
+		op:     "GetCapabilities",
+		method: http.MethodGet,
+		url:    c.endpoint("capabilities"),
+	}, &caps)
+	switch {
+	case isMissingEndpoint(err):
+		caps = Capabilities{}
+	case err != nil:
+		// The caller giving up says nothing about the server.
+		if ctx.Err() == nil {
+			c.capabilitiesFailed.Store(&capabilitiesFailure{err: err, retryAt: time.Now().Add(capabilitiesDiscoveryBackoff)})
+		}
+		return nil, err
+	}
+
+	c.capabilities.Store(&caps)
+	return &caps, nil
+}
+
+// bodyLookup reports whether key material should be sent in request bodies
+// rather than URLs. Custom transports never put keys in URLs, so discovery
+// is skipped for them.
+func (c *Client) bodyLookup(ctx context.Context) bool {
+	if c.transport != nil {
+		return false
+	}
+
+	caps, err := c.Capabilities(ctx)
+	return err == nil && caps.Has(CapabilityBodyLookup)
+}
+
+type keyRequest struct {
+	APIKey string `json:"api_key"`
+}
+
+// LookupAPIKey retrieves the APIKey record for the given key material, which
+// is sent in the body of a POST request so it stays out of URLs and access
+// logs.
+func (c *Client) LookupAPIKey(ctx context.Context, apiKey string) (*APIKey, error) {
+	var key APIKey
+	_, err := c.do(ctx, c.lookupPOST(apiKey), &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// ValidateAPIKeyPOST asks the server whether apiKey is valid, sending it in
+// the body of a POST request. Unlike ValidateAPIKey it always asks the
+// server, bypassing local verification and the validation cache.
+func (c *Client) ValidateAPIKeyPOST(ctx context.Context, apiKey string) (bool, error) {
+	validation, err := c.validate(ctx, c.validatePOST(apiKey))
+	if err != nil {
+		return false, err
+	}
+
+	return validation.IsValid, nil
+}
+
+// lookupRequest returns the request fetching the record of apiKey in the
+// safest form the client and server support.
+func (c *Client) lookupRequest(ctx context.Context, apiKey string) *request {
+	switch {
+	case c.hashedKeys:
+		hash := HashAPIKey(apiKey)
+		return &request{
+			op:         "GetAPIKeyByHash",
+			method:     http.MethodPost,
+			url:        c.endpoint("apikeys", "lookup"),
+			body:       keyHashRequest{KeyHash: hash},
+			in:         hash,
+			idempotent: true,
+		}
+	case c.bodyLookup(ctx):
+		return c.lookupPOST(apiKey)
+	}
+
+	return &request{
+		op:     "GetAPIKeyByAPIKey",
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys", "key", apiKey),
+		in:     apiKey,
+	}
+}
+
+func (c *Client) lookupPOST(apiKey string) *request {
+	return &request{
+		op:         "LookupAPIKey",
+		method:     http.MethodPost,
+		url:        c.endpoint("apikeys", "lookup"),
+		body:       keyRequest{APIKey: apiKey},
+		in:         apiKey,
+		idempotent: true,
+	}
+}
+
+// validateRequests recycles the requests of validations, which services
+// authenticating with the middleware make for every request they serve.
+var validateRequests = sync.Pool{New: func() any { return new(request) }}
+
+// validateRequest returns the request validating apiKey, whose digest is
+// hash, in the safest form the client and server support. Hand it back with
+// releaseValidateRequest once it has been sent.
+func (c *Client) validateRequest(ctx context.Context, apiKey, hash string) *request {
+	urls := c.validateURLs()
+	r := validateRequests.Get().(*request)
+	switch {
+	case c.hashedKeys:
+		*r = request{
+			op:         "ValidateAPIKeyHash",
+			method:     http.MethodPost,
+			url:        urls.post,
+			body:       keyHashRequest{KeyHash: hash},
+			in:         hash,
+			idempotent: true,
+		}
+	case c.bodyLookup(ctx):
+		*r = *c.validatePOST(apiKey)
+	default:
+		*r = request{
+			op:     "ValidateAPIKey",
+			method: http.MethodGet,
+			url:    urls.get(apiKey),
+			in:     apiKey,
+		}
+	}
+	return r
+}
+
+// releaseValidateRequest returns r, from validateRequest, to the pool.
+func releaseValidateRequest(r *request) {
+	*r = request{}
+	validateRequests.Put(r)
+}
+
+func (c *Client) validatePOST(apiKey string) *request {
+	return &request{
+		op:         "ValidateAPIKeyPOST",
+		method:     http.MethodPost,
+		url:        c.validateURLs().post,
+		body:       keyRequest{APIKey: apiKey},
+		in:         apiKey,
+		idempotent: true,
+	}
+}
+
+// validateEndpoints are the URLs of validations under base, resolved once
+// rather than for every validation.
+type validateEndpoints struct {
+	base string
+	post string
+
+	// keyPrefix is the URL of GET validations up to the escaped key.
+	keyPrefix string
+}
+
+// get returns the URL of the GET validation of apiKey.
+func (e *validateEndpoints) get(apiKey string) string {
+	return e.keyPrefix + url.PathEscape(apiKey) + "/validate"
+}
+
+// validateURLs returns the validation endpoints under BaseURL, resolving
+// them again if BaseURL was changed since NewClient.
+func (c *Client) validateURLs() *validateEndpoints {
+	if e := c.validateEndpoints.Load(); e != nil && e.base == c.BaseURL {
+		return e
+	}
+	e := &validateEndpoints{
+		base:      c.BaseURL,
+		post:      c.endpoint("apikeys", "validate"),
+		keyPrefix: c.endpoint("apikeys", "key") + "/",
+	}
+	c.validateEndpoints.Store(e)
+	return e
+}
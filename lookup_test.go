@@ -0,0 +1,45 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// TestCapabilitiesDiscoveryFailure checks that a failed GET /capabilities
+// is not sent again before each validation.
+func TestCapabilitiesDiscoveryFailure(t *testing.T) {
+	var discoveries atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/capabilities" {
+			discoveries.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apikeysclient.ValidateResponse{IsValid: true})
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if valid, err := client.ValidateAPIKey(context.Background(), "key"); !valid || err != nil {
+			t.Fatalf("ValidateAPIKey = %v, %v", valid, err)
+		}
+	}
+
+	if got := discoveries.Load(); got != 1 {
+		t.Errorf("GET /capabilities sent %d times, want 1", got)
+	}
+	if _, err := client.Capabilities(context.Background()); err == nil {
+		t.Error("Capabilities succeeded, want the remembered failure")
+	}
+}
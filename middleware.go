@@ -0,0 +1,248 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Errors passed to a middleware ErrorHandler.
+var (
+	ErrMissingAPIKey = errors.New("missing API key")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// DefaultKeyHeader is the header the middleware reads the API key from unless
+// configured otherwise.
+const DefaultKeyHeader = "X-API-Key"
+
+// ErrorHandler writes the response for a request the middleware rejected.
+// status is the suggested status code: 401 for a missing key, 403 for an
+// invalid or restricted one, 429 for one over quota and 503 when the key
+// could not be validated.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// MiddlewareOption configures the middleware returned by Client.Middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	extractor     Extractor
+	resolve       bool
+	requestMeta   func(*http.Request) RequestMeta
+	enforceQuota  bool
+	errorHandler  ErrorHandler
+	failurePolicy *FailurePolicy
+}
+
+// WithKeyHeader sets the header the API key is read from. When the header is
+// Authorization, the key is expected as a Bearer token. It replaces any
+// extractor set with WithExtractor.
+func WithKeyHeader(name string) MiddlewareOption {
+	return WithExtractor(keyHeaderExtractor(name))
+}
+
+// WithExtractor sets how the API key is found in requests, for clients that
+// send it elsewhere than the DefaultKeyHeader header. Combine extractors with
+// ChainExtractors to accept several places:
+//
+//	client.Middleware(next, apikeysclient.WithExtractor(apikeysclient.ChainExtractors(
+//		apikeysclient.HeaderExtractor("X-API-Key"),
+//		apikeysclient.BearerExtractor(),
+//		apikeysclient.QueryExtractor("api_key"),
+//	)))
+func WithExtractor(e Extractor) MiddlewareOption {
+	return func(m *middlewareConfig) {
+		m.extractor = e
+	}
+}
+
+// WithResolveKey controls whether a validated key is looked up so its APIKey
+// record can be stored in the request context. It is enabled by default;
+// disabling it saves a request to the server per inbound request.
+func WithResolveKey(resolve bool) MiddlewareOption {
+	return func(m *middlewareConfig) {
+		m.resolve = resolve
+	}
+}
+
+// WithRequestMeta sets how requests are described to CheckRestrictions. The
+// default, RequestMetaFromHTTP, takes the client address from the
+// connection; services behind a proxy should read the forwarded address
+// they trust instead.
+func WithRequestMeta(fn func(*http.Request) RequestMeta) MiddlewareOption {
+	return func(m *middlewareConfig) {
+		m.requestMeta = fn
+	}
+}
+
+// WithQuotaEnforcement controls whether requests with a key the server
+// reported over its quota are rejected with a *QuotaExceededError, as
+// CheckQuota does. Rejections carry the X-RateLimit-Limit,
+// X-RateLimit-Remaining, X-RateLimit-Reset and Retry-After headers of the
+// quota, set before the error handler is called. It is disabled by default.
+func WithQuotaEnforcement(enforce bool) MiddlewareOption {
+	return func(m *middlewareConfig) {
+		m.enforceQuota = enforce
+	}
+}
+
+// WithErrorHandler sets the handler for rejected requests. The default
+// replies with the status code and its text.
+func WithErrorHandler(h ErrorHandler) MiddlewareOption {
+	return func(m *middlewareConfig) {
+		m.errorHandler = h
+	}
+}
+
+func defaultErrorHandler(w http.ResponseWriter, _ *http.Request, status int, _ error) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+// Middleware returns an http.Handler that authenticates requests with the API
+// key they carry before passing them to next. Keys are checked with
+// ValidateAPIKey, so the validation cache applies, and requests their
+// Restrictions do not allow are rejected with ErrRestricted, as are keys
+// over quota when WithQuotaEnforcement is set. The APIKey record of an
+// accepted key is available to next through APIKeyFromContext, and its use
+// is recorded with the UsageReporter attached by ReportUsage. Restrictions
+// are only enforced, and uses recorded, when key resolution is enabled.
+func (c *Client) Middleware(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	m := &middlewareConfig{
+		extractor:    HeaderExtractor(DefaultKeyHeader),
+		resolve:      true,
+		requestMeta:  RequestMetaFromHTTP,
+		errorHandler: defaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := m.extractor.Extract(r)
+		if key == "" {
+			m.errorHandler(w, r, http.StatusUnauthorized, ErrMissingAPIKey)
+			return
+		}
+
+		ctx := r.Context()
+		if m.failurePolicy != nil {
+			ctx = ContextWithFailurePolicy(ctx, *m.failurePolicy)
+		}
+
+		meta := m.requestMeta(r)
+		apiKey, err := c.authenticate(ctx, key, m.resolve)
+		if err == nil && apiKey != nil {
+			err = CheckRestrictions(apiKey, meta)
+		}
+		if err == nil && m.enforceQuota {
+			err = c.CheckQuota(key)
+		}
+		if err != nil {
+			var quotaErr *QuotaExceededError
+			if errors.As(err, &quotaErr) {
+				quotaErr.SetHeaders(w.Header())
+			}
+			m.errorHandler(w, r, AuthErrorStatus(err), err)
+			return
+		}
+
+		if apiKey != nil {
+			c.RecordUsage(apiKey, meta)
+			ctx = ContextWithAPIKey(ctx, apiKey)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticate validates key and returns its APIKey record, as the
+// middleware does for each request. It fails with ErrMissingAPIKey for an
+// empty key and ErrInvalidAPIKey for a key the server rejects; other errors
+// mean the key could not be checked. It is the building block of framework
+// adapters such as those under contrib.
+func (c *Client) Authenticate(ctx context.Context, key string) (*APIKey, error) {
+	if key == "" {
+		return nil, ErrMissingAPIKey
+	}
+	return c.authenticate(ctx, key, true)
+}
+
+// AuthErrorStatus returns the status code for rejecting a request that
+// failed authentication with err: 401 for ErrMissingAPIKey, 403 for
+// ErrInvalidAPIKey, ErrInsufficientScope and ErrRestricted, 429 for
+// ErrQuotaExceeded, and 503 otherwise.
+func AuthErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrMissingAPIKey):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrInvalidAPIKey), errors.Is(err, ErrInsufficientScope), errors.Is(err, ErrRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
+
+// authenticate validates key and, if resolve is set, returns its APIKey
+// record. It returns ErrInvalidAPIKey when the server rejects the key.
+func (c *Client) authenticate(ctx context.Context, key string, resolve bool) (*APIKey, error) {
+	valid, err := c.ValidateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if !resolve {
+		return nil, nil
+	}
+
+	// Verified signed keys carry their own record.
+	if claims, _, ok := c.verifySignedKey(key); ok && claims != nil {
+		return claims.apiKey(key), nil
+	}
+
+	apiKey, err := c.GetAPIKeyByAPIKey(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		// The key was deleted between validation and lookup.
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil && isUnavailable(err) && c.degradationPolicy(ctx).Fallback == FailOpen {
+		c.logDegraded(ctx, "fail_open", err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.IsExpired() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return apiKey, nil
+}
+
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns a copy of ctx carrying key, as the middleware
+// stores accepted keys for APIKeyFromContext.
+func ContextWithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the APIKey stored in ctx by the middleware.
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return apiKey, ok
+}
+
+// ServiceAccountIDFromContext returns the service account ID of the APIKey
+// stored in ctx by the middleware.
+func ServiceAccountIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	apiKey, ok := APIKeyFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return apiKey.ServiceAccountID, true
+}
@@ -0,0 +1,77 @@
+package apikeysclient
+
+import "time"
+
+// ValidationOutcome is the result of a ValidateAPIKey call as reported to an
+// Observer.
+type ValidationOutcome string
+
+// Validation outcomes.
+const (
+	ValidationValid   ValidationOutcome = "valid"
+	ValidationInvalid ValidationOutcome = "invalid"
+	ValidationError   ValidationOutcome = "error"
+)
+
+// Caches reported to Observer.ObserveCache.
+const (
+	CacheValidation = "validation"
+	CacheKey        = "key"
+)
+
+// Observer receives events from a Client, for instrumentation other than
+// OpenTelemetry. Package apikeysprom provides a Prometheus implementation.
+// Methods are called synchronously from the calling goroutine and must be
+// safe for concurrent use.
+type Observer interface {
+	// ObserveRequest is called when a call to the keys server finishes,
+	// with the client method making it and its duration including retries.
+	ObserveRequest(op string, d time.Duration, err error)
+
+	// ObserveValidation is called with the result of every ValidateAPIKey
+	// call, including those answered from a cache. Results decided by the
+	// DegradationPolicy count as the result returned.
+	ObserveValidation(outcome ValidationOutcome)
+
+	// ObserveCache is called on every lookup in the validation cache
+	// (CacheValidation) or the key cache (CacheKey).
+	ObserveCache(cache string, hit bool)
+
+	// ObserveCircuitState is called when the circuit breaker changes state.
+	ObserveCircuitState(state CircuitState)
+}
+
+// WithObserver reports client events to o.
+func WithObserver(o Observer) Option {
+	return func(_ *Client, opts *options) {
+		opts.observer = o
+	}
+}
+
+func validationOutcome(valid bool, err error) ValidationOutcome {
+	switch {
+	case err != nil:
+		return ValidationError
+	case valid:
+		return ValidationValid
+	}
+	return ValidationInvalid
+}
+
+// observeRequest starts timing a call of op. The returned function must be
+// called with its outcome.
+func (c *Client) observeRequest(op string) func(error) {
+	if method, ok := opMethods[op]; ok {
+		op = method
+	}
+	began := time.Now()
+	return func(err error) {
+		c.observer.ObserveRequest(op, time.Since(began), err)
+	}
+}
+
+func (c *Client) observeCache(cache string, hit bool) {
+	if c.observer != nil {
+		c.observer.ObserveCache(cache, hit)
+	}
+}
@@ -0,0 +1,217 @@
+package apikeysclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultOperationPollInterval is how often pending operations are polled
+// unless configured otherwise or the server sends a Retry-After header.
+const DefaultOperationPollInterval = time.Second
+
+// ErrOperationFailed is returned, wrapped with the server's reason, for
+// asynchronous operations that completed unsuccessfully.
+var ErrOperationFailed = errors.New("operation failed")
+
+// errUnboundOperation is returned by the methods of Operation values not
+// obtained from a Client.
+var errUnboundOperation = errors.New("operation not obtained from a client")
+
+// OperationStatus is the state of an asynchronous operation.
+type OperationStatus string
+
+// Operation statuses reported by the server.
+const (
+	OperationPending   OperationStatus = "pending"
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+)
+
+// Operation is a request the server accepted with 202 and carries out in
+// the background. Operations returned by the client are handles: Poll
+// refreshes them and Wait blocks until they are done.
+//
+//	op, err := client.RotateAPIKeys(ctx, ids)
+//	if err != nil {
+//		...
+//	}
+//	if err := op.Wait(ctx); err != nil {
+//		...
+//	}
+//	results, err := op.RotationResults()
+//
+// An Operation is not safe for concurrent use.
+type Operation struct {
+	ID     string          `json:"id"`
+	Status OperationStatus `json:"status"`
+
+	// Resource is the URL of the result of a succeeded operation, such as
+	// the created key, when it has one.
+	Resource string `json:"resource,omitempty"`
+
+	// Result is the result of a succeeded operation returned inline, when
+	// it has one; see DecodeResult.
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error is the reason a failed operation failed.
+	Error string `json:"error,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	c *Client
+
+	// url is where the operation is polled, the Location it was reported
+	// at; operations without one are polled by ID.
+	url string
+
+	// retryAfter is the wait before the next poll asked by the server.
+	retryAfter time.Duration
+}
+
+// Done reports whether the operation completed, successfully or not, as of
+// its last poll.
+func (o *Operation) Done() bool {
+	return o.Status == OperationSucceeded || o.Status == OperationFailed
+}
+
+// Err returns the error of a failed operation, which wraps
+// ErrOperationFailed, and nil otherwise.
+func (o *Operation) Err() error {
+	if o.Status != OperationFailed {
+		return nil
+	}
+	return fmt.Errorf("%w: operation %s: %s", ErrOperationFailed, o.ID, o.Error)
+}
+
+// Poll refreshes the operation from the server once. Errors are those of
+// the request, or of a response without a status; check Done and Err for
+// the outcome of the operation.
+func (o *Operation) Poll(ctx context.Context) error {
+	if o.c == nil {
+		return errUnboundOperation
+	}
+
+	r := &request{
+		op:     "GetOperation",
+		method: http.MethodGet,
+		url:    o.url,
+		in:     o.ID,
+	}
+	if r.url == "" {
+		r.url = o.c.endpoint("operations", o.ID)
+	}
+
+	var latest Operation
+	resp, err := o.c.do(ctx, r, &latest)
+	if err != nil {
+		return err
+	}
+	if latest.Status == "" {
+		return fmt.Errorf("operation %s: response has no status", o.ID)
+	}
+
+	latest.c, latest.url = o.c, o.url
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		latest.retryAfter = retryAfter
+	}
+	*o = latest
+	return nil
+}
+
+// Wait polls the operation until it is done and returns Err. Polls are
+// spaced by the client's WithOperationPollInterval, or the server's
+// Retry-After. It gives up with ctx's error when ctx is done.
+func (o *Operation) Wait(ctx context.Context) error {
+	if o.c == nil {
+		return errUnboundOperation
+	}
+
+	for polled := false; !o.Done(); polled = true {
+		// Operations only known by their location are polled right away;
+		// every later poll waits.
+		if polled || o.Status != "" {
+			wait := o.c.operationPollInterval
+			if o.retryAfter > 0 {
+				wait = o.retryAfter
+			}
+			if wait <= 0 {
+				wait = DefaultOperationPollInterval
+			}
+			if err := sleep(ctx, wait); err != nil {
+				return fmt.Errorf("poll operation: %w", err)
+			}
+		}
+		if err := o.Poll(ctx); err != nil {
+			return fmt.Errorf("poll operation: %w", err)
+		}
+	}
+	return o.Err()
+}
+
+// DecodeResult decodes the inline Result of a succeeded operation into out.
+// It fails with Err for failed operations and when the operation is not
+// done yet or has no inline result.
+func (o *Operation) DecodeResult(out any) error {
+	if err := o.Err(); err != nil {
+		return err
+	}
+	if !o.Done() {
+		return fmt.Errorf("operation %s is %s", o.ID, o.Status)
+	}
+	if len(o.Result) == 0 {
+		return fmt.Errorf("operation %s has no result", o.ID)
+	}
+	if err := json.Unmarshal(o.Result, out); err != nil {
+		return fmt.Errorf("decode operation result: %w", err)
+	}
+	return nil
+}
+
+// WithOperationPollInterval sets how often the client polls the operations
+// of calls the server answers with 202 Accepted, DefaultOperationPollInterval
+// by default. Retry-After headers on operation responses take precedence.
+func WithOperationPollInterval(d time.Duration) Option {
+	return func(c *Client, _ *options) {
+		c.operationPollInterval = d
+	}
+}
+
+// GetOperation retrieves the asynchronous operation with the given id.
+func (c *Client) GetOperation(ctx context.Context, id string) (*Operation, error) {
+	op := &Operation{ID: id, c: c}
+	if err := op.Poll(ctx); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// operationAt returns a handle on the operation found at location, which
+// may be absolute or relative to base, and on the keys server.
+func (c *Client) operationAt(base *url.URL, location string) (*Operation, error) {
+	u, err := c.resolveLocation(base, location)
+	if err != nil {
+		return nil, err
+	}
+	return &Operation{c: c, url: u}, nil
+}
+
+// awaitOperation waits for the operation found at location, relative to
+// base, to complete and returns it. It fails with ErrOperationFailed if the
+// operation did, and when ctx is done.
+func (c *Client) awaitOperation(ctx context.Context, base *url.URL, location string) (*Operation, error) {
+	op, err := c.operationAt(base, location)
+	if err != nil {
+		return nil, err
+	}
+	if err := op.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
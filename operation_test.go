@@ -0,0 +1,78 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// operationServer accepts key creations asynchronously and reports the
+// operation with the statuses given, the last one repeated.
+func operationServer(t *testing.T, polls *atomic.Int32, statuses ...apikeysclient.OperationStatus) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/apikeys":
+			w.Header().Set("Location", "/operations/1")
+			w.WriteHeader(http.StatusAccepted)
+		case "/operations/1":
+			n := int(polls.Add(1))
+			status := statuses[min(n, len(statuses))-1]
+			json.NewEncoder(w).Encode(apikeysclient.Operation{ID: "1", Status: status, Error: "boom"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOperationWaitSpacesPolls(t *testing.T) {
+	var polls atomic.Int32
+	srv := operationServer(t, &polls,
+		apikeysclient.OperationPending, apikeysclient.OperationRunning, apikeysclient.OperationFailed)
+
+	const interval = 20 * time.Millisecond
+	client, err := apikeysclient.NewClient(srv.URL, apikeysclient.WithOperationPollInterval(interval))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.CreateAPIKey(context.Background(), apikeysclient.APIKey{Name: "async"})
+	if err == nil {
+		t.Fatal("CreateAPIKey succeeded; want the operation's failure")
+	}
+	if got := polls.Load(); got != 3 {
+		t.Errorf("operation polled %d times, want 3", got)
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("3 polls took %v, want at least %v", elapsed, 2*interval)
+	}
+}
+
+func TestOperationWithoutStatus(t *testing.T) {
+	var polls atomic.Int32
+	srv := operationServer(t, &polls, "")
+
+	client, err := apikeysclient.NewClient(srv.URL, apikeysclient.WithOperationPollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.CreateAPIKey(ctx, apikeysclient.APIKey{Name: "async"}); err == nil {
+		t.Fatal("CreateAPIKey succeeded; want an error for the missing status")
+	}
+	if got := polls.Load(); got != 1 {
+		t.Errorf("operation polled %d times, want 1", got)
+	}
+}
@@ -0,0 +1,186 @@
+package apikeysclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*Client, *options)
+
+// options holds settings that can only be applied once every Option has run,
+// because they depend on other options.
+type options struct {
+	timeout time.Duration
+
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+
+	observer Observer
+
+	codecs      []Codec
+	contentType string
+
+	tlsConfig      *tls.Config
+	clientCertFile string
+	clientKeyFile  string
+	rootCAs        *x509.CertPool
+
+	dialContext DialContextFunc
+	unixSocket  string
+
+	proxyURL           string
+	noProxy            []string
+	proxyConnectHeader http.Header
+}
+
+// apply finalizes c with the collected settings.
+func (o *options) apply(c *Client) error {
+	if c.signedKeys != nil && c.signedKeys.Verifier == nil {
+		return ErrMissingVerifier
+	}
+
+	if o.tracerProvider != nil || o.meterProvider != nil {
+		// Instrument creation only fails for invalid names, which are fixed.
+		c.telemetry, _ = newTelemetry(o.tracerProvider, o.meterProvider)
+	}
+
+	if o.observer != nil {
+		c.observer = o.observer
+		if c.breaker != nil {
+			c.breaker.onChange = o.observer.ObserveCircuitState
+		}
+	}
+
+	if err := o.applyHTTPTransport(c); err != nil {
+		return err
+	}
+
+	if o.timeout > 0 {
+		// Copy the client so a caller-supplied http.Client is not mutated.
+		hc := *c.HttpClient
+		hc.Timeout = o.timeout
+		c.HttpClient = &hc
+	}
+
+	return o.applyCodecs(c)
+}
+
+// applyHTTPTransport installs the TLS, proxy and dialing settings of o on a copy of
+// the transport of c.
+func (o *options) applyHTTPTransport(c *Client) error {
+	if !o.hasTLS() && !o.hasProxy() && !o.hasDialer() {
+		return nil
+	}
+
+	var t *http.Transport
+	switch rt := c.HttpClient.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return fmt.Errorf("%w, have %T", ErrTLSTransport, rt)
+	}
+
+	if err := o.configureTLS(t); err != nil {
+		return err
+	}
+	if err := o.configureProxy(t); err != nil {
+		return err
+	}
+	o.configureDialer(t)
+
+	// Copy the client so a caller-supplied http.Client is not mutated.
+	hc := *c.HttpClient
+	hc.Transport = t
+	c.HttpClient = &hc
+	return nil
+}
+
+// WithHTTPClient sets the http.Client used to send requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client, _ *options) {
+		c.HttpClient = hc
+	}
+}
+
+// WithTimeout sets the overall timeout of each HTTP exchange, overriding the
+// timeout of the http.Client in use. Streamed responses, whose body is read
+// for as long as the caller wants, are exempt.
+func WithTimeout(d time.Duration) Option {
+	return func(_ *Client, o *options) {
+		o.timeout = d
+	}
+}
+
+// WithCallTimeout bounds every client call by d, including its retries and
+// the time spent waiting for the rate limiter, so latency-sensitive callers
+// are not held up for the full timeout of the http.Client. It applies to
+// custom transports too. Streamed calls, such as ListAPIKeysStream, are
+// only bounded until their response starts. Override it for single methods
+// with WithMethodTimeout.
+func WithCallTimeout(d time.Duration) Option {
+	return func(c *Client, _ *options) {
+		c.callTimeout = d
+	}
+}
+
+// WithMethodTimeout bounds calls of the client method named op, such as
+// "ValidateAPIKey" or "ListAPIKeysPage", by d instead of the timeout set by
+// WithCallTimeout. op is a Call op name; the timeout of "ValidateAPIKey"
+// and "GetAPIKeyByAPIKey" also covers the hashed and POST forms those
+// methods may send. A zero d exempts the method from the call timeout. The
+// timeout of the http.Client still limits each HTTP exchange. For streamed
+// responses, the call timeout only bounds the wait for the response.
+func WithMethodTimeout(op string, d time.Duration) Option {
+	return func(c *Client, _ *options) {
+		if c.methodTimeouts == nil {
+			c.methodTimeouts = make(map[string]time.Duration)
+		}
+		c.methodTimeouts[op] = d
+	}
+}
+
+// WithAcceptedStatus makes the client method named op, such as
+// "DeleteAPIKey" or "UpdateAPIKey", treat responses with the given status
+// codes as successful besides those it accepts by default, for servers
+// answering with other codes than this client expects. 202 and 204
+// responses carry no result, which is left zero. As with WithMethodTimeout,
+// op also covers the alternative forms of ValidateAPIKey and
+// GetAPIKeyByAPIKey.
+func WithAcceptedStatus(op string, codes ...int) Option {
+	return func(c *Client, _ *options) {
+		if c.acceptedStatus == nil {
+			c.acceptedStatus = make(map[string][]int)
+		}
+		c.acceptedStatus[op] = append(c.acceptedStatus[op], codes...)
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client, _ *options) {
+		c.UserAgent = ua
+	}
+}
+
+// WithRetry enables retries of failed requests according to policy. Use
+// DefaultRetryPolicy for sensible defaults.
+func WithRetry(policy *RetryPolicy) Option {
+	return func(c *Client, _ *options) {
+		c.Retry = policy
+	}
+}
+
+// WithAuthToken sets the bearer token sent in the Authorization header. It
+// is equivalent to WithBearerToken.
+func WithAuthToken(token string) Option {
+	return WithBearerToken(token)
+}
@@ -0,0 +1,310 @@
+// Package protocodec encodes keys server bodies as Protocol Buffers, using
+// the messages of package apikeyspb defined in proto/apikeys/v1. Register it
+// to have the client ask for protobuf responses:
+//
+//	client, err := apikeysclient.NewClient(baseURL,
+//		apikeysclient.WithCodec(protocodec.Codec{}),
+//		apikeysclient.WithContentType(apikeysclient.ContentTypeProtobuf))
+//
+// Keys, key listings, validation results and rotations have protobuf forms;
+// servers answer other calls in JSON.
+package protocodec
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeyspb"
+)
+
+// ErrUnsupportedType is returned for values without a protobuf form.
+var ErrUnsupportedType = errors.New("type has no protobuf form")
+
+// Codec is an apikeysclient.Codec for ContentTypeProtobuf. Besides
+// proto.Message values, it handles APIKey, []APIKey, ValidateResponse and
+// RotateAPIKeyResponse, and pointers to them.
+type Codec struct{}
+
+var _ apikeysclient.Codec = Codec{}
+
+// ContentType implements apikeysclient.Codec.
+func (Codec) ContentType() string {
+	return apikeysclient.ContentTypeProtobuf
+}
+
+// Marshal implements apikeysclient.Codec.
+func (Codec) Marshal(v any) ([]byte, error) {
+	var m proto.Message
+	switch v := v.(type) {
+	case proto.Message:
+		m = v
+	case apikeysclient.APIKey:
+		m = ToProto(&v)
+	case *apikeysclient.APIKey:
+		m = ToProto(v)
+	case []apikeysclient.APIKey:
+		m = listToProto(v)
+	case *[]apikeysclient.APIKey:
+		m = listToProto(*v)
+	case apikeysclient.ValidateResponse:
+		m = validationToProto(&v)
+	case *apikeysclient.ValidateResponse:
+		m = validationToProto(v)
+	case apikeysclient.RotateAPIKeyResponse:
+		m = rotationToProto(&v)
+	case *apikeysclient.RotateAPIKeyResponse:
+		m = rotationToProto(v)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal implements apikeysclient.Codec.
+func (Codec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case proto.Message:
+		return proto.Unmarshal(data, v)
+	case *apikeysclient.APIKey:
+		var pk apikeyspb.APIKey
+		if err := proto.Unmarshal(data, &pk); err != nil {
+			return err
+		}
+		*v = FromProto(&pk)
+	case *[]apikeysclient.APIKey:
+		var resp apikeyspb.ListAPIKeysResponse
+		if err := proto.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		keys := make([]apikeysclient.APIKey, len(resp.GetApiKeys()))
+		for i, key := range resp.GetApiKeys() {
+			keys[i] = FromProto(key)
+		}
+		*v = keys
+	case *apikeysclient.ValidateResponse:
+		var resp apikeyspb.ValidateAPIKeyResponse
+		if err := proto.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		*v = ValidationFromProto(&resp)
+	case *apikeysclient.RotateAPIKeyResponse:
+		var resp apikeyspb.RotateAPIKeyResponse
+		if err := proto.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		*v = apikeysclient.RotateAPIKeyResponse{
+			NewKey:            FromProto(resp.GetNewKey()),
+			OldKey:            FromProto(resp.GetOldKey()),
+			GracePeriodEndsAt: fromTimestamp(resp.GetGracePeriodEndsAt()),
+		}
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	return nil
+}
+
+// ToProto converts k to its protobuf form.
+func ToProto(k *apikeysclient.APIKey) *apikeyspb.APIKey {
+	pk := &apikeyspb.APIKey{
+		ApiKey:       k.APIKey,
+		Valid:        k.Valid,
+		IsActive:     k.IsActive,
+		ServiceName:  k.ServiceName,
+		Scopes:       k.Scopes,
+		Name:         k.Name,
+		Description:  k.Description,
+		Labels:       k.Labels,
+		KeyPrefix:    k.KeyPrefix,
+		Restrictions: restrictionsToProto(k.Restrictions),
+		Version:      k.Version,
+		RateLimit:    rateLimitToProto(k.RateLimit),
+		Quota:        quotaToProto(k.Quota),
+		KeyHash:      k.KeyHash,
+		Status:       string(k.Status),
+	}
+	if k.ID != uuid.Nil {
+		pk.Id = k.ID.String()
+	}
+	if k.ServiceAccountID != uuid.Nil {
+		pk.ServiceAccountId = k.ServiceAccountID.String()
+	}
+	if !k.CreatedAt.IsZero() {
+		pk.CreatedAt = timestamppb.New(k.CreatedAt)
+	}
+	if !k.UpdatedAt.IsZero() {
+		pk.UpdatedAt = timestamppb.New(k.UpdatedAt)
+	}
+	if k.ExpiresAt != nil {
+		pk.ExpiresAt = timestamppb.New(*k.ExpiresAt)
+	}
+	return pk
+}
+
+// FromProto converts pk from its protobuf form. A nil pk converts to the
+// zero APIKey.
+func FromProto(pk *apikeyspb.APIKey) apikeysclient.APIKey {
+	if pk == nil {
+		return apikeysclient.APIKey{}
+	}
+
+	// Malformed IDs from the server decode as uuid.Nil.
+	id, _ := uuid.Parse(pk.GetId())
+	serviceAccountID, _ := uuid.Parse(pk.GetServiceAccountId())
+
+	return apikeysclient.APIKey{
+		ID:               id,
+		ServiceAccountID: serviceAccountID,
+		APIKey:           pk.GetApiKey(),
+		CreatedAt:        fromTimestamp(pk.GetCreatedAt()),
+		UpdatedAt:        fromTimestamp(pk.GetUpdatedAt()),
+		Valid:            pk.GetValid(),
+		IsActive:         pk.GetIsActive(),
+		ServiceName:      pk.GetServiceName(),
+		ExpiresAt:        fromTimestampPtr(pk.GetExpiresAt()),
+		Scopes:           pk.GetScopes(),
+		Name:             pk.GetName(),
+		Description:      pk.GetDescription(),
+		Labels:           pk.GetLabels(),
+		KeyPrefix:        pk.GetKeyPrefix(),
+		Restrictions:     restrictionsFromProto(pk.GetRestrictions()),
+		Version:          pk.GetVersion(),
+		RateLimit:        rateLimitFromProto(pk.GetRateLimit()),
+		Quota:            quotaFromProto(pk.GetQuota()),
+		KeyHash:          pk.GetKeyHash(),
+		Status:           apikeysclient.KeyStatus(pk.GetStatus()),
+	}
+}
+
+// ValidationFromProto converts resp from its protobuf form.
+func ValidationFromProto(resp *apikeyspb.ValidateAPIKeyResponse) apikeysclient.ValidateResponse {
+	v := apikeysclient.ValidateResponse{
+		IsValid:   resp.GetIsValid(),
+		ExpiresAt: fromTimestampPtr(resp.GetExpiresAt()),
+	}
+	if q := resp.GetQuota(); q != nil {
+		v.Quota = &apikeysclient.QuotaUsage{
+			Limit:     q.GetLimit(),
+			Period:    apikeysclient.QuotaPeriod(q.GetPeriod()),
+			Used:      q.GetUsed(),
+			Remaining: q.GetRemaining(),
+			ResetsAt:  fromTimestamp(q.GetResetsAt()),
+		}
+	}
+	return v
+}
+
+func rateLimitToProto(l *apikeysclient.KeyRateLimit) *apikeyspb.KeyRateLimit {
+	if l == nil {
+		return nil
+	}
+	return &apikeyspb.KeyRateLimit{RequestsPerSecond: l.RequestsPerSecond, Burst: int32(l.Burst)}
+}
+
+func rateLimitFromProto(pl *apikeyspb.KeyRateLimit) *apikeysclient.KeyRateLimit {
+	if pl == nil {
+		return nil
+	}
+	return &apikeysclient.KeyRateLimit{RequestsPerSecond: pl.GetRequestsPerSecond(), Burst: int(pl.GetBurst())}
+}
+
+func quotaToProto(q *apikeysclient.Quota) *apikeyspb.Quota {
+	if q == nil {
+		return nil
+	}
+	return &apikeyspb.Quota{Limit: q.Limit, Period: string(q.Period)}
+}
+
+func quotaFromProto(pq *apikeyspb.Quota) *apikeysclient.Quota {
+	if pq == nil {
+		return nil
+	}
+	return &apikeysclient.Quota{Limit: pq.GetLimit(), Period: apikeysclient.QuotaPeriod(pq.GetPeriod())}
+}
+
+func restrictionsToProto(r *apikeysclient.Restrictions) *apikeyspb.Restrictions {
+	if r == nil {
+		return nil
+	}
+	pr := &apikeyspb.Restrictions{
+		AllowedReferrers: r.AllowedReferrers,
+		AllowedMethods:   r.AllowedMethods,
+	}
+	for _, p := range r.AllowedCIDRs {
+		pr.AllowedCidrs = append(pr.AllowedCidrs, p.String())
+	}
+	return pr
+}
+
+func restrictionsFromProto(pr *apikeyspb.Restrictions) *apikeysclient.Restrictions {
+	if pr == nil {
+		return nil
+	}
+	r := &apikeysclient.Restrictions{
+		AllowedReferrers: pr.GetAllowedReferrers(),
+		AllowedMethods:   pr.GetAllowedMethods(),
+	}
+	for _, cidr := range pr.GetAllowedCidrs() {
+		// A malformed network decodes as the invalid Prefix, which contains
+		// no address, so the key stays restricted instead of allowing all.
+		p, _ := netip.ParsePrefix(cidr)
+		r.AllowedCIDRs = append(r.AllowedCIDRs, p)
+	}
+	return r
+}
+
+func listToProto(keys []apikeysclient.APIKey) *apikeyspb.ListAPIKeysResponse {
+	resp := &apikeyspb.ListAPIKeysResponse{ApiKeys: make([]*apikeyspb.APIKey, len(keys)), TotalCount: -1}
+	for i := range keys {
+		resp.ApiKeys[i] = ToProto(&keys[i])
+	}
+	return resp
+}
+
+func validationToProto(v *apikeysclient.ValidateResponse) *apikeyspb.ValidateAPIKeyResponse {
+	resp := &apikeyspb.ValidateAPIKeyResponse{IsValid: v.IsValid}
+	if v.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(*v.ExpiresAt)
+	}
+	if q := v.Quota; q != nil {
+		resp.Quota = &apikeyspb.QuotaUsage{
+			Limit:     q.Limit,
+			Period:    string(q.Period),
+			Used:      q.Used,
+			Remaining: q.Remaining,
+		}
+		if !q.ResetsAt.IsZero() {
+			resp.Quota.ResetsAt = timestamppb.New(q.ResetsAt)
+		}
+	}
+	return resp
+}
+
+func rotationToProto(r *apikeysclient.RotateAPIKeyResponse) *apikeyspb.RotateAPIKeyResponse {
+	resp := &apikeyspb.RotateAPIKeyResponse{NewKey: ToProto(&r.NewKey), OldKey: ToProto(&r.OldKey)}
+	if !r.GracePeriodEndsAt.IsZero() {
+		resp.GracePeriodEndsAt = timestamppb.New(r.GracePeriodEndsAt)
+	}
+	return resp
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func fromTimestampPtr(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
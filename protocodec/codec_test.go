@@ -0,0 +1,105 @@
+package protocodec_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+	"github.com/PiccoloMondoC/apikeysclient/apikeyspb"
+	"github.com/PiccoloMondoC/apikeysclient/protocodec"
+)
+
+func TestRestrictionsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		restrictions *apikeysclient.Restrictions
+	}{
+		{"none", nil},
+		{"all", &apikeysclient.Restrictions{
+			AllowedCIDRs:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")},
+			AllowedReferrers: []string{"*.example.com"},
+			AllowedMethods:   []string{"GET"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := apikeysclient.APIKey{ID: uuid.New(), Restrictions: tt.restrictions}
+			got := protocodec.FromProto(protocodec.ToProto(&key))
+			if !reflect.DeepEqual(got.Restrictions, tt.restrictions) {
+				t.Errorf("Restrictions = %+v, want %+v", got.Restrictions, tt.restrictions)
+			}
+		})
+	}
+}
+
+func TestMalformedCIDRStaysRestricted(t *testing.T) {
+	key := protocodec.FromProto(&apikeyspb.APIKey{
+		Restrictions: &apikeyspb.Restrictions{AllowedCidrs: []string{"not a network"}},
+	})
+	err := apikeysclient.CheckRestrictions(&key, apikeysclient.RequestMeta{RemoteIP: netip.MustParseAddr("192.0.2.1")})
+	if err == nil {
+		t.Error("CheckRestrictions allowed a key with a malformed network")
+	}
+}
+
+func TestLimitsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		rateLimit *apikeysclient.KeyRateLimit
+		quota     *apikeysclient.Quota
+	}{
+		{"none", nil, nil},
+		{"rate limit", &apikeysclient.KeyRateLimit{RequestsPerSecond: 2.5, Burst: 10}, nil},
+		{"quota", nil, &apikeysclient.Quota{Limit: 1000, Period: apikeysclient.QuotaPerDay}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := apikeysclient.APIKey{ID: uuid.New(), RateLimit: tt.rateLimit, Quota: tt.quota}
+			got := protocodec.FromProto(protocodec.ToProto(&key))
+			if !reflect.DeepEqual(got.RateLimit, tt.rateLimit) || !reflect.DeepEqual(got.Quota, tt.quota) {
+				t.Errorf("limits = %+v, %+v; want %+v, %+v", got.RateLimit, got.Quota, tt.rateLimit, tt.quota)
+			}
+		})
+	}
+}
+
+func TestValidationQuotaRoundTrip(t *testing.T) {
+	resetsAt := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	want := apikeysclient.ValidateResponse{
+		IsValid: true,
+		Quota: &apikeysclient.QuotaUsage{
+			Limit: 10, Period: apikeysclient.QuotaPerDay, Used: 11, Remaining: 0, ResetsAt: resetsAt,
+		},
+	}
+
+	var codec protocodec.Codec
+	data, err := codec.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got apikeysclient.ValidateResponse
+	if err := codec.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateResponse = %+v, want %+v", got, want)
+	}
+}
+
+func TestHashAndStatusRoundTrip(t *testing.T) {
+	key := apikeysclient.APIKey{
+		ID:      uuid.New(),
+		KeyHash: apikeysclient.HashAPIKey("ak_test"),
+		Status:  apikeysclient.KeySuspended,
+	}
+	got := protocodec.FromProto(protocodec.ToProto(&key))
+	if got.KeyHash != key.KeyHash || got.Status != key.Status {
+		t.Errorf("KeyHash, Status = %q, %q; want %q, %q", got.KeyHash, got.Status, key.KeyHash, key.Status)
+	}
+}
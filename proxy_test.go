@@ -0,0 +1,57 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// TestHeaderAllowListKeepsClientHeaders checks that an allow-list only
+// filters the headers of interceptors, not those giving a call its meaning.
+func TestHeaderAllowListKeepsClientHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL,
+		apikeysclient.WithHeaderAllowList("X-Trace-ID"),
+		apikeysclient.WithDryRun(),
+		apikeysclient.WithTenant(apikeysclient.Tenant{OrgID: "org", ProjectID: "project"}),
+		apikeysclient.WithActor("operator@example.com"),
+		apikeysclient.WithRequestInterceptor(func(req *http.Request) error {
+			req.Header.Set("X-Trace-ID", "trace")
+			req.Header.Set("X-Debug", "on")
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := apikeysclient.ContextWithIdempotencyKey(context.Background(), "create-1")
+	if _, err := client.CreateKey(ctx, apikeysclient.CreateAPIKeyRequest{ServiceAccountID: uuid.New()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		apikeysclient.DryRunHeader:         "true",
+		apikeysclient.OrgIDHeader:          "org",
+		apikeysclient.ProjectIDHeader:      "project",
+		apikeysclient.ActorHeader:          "operator@example.com",
+		apikeysclient.IdempotencyKeyHeader: "create-1",
+		"X-Trace-Id":                       "trace",
+		"X-Debug":                          "",
+	} {
+		if v := got.Get(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+}
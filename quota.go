@@ -0,0 +1,162 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrQuotaExceeded is returned by CheckQuota, and passed to middleware error
+// handlers as a *QuotaExceededError, for keys the server reported over
+// their quota.
+var ErrQuotaExceeded = errors.New("API key quota exceeded")
+
+// KeyRateLimit bounds the request rate of a key, as enforced by the server.
+type KeyRateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+
+	// Burst is the number of requests allowed at once above the rate.
+	Burst int `json:"burst,omitempty"`
+}
+
+// QuotaPeriod is the window over which a Quota is counted. Windows are
+// aligned to UTC calendar boundaries.
+type QuotaPeriod string
+
+// Quota periods.
+const (
+	QuotaPerMinute QuotaPeriod = "minute"
+	QuotaPerHour   QuotaPeriod = "hour"
+	QuotaPerDay    QuotaPeriod = "day"
+	QuotaPerMonth  QuotaPeriod = "month"
+)
+
+// Quota caps the number of requests a key may authenticate per Period, as
+// product tiers do. A zero Limit means unlimited.
+type Quota struct {
+	Limit  int64       `json:"limit"`
+	Period QuotaPeriod `json:"period"`
+}
+
+// IsZero reports whether q sets no limit.
+func (q *Quota) IsZero() bool {
+	return q == nil || q.Limit <= 0
+}
+
+// QuotaUsage reports how much of its quota a key used in the current
+// period.
+type QuotaUsage struct {
+	Limit  int64       `json:"limit"`
+	Period QuotaPeriod `json:"period,omitempty"`
+
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+
+	// ResetsAt is when the current period ends, zero for keys without a
+	// quota.
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// Exceeded reports whether the key was used more than its limit in the
+// period. Validation responses count the request being validated, so the
+// request using the last of the quota is not over it.
+func (u *QuotaUsage) Exceeded() bool {
+	return u != nil && u.Limit > 0 && u.Used > u.Limit
+}
+
+// QuotaExceededError is the error of a key over its quota. It matches
+// ErrQuotaExceeded with errors.Is.
+type QuotaExceededError struct {
+	Usage QuotaUsage
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%v: %d of %d requests per %s used, resets at %s",
+		ErrQuotaExceeded, e.Usage.Used, e.Usage.Limit, e.Usage.Period, e.Usage.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// SetHeaders sets the X-RateLimit-Limit, X-RateLimit-Remaining,
+// X-RateLimit-Reset and Retry-After headers of a 429 response for e.
+func (e *QuotaExceededError) SetHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.FormatInt(e.Usage.Limit, 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatInt(max(e.Usage.Remaining, 0), 10))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(e.Usage.ResetsAt.Unix(), 10))
+	retryAfter := int64(time.Until(e.Usage.ResetsAt).Round(time.Second) / time.Second)
+	h.Set("Retry-After", strconv.FormatInt(max(retryAfter, 1), 10))
+}
+
+// SetKeyQuota replaces the quota of the key with the given id and returns
+// the updated key. A zero Quota lifts it. Rate limits are set with the rest
+// of the key through UpdateAPIKey.
+func (c *Client) SetKeyQuota(ctx context.Context, id uuid.UUID, quota Quota) (*APIKey, error) {
+	var key APIKey
+	_, err := c.do(ctx, &request{
+		op:     "SetKeyQuota",
+		keyID:  id,
+		method: http.MethodPut,
+		url:    c.endpoint("apikeys", id.String(), "quota"),
+		body:   quota,
+		in:     SetKeyQuotaInput{ID: id, Quota: quota},
+	}, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetKeyQuotaUsage retrieves how much of its quota the key with the given id
+// used in the current period. Keys without a quota report a zero Limit.
+func (c *Client) GetKeyQuotaUsage(ctx context.Context, id uuid.UUID) (*QuotaUsage, error) {
+	var usage QuotaUsage
+	_, err := c.do(ctx, &request{
+		op:     "GetKeyQuotaUsage",
+		keyID:  id,
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys", id.String(), "quota"),
+		in:     id,
+	}, &usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// CheckQuota fails with a *QuotaExceededError if the server's last
+// validation of apikey reported it over quota and the period has not reset
+// since. It makes no request: quota state comes with validation responses,
+// so keys answered from the validation cache keep the state of the
+// validation that filled it.
+func (c *Client) CheckQuota(apikey string) error {
+	hash := HashAPIKey(apikey)
+	v, ok := c.overQuota.Load(hash)
+	if !ok {
+		return nil
+	}
+	usage := v.(QuotaUsage)
+	if !time.Now().Before(usage.ResetsAt) {
+		c.overQuota.CompareAndDelete(hash, v)
+		return nil
+	}
+	return &QuotaExceededError{Usage: usage}
+}
+
+// recordQuota remembers the quota state of a validation response for
+// CheckQuota. Only keys over quota are kept, until their period resets.
+func (c *Client) recordQuota(hash string, usage *QuotaUsage) {
+	if usage.Exceeded() && time.Now().Before(usage.ResetsAt) {
+		c.overQuota.Store(hash, *usage)
+		return
+	}
+	c.overQuota.Delete(hash)
+}
@@ -0,0 +1,109 @@
+package apikeysclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitState is the server's rate limit as last reported in the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset response
+// headers.
+type RateLimitState struct {
+	// Limit is the number of requests allowed per window.
+	Limit int
+
+	// Remaining is the number of requests left in the current window.
+	Remaining int
+
+	// Reset is when the current window ends, zero if not reported.
+	Reset time.Time
+
+	// ObservedAt is when the headers were received.
+	ObservedAt time.Time
+}
+
+// RateLimitState returns the most recently reported rate limit, and false if
+// no response carried rate limit headers yet.
+func (c *Client) RateLimitState() (RateLimitState, bool) {
+	state := c.rateLimit.Load()
+	if state == nil {
+		return RateLimitState{}, false
+	}
+	return *state, true
+}
+
+// WithRateLimiter paces requests through l, typically to keep bulk
+// operations under the server's limit. Every call waits for a token before
+// it is sent; its retries are paced by the retry policy. When the server
+// reports its limit exhausted, calls also wait until the reported reset.
+func WithRateLimiter(l *rate.Limiter) Option {
+	return func(c *Client, _ *options) {
+		c.limiter = l
+	}
+}
+
+// WithRateLimit is WithRateLimiter with a new limiter allowing r requests per
+// second with bursts of up to burst requests.
+func WithRateLimit(r rate.Limit, burst int) Option {
+	return WithRateLimiter(rate.NewLimiter(r, burst))
+}
+
+// throttle waits until the client-side limiter allows another request.
+func (c *Client) throttle(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	if state := c.rateLimit.Load(); state != nil && state.Remaining <= 0 && state.Limit > 0 {
+		if err := sleep(ctx, time.Until(state.Reset)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limit: %w", err)
+	}
+	return nil
+}
+
+// recordRateLimit stores the rate limit reported in h, if any.
+func (c *Client) recordRateLimit(h http.Header) {
+	v := h.Get("X-RateLimit-Limit")
+	if v == "" {
+		// Checked first, as parse errors allocate.
+		return
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	state := &RateLimitState{Limit: limit, Remaining: remaining, ObservedAt: now}
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil && reset >= 0 {
+		state.Reset = parseRateLimitReset(reset, now)
+	}
+
+	c.rateLimit.Store(state)
+}
+
+// resetEpochThreshold separates X-RateLimit-Reset values given as seconds
+// until the reset from those given as a Unix time; some servers send one,
+// some the other.
+const resetEpochThreshold = 1_000_000_000
+
+func parseRateLimitReset(v int64, now time.Time) time.Time {
+	if v >= resetEpochThreshold {
+		return time.Unix(v, 0)
+	}
+	return now.Add(time.Duration(v) * time.Second)
+}
@@ -0,0 +1,454 @@
+package apikeysclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errEmptyBody is returned by do when a response body was expected but the
+// server sent none.
+var errEmptyBody = errors.New("empty response body")
+
+// request describes a single call to the keys server.
+type request struct {
+	// op is the name of the client method making the call.
+	op string
+
+	// keyID is the ID of the key the call is about, if any.
+	keyID uuid.UUID
+
+	method string
+	url    string
+	query  url.Values
+	body   any
+
+	// accept overrides the Accept header, which defaults to JSON.
+	accept string
+
+	// contentType overrides the Content-Type header of the body, which
+	// defaults to JSON.
+	contentType string
+
+	// ifNoneMatch is sent as the If-None-Match header to revalidate a
+	// cached response.
+	ifNoneMatch string
+
+	// ifMatch is sent as the If-Match header to make a change conditional
+	// on the version of its target.
+	ifMatch string
+
+	// in is the call's input handed to a custom Transport; see Call.
+	in any
+
+	// idempotencyKey is sent as the Idempotency-Key header of every attempt.
+	idempotencyKey string
+
+	// idempotent marks requests that are safe to retry whatever their
+	// method, such as lookups sent as POST to keep keys out of URLs.
+	idempotent bool
+
+	// secret marks calls whose result is meant to carry key material, which
+	// WithRedactedSecrets leaves in place.
+	secret bool
+
+	// elevated marks calls authorized with the elevated token source.
+	elevated bool
+
+	// tenant scopes the call to another tenant than the client's.
+	tenant Tenant
+
+	// attempts is the number of HTTP exchanges made for the call so far.
+	attempts int
+
+	// stream marks responses whose body is consumed incrementally by the
+	// caller and must not be buffered.
+	stream bool
+
+	// idleTimeout, for streamed responses, bounds each wait for more of the
+	// body, zero for no bound.
+	idleTimeout time.Duration
+}
+
+// do sends r, retrying according to c.Retry, and decodes a successful JSON
+// response into out when out is non-nil. A response whose status code is not
+// in expected (200 when empty) or accepted for r.op with WithAcceptedStatus
+// is turned into an *APIError; 202 and 204 responses are not decoded. The returned
+// response's body has already been consumed and closed; it is returned so
+// callers can inspect status and headers.
+func (c *Client) do(ctx context.Context, r *request, out any, expected ...int) (*http.Response, error) {
+	if r.keyID != uuid.Nil && r.method != http.MethodGet {
+		// The call changes the key, so its cached record is stale.
+		defer c.forgetKey(ctx, r.keyID)
+	}
+
+	if c.transport != nil {
+		resp, err := c.roundTrip(ctx, r, out)
+		if err == nil {
+			c.prepareKeys(r, out)
+		}
+		return resp, err
+	}
+
+	resp, err := c.open(ctx, r, expected...)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		if err := c.decodeResponse(resp, out); err != nil {
+			return resp, err
+		}
+		c.prepareKeys(r, out)
+	}
+
+	return resp, nil
+}
+
+// decodeJSONResponse decodes the JSON body of resp into out, unwrapping it
+// if it is enveloped.
+func decodeJSONResponse(resp *http.Response, out any) error {
+	if isEnveloped(resp) {
+		return unwrapEnvelope(resp, out)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errEmptyBody
+		}
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// open sends r like do but returns the response with its body unread. The
+// caller must close it. On error the body is already closed. Streaming is
+// only available over REST.
+func (c *Client) open(ctx context.Context, r *request, expected ...int) (resp *http.Response, err error) {
+	if c.transport != nil {
+		return nil, ErrUnsupportedOperation
+	}
+
+	var cancel context.CancelFunc
+	opened := func() {}
+	if r.stream {
+		ctx, cancel, opened = c.streamContext(ctx, r.op)
+	} else {
+		ctx, cancel = c.callContext(ctx, r.op)
+	}
+	defer func() {
+		if err != nil {
+			cancel()
+			return
+		}
+		// The call lasts until the caller is done with the body.
+		body := resp.Body
+		if r.stream && r.idleTimeout > 0 {
+			body = newIdleTimeoutBody(body, r.idleTimeout, cancel)
+		}
+		resp.Body = &cancelOnClose{ReadCloser: body, cancel: cancel}
+	}()
+
+	if c.telemetry != nil {
+		var end func(*http.Response, error)
+		ctx, end = c.telemetry.start(ctx, r)
+		defer func() { end(resp, err) }()
+	}
+	if c.observer != nil {
+		end := c.observeRequest(r.op)
+		defer func() { end(err) }()
+	}
+
+	expected = c.expectedStatus(r.op, expected)
+
+	if r.accept == "" && c.apiVersion != "" && c.negotiatedVersion(ctx) == APIVersion2 {
+		r.accept = MediaTypeV2
+	}
+
+	var body []byte
+	if r.body != nil {
+		body, err = json.Marshal(r.body)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	if err := c.throttle(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.breaker != nil && !c.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err = c.send(ctx, r, body)
+	opened()
+	if c.breaker != nil {
+		if errors.Is(err, context.Canceled) {
+			c.breaker.release()
+		} else {
+			c.breaker.record(!isServerFailure(resp, err))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(expected, resp.StatusCode) {
+		defer resp.Body.Close()
+		return resp, newAPIError(resp)
+	}
+
+	return resp, nil
+}
+
+// opMethods maps the ops of the alternative forms of a call, picked by the
+// client according to its options and the server's capabilities, to the
+// method making them, so that method's timeout covers every form.
+var opMethods = map[string]string{
+	"ValidateAPIKeyHash": "ValidateAPIKey",
+	"ValidateAPIKeyPOST": "ValidateAPIKey",
+	"GetAPIKeyByHash":    "GetAPIKeyByAPIKey",
+	"LookupAPIKey":       "GetAPIKeyByAPIKey",
+}
+
+// statusOK is the default of expectedStatus, shared to save allocating it
+// for every call. It is never appended to in place.
+var statusOK = []int{http.StatusOK}
+
+// expectedStatus returns the status codes of successful responses to op:
+// expected, 200 when empty, and those accepted with WithAcceptedStatus.
+func (c *Client) expectedStatus(op string, expected []int) []int {
+	if len(expected) == 0 {
+		expected = statusOK
+	}
+	accepted, ok := c.acceptedStatus[op]
+	if !ok {
+		accepted = c.acceptedStatus[opMethods[op]]
+	}
+	if len(accepted) == 0 {
+		return expected
+	}
+	return append(slices.Clip(expected), accepted...)
+}
+
+// callContext returns ctx bounded by the call timeout of op, if any.
+func (c *Client) callContext(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	d := c.timeout(op)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// streamContext is callContext for calls whose response is streamed: the
+// call timeout only bounds the wait for the response, until opened is
+// called, since the body is then read for as long as the caller wants.
+func (c *Client) streamContext(ctx context.Context, op string) (_ context.Context, cancel context.CancelFunc, opened func()) {
+	ctx, cancel = context.WithCancel(ctx)
+	d := c.timeout(op)
+	if d <= 0 {
+		return ctx, cancel, func() {}
+	}
+	timer := time.AfterFunc(d, cancel)
+	return ctx, cancel, func() { timer.Stop() }
+}
+
+// timeout returns the call timeout of op, zero for none.
+func (c *Client) timeout(op string) time.Duration {
+	d, ok := c.methodTimeouts[op]
+	if !ok {
+		d, ok = c.methodTimeouts[opMethods[op]]
+	}
+	if !ok {
+		d = c.callTimeout
+	}
+	return d
+}
+
+// httpClient returns the http.Client sending r. The Timeout of c.HttpClient
+// also covers reading the response body, so it does not apply to streamed
+// responses.
+func (c *Client) httpClient(r *request) *http.Client {
+	if !r.stream || c.HttpClient.Timeout == 0 {
+		return c.HttpClient
+	}
+	hc := *c.HttpClient
+	hc.Timeout = 0
+	return &hc
+}
+
+// cancelOnClose releases the context of a call when its response body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// idleTimeoutBody fails reads of a streamed body that wait longer than
+// timeout for data, by cancelling the call.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		b.timedOut.Store(true)
+		cancel()
+	})
+	b.timer.Stop()
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && b.timedOut.Load() {
+		return n, fmt.Errorf("no data for %v: %w", b.timeout, context.DeadlineExceeded)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
+
+// retryable reports whether r may be sent again after a failed attempt.
+func (r *request) retryable() bool {
+	return r.idempotent || r.idempotencyKey != "" || isIdempotent(r.method)
+}
+
+// mutates reports whether r changes state on the server. Lookups are sent as
+// idempotent POSTs and do not.
+func (r *request) mutates() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead:
+		return false
+	case http.MethodPost:
+		return !r.idempotent
+	}
+	return true
+}
+
+// send performs the HTTP exchange for r, building a fresh *http.Request for
+// every attempt so the body can be replayed on retries. Every request made
+// by the client goes through send, which runs the interceptor chains.
+func (c *Client) send(ctx context.Context, r *request, body []byte) (*http.Response, error) {
+	endpoint := r.url
+	if len(r.query) > 0 {
+		endpoint += "?" + r.query.Encode()
+	}
+
+	for attempt := 1; ; attempt++ {
+		r.attempts = attempt
+
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, r.method, endpoint, reader)
+		if err != nil {
+			return nil, fmt.Errorf("create %s request: %w", r.method, err)
+		}
+
+		if body != nil {
+			contentType := r.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Accept", c.acceptHeader(r))
+		if c.UserAgent != "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+		if r.idempotencyKey != "" {
+			req.Header.Set(IdempotencyKeyHeader, r.idempotencyKey)
+		}
+		if r.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", r.ifNoneMatch)
+		}
+		if r.ifMatch != "" {
+			req.Header.Set("If-Match", r.ifMatch)
+		}
+		if c.isDryRun(ctx, r) {
+			req.Header.Set(DryRunHeader, "true")
+		}
+		if actor := c.requestActor(ctx, r); actor != "" {
+			req.Header.Set(ActorHeader, actor)
+		}
+		c.setTenantHeaders(req, r)
+
+		if err := c.authorize(ctx, req, r.elevated); err != nil {
+			return nil, err
+		}
+
+		allowed := c.allowedHeaders(ctx)
+		var own []string
+		if allowed != nil {
+			own = slices.Collect(maps.Keys(req.Header))
+		}
+		if err := c.interceptRequest(req); err != nil {
+			return nil, err
+		}
+		c.filterHeaders(req, allowed, own)
+		if err := c.signRequest(req, body); err != nil {
+			return nil, err
+		}
+
+		c.logRequest(ctx, r, req, body, attempt)
+
+		began := time.Now()
+		resp, err := c.httpClient(r).Do(req)
+		c.logResponse(ctx, r, resp, err, time.Since(began))
+		if err == nil {
+			c.recordRateLimit(resp.Header)
+			if err := c.interceptResponse(resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+
+		if !c.Retry.shouldRetry(ctx, r.retryable(), attempt, resp, err) {
+			if err != nil {
+				return nil, fmt.Errorf("send %s request: %w", r.method, err)
+			}
+			return resp, nil
+		}
+
+		wait := c.Retry.backoff(attempt, resp)
+		if resp != nil {
+			// Drain the body so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, fmt.Errorf("send %s request: %w", r.method, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrRestricted is returned by CheckRestrictions, and passed to middleware
+// error handlers, for requests a key's Restrictions do not allow.
+var ErrRestricted = errors.New("request not allowed by API key restrictions")
+
+// Restrictions limit where a key may be used from. Empty fields allow
+// anything; a request must satisfy every non-empty field.
+type Restrictions struct {
+	// AllowedCIDRs lists the networks requests may come from.
+	AllowedCIDRs []netip.Prefix `json:"allowed_cidrs,omitempty"`
+
+	// AllowedReferrers lists the origins requests may be made from, as
+	// reported by their Origin or Referer header. An entry is a host, such
+	// as "app.example.com", optionally preceded by a scheme, as in
+	// "https://app.example.com", and optionally followed by a port. A
+	// leading "*." matches any subdomain.
+	AllowedReferrers []string `json:"allowed_referrers,omitempty"`
+
+	// AllowedMethods lists the HTTP methods requests may use.
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+}
+
+// IsZero reports whether r restricts nothing.
+func (r *Restrictions) IsZero() bool {
+	return r == nil || len(r.AllowedCIDRs) == 0 && len(r.AllowedReferrers) == 0 && len(r.AllowedMethods) == 0
+}
+
+// RequestMeta describes an inbound request for CheckRestrictions.
+type RequestMeta struct {
+	// RemoteIP is the address of the client, after any trusted proxies.
+	RemoteIP netip.Addr
+
+	// Origin and Referrer are the values of the Origin and Referer headers.
+	Origin   string
+	Referrer string
+
+	Method string
+}
+
+// RequestMetaFromHTTP returns the RequestMeta of r, taking the client
+// address from r.RemoteAddr. Behind a proxy, build the RequestMeta from the
+// forwarded address instead and pass it to WithRequestMeta.
+func RequestMetaFromHTTP(r *http.Request) RequestMeta {
+	meta := RequestMeta{
+		Origin:   r.Header.Get("Origin"),
+		Referrer: r.Referer(),
+		Method:   r.Method,
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		meta.RemoteIP = addr.Unmap()
+	}
+	return meta
+}
+
+// CheckRestrictions reports whether key may be used for the request
+// described by meta, failing with ErrRestricted when its Restrictions do
+// not allow it. Keys without restrictions allow every request.
+func CheckRestrictions(key *APIKey, meta RequestMeta) error {
+	r := key.Restrictions
+	if r.IsZero() {
+		return nil
+	}
+
+	if len(r.AllowedCIDRs) > 0 {
+		ip := meta.RemoteIP.Unmap()
+		if !slices.ContainsFunc(r.AllowedCIDRs, func(p netip.Prefix) bool { return ip.IsValid() && p.Contains(ip) }) {
+			return fmt.Errorf("%w: address %s", ErrRestricted, meta.RemoteIP)
+		}
+	}
+
+	if len(r.AllowedMethods) > 0 {
+		if !slices.ContainsFunc(r.AllowedMethods, func(m string) bool { return strings.EqualFold(m, meta.Method) }) {
+			return fmt.Errorf("%w: method %s", ErrRestricted, meta.Method)
+		}
+	}
+
+	if len(r.AllowedReferrers) > 0 {
+		origin := requestOrigin(meta)
+		if origin == nil || !slices.ContainsFunc(r.AllowedReferrers, func(p string) bool { return matchReferrer(p, origin) }) {
+			source := meta.Origin
+			if source == "" {
+				source = meta.Referrer
+			}
+			return fmt.Errorf("%w: referrer %q", ErrRestricted, source)
+		}
+	}
+
+	return nil
+}
+
+// requestOrigin returns the scheme and host the request was made from,
+// preferring its Origin header over its Referer.
+func requestOrigin(meta RequestMeta) *url.URL {
+	for _, raw := range []string{meta.Origin, meta.Referrer} {
+		if raw == "" || raw == "null" {
+			continue
+		}
+		if u, err := url.Parse(raw); err == nil && u.Host != "" {
+			return u
+		}
+	}
+	return nil
+}
+
+// matchReferrer reports whether origin matches the AllowedReferrers entry
+// pattern.
+func matchReferrer(pattern string, origin *url.URL) bool {
+	if scheme, rest, ok := strings.Cut(pattern, "://"); ok {
+		if !strings.EqualFold(scheme, origin.Scheme) {
+			return false
+		}
+		pattern = rest
+	}
+	// Paths are not part of an origin.
+	pattern, _, _ = strings.Cut(pattern, "/")
+
+	host := origin.Hostname()
+	if hostname, port, err := net.SplitHostPort(pattern); err == nil {
+		if port != origin.Port() {
+			return false
+		}
+		pattern = hostname
+	} else {
+		// Bracketed IPv6 hosts without a port.
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "["), "]")
+	}
+
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return len(host) > len(suffix) && strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(suffix))
+	}
+	return strings.EqualFold(host, pattern)
+}
+
+// GetRestrictions retrieves the restrictions of the key with the given id.
+// Keys without restrictions return a zero Restrictions.
+func (c *Client) GetRestrictions(ctx context.Context, id uuid.UUID) (*Restrictions, error) {
+	var restrictions Restrictions
+	_, err := c.do(ctx, &request{
+		op:     "GetRestrictions",
+		keyID:  id,
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys", id.String(), "restrictions"),
+		in:     id,
+	}, &restrictions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &restrictions, nil
+}
+
+// SetRestrictions replaces the restrictions of the key with the given id and
+// returns the updated key. A zero Restrictions lifts them all.
+func (c *Client) SetRestrictions(ctx context.Context, id uuid.UUID, restrictions Restrictions) (*APIKey, error) {
+	var key APIKey
+	_, err := c.do(ctx, &request{
+		op:     "SetRestrictions",
+		keyID:  id,
+		method: http.MethodPut,
+		url:    c.endpoint("apikeys", id.String(), "restrictions"),
+		body:   restrictions,
+		in:     SetRestrictionsInput{ID: id, Restrictions: restrictions},
+	}, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
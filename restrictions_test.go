@@ -0,0 +1,110 @@
+package apikeysclient_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestCheckRestrictions(t *testing.T) {
+	office := netip.MustParseAddr("10.1.2.3")
+	tests := []struct {
+		name         string
+		restrictions *apikeysclient.Restrictions
+		meta         apikeysclient.RequestMeta
+		wantErr      bool
+	}{
+		{"none", nil, apikeysclient.RequestMeta{}, false},
+		{"empty", &apikeysclient.Restrictions{}, apikeysclient.RequestMeta{}, false},
+
+		{"cidr match", cidrs("10.0.0.0/8"), apikeysclient.RequestMeta{RemoteIP: office}, false},
+		{"cidr mapped IPv4", cidrs("10.0.0.0/8"), apikeysclient.RequestMeta{RemoteIP: netip.MustParseAddr("::ffff:10.1.2.3")}, false},
+		{"cidr IPv6", cidrs("10.0.0.0/8", "2001:db8::/32"), apikeysclient.RequestMeta{RemoteIP: netip.MustParseAddr("2001:db8::1")}, false},
+		{"cidr mismatch", cidrs("10.0.0.0/8"), apikeysclient.RequestMeta{RemoteIP: netip.MustParseAddr("192.168.0.1")}, true},
+		{"cidr unknown address", cidrs("10.0.0.0/8"), apikeysclient.RequestMeta{}, true},
+
+		{"method match", &apikeysclient.Restrictions{AllowedMethods: []string{"GET", "head"}}, apikeysclient.RequestMeta{Method: "HEAD"}, false},
+		{"method mismatch", &apikeysclient.Restrictions{AllowedMethods: []string{"GET"}}, apikeysclient.RequestMeta{Method: "POST"}, true},
+
+		{"referrer host", referrers("app.example.com"), apikeysclient.RequestMeta{Origin: "https://app.example.com"}, false},
+		{"referrer host case", referrers("App.Example.com"), apikeysclient.RequestMeta{Origin: "https://app.example.COM"}, false},
+		{"referrer host any port", referrers("app.example.com"), apikeysclient.RequestMeta{Origin: "https://app.example.com:8443"}, false},
+		{"referrer other host", referrers("app.example.com"), apikeysclient.RequestMeta{Origin: "https://evil.example.com"}, true},
+		{"referrer suffix is not a subdomain", referrers("example.com"), apikeysclient.RequestMeta{Origin: "https://notexample.com"}, true},
+		{"referrer scheme", referrers("https://app.example.com"), apikeysclient.RequestMeta{Origin: "https://app.example.com"}, false},
+		{"referrer scheme mismatch", referrers("https://app.example.com"), apikeysclient.RequestMeta{Origin: "http://app.example.com"}, true},
+		{"referrer port", referrers("localhost:3000"), apikeysclient.RequestMeta{Origin: "http://localhost:3000"}, false},
+		{"referrer port mismatch", referrers("localhost:3000"), apikeysclient.RequestMeta{Origin: "http://localhost:4000"}, true},
+		{"referrer port required", referrers("localhost:3000"), apikeysclient.RequestMeta{Origin: "http://localhost"}, true},
+		{"referrer IPv6", referrers("[::1]"), apikeysclient.RequestMeta{Origin: "http://[::1]:3000"}, false},
+		{"referrer IPv6 port", referrers("http://[::1]:3000"), apikeysclient.RequestMeta{Origin: "http://[::1]:3000"}, false},
+		{"referrer pattern path ignored", referrers("https://app.example.com/login"), apikeysclient.RequestMeta{Origin: "https://app.example.com"}, false},
+		{"wildcard subdomain", referrers("*.example.com"), apikeysclient.RequestMeta{Origin: "https://app.example.com"}, false},
+		{"wildcard nested subdomain", referrers("*.example.com"), apikeysclient.RequestMeta{Origin: "https://a.b.example.com"}, false},
+		{"wildcard excludes apex", referrers("*.example.com"), apikeysclient.RequestMeta{Origin: "https://example.com"}, true},
+		{"wildcard suffix is not a subdomain", referrers("*.example.com"), apikeysclient.RequestMeta{Origin: "https://badexample.com"}, true},
+		{"wildcard with scheme and port", referrers("https://*.example.com:8443"), apikeysclient.RequestMeta{Origin: "https://app.example.com:8443"}, false},
+		{"wildcard port mismatch", referrers("https://*.example.com:8443"), apikeysclient.RequestMeta{Origin: "https://app.example.com"}, true},
+		{"referer header", referrers("app.example.com"), apikeysclient.RequestMeta{Referrer: "https://app.example.com/page?q=1"}, false},
+		{"origin preferred to referer", referrers("app.example.com"), apikeysclient.RequestMeta{Origin: "https://evil.example.com", Referrer: "https://app.example.com/"}, true},
+		{"null origin falls back to referer", referrers("app.example.com"), apikeysclient.RequestMeta{Origin: "null", Referrer: "https://app.example.com/"}, false},
+		{"no referrer", referrers("app.example.com"), apikeysclient.RequestMeta{}, true},
+
+		{"all match", &apikeysclient.Restrictions{
+			AllowedCIDRs:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			AllowedReferrers: []string{"*.example.com"},
+			AllowedMethods:   []string{"GET"},
+		}, apikeysclient.RequestMeta{RemoteIP: office, Origin: "https://app.example.com", Method: "GET"}, false},
+		{"one fails", &apikeysclient.Restrictions{
+			AllowedCIDRs:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			AllowedReferrers: []string{"*.example.com"},
+			AllowedMethods:   []string{"GET"},
+		}, apikeysclient.RequestMeta{RemoteIP: office, Origin: "https://app.example.com", Method: "DELETE"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := apikeysclient.CheckRestrictions(&apikeysclient.APIKey{Restrictions: tt.restrictions}, tt.meta)
+			if tt.wantErr {
+				if !errors.Is(err, apikeysclient.ErrRestricted) {
+					t.Errorf("CheckRestrictions = %v, want ErrRestricted", err)
+				}
+			} else if err != nil {
+				t.Errorf("CheckRestrictions = %v", err)
+			}
+		})
+	}
+}
+
+func TestRequestMetaFromHTTP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "[::ffff:10.1.2.3]:51234"
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Referer", "https://app.example.com/page")
+
+	want := apikeysclient.RequestMeta{
+		RemoteIP: netip.MustParseAddr("10.1.2.3"),
+		Origin:   "https://app.example.com",
+		Referrer: "https://app.example.com/page",
+		Method:   http.MethodPost,
+	}
+	if got := apikeysclient.RequestMetaFromHTTP(r); got != want {
+		t.Errorf("RequestMetaFromHTTP = %+v, want %+v", got, want)
+	}
+}
+
+func cidrs(prefixes ...string) *apikeysclient.Restrictions {
+	r := &apikeysclient.Restrictions{}
+	for _, p := range prefixes {
+		r.AllowedCIDRs = append(r.AllowedCIDRs, netip.MustParsePrefix(p))
+	}
+	return r
+}
+
+func referrers(patterns ...string) *apikeysclient.Restrictions {
+	return &apikeysclient.Restrictions{AllowedReferrers: patterns}
+}
@@ -0,0 +1,162 @@
+package apikeysclient
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how failed requests are retried. Only idempotent
+// requests (GET, HEAD, PUT, DELETE, key lookups and validations sent as POST,
+// and calls carrying an idempotency key) are retried, after network errors or
+// responses with one of RetryableStatusCodes. Zero fields fall back to the values of
+// DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the exponentially growing wait between attempts.
+	// Responses whose Retry-After asks for a longer wait are not retried,
+	// so their APIError is returned at once.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the backoff grows by after each attempt.
+	Multiplier float64
+
+	// Jitter is the fraction, between 0 and 1, of each backoff that is
+	// randomized to spread retries from concurrent callers.
+	Jitter float64
+
+	// RetryableStatusCodes lists the response status codes that are retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy returns a policy retrying up to three times on 429 and
+// 5xx gateway errors with exponential backoff starting at 100ms.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// withDefaults returns a copy of p with zero fields filled in.
+func (p *RetryPolicy) withDefaults() RetryPolicy {
+	d := DefaultRetryPolicy()
+	policy := *p
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = d.MaxAttempts
+	}
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = d.InitialBackoff
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = d.MaxBackoff
+	}
+	if policy.Multiplier == 0 {
+		policy.Multiplier = d.Multiplier
+	}
+	if policy.RetryableStatusCodes == nil {
+		policy.RetryableStatusCodes = d.RetryableStatusCodes
+	}
+	return policy
+}
+
+// shouldRetry reports whether the attempt that produced resp and err should
+// be retried. A nil policy never retries.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, idempotent bool, attempt int, resp *http.Response, err error) bool {
+	if p == nil || ctx.Err() != nil || !idempotent {
+		return false
+	}
+
+	policy := p.withDefaults()
+	if attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > policy.MaxBackoff {
+		return false
+	}
+
+	return slices.Contains(policy.RetryableStatusCodes, resp.StatusCode)
+}
+
+// backoff returns how long to wait after the given attempt. A Retry-After
+// header on resp takes precedence when it asks for a longer wait; shouldRetry
+// already refused the ones beyond MaxBackoff.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	policy := p.withDefaults()
+
+	wait := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(attempt-1))
+	if wait > float64(policy.MaxBackoff) {
+		wait = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		wait -= wait * policy.Jitter * rand.Float64()
+	}
+
+	d := time.Duration(wait)
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > d {
+			d = retryAfter
+		}
+	}
+
+	return d
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
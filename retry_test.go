@@ -0,0 +1,68 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		wantHits   int32
+		wantStatus int
+	}{
+		{"within MaxBackoff", "0", 2, 0},
+		{"beyond MaxBackoff", "3600", 1, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if hits.Add(1) == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			client, err := apikeysclient.NewClient(srv.URL, apikeysclient.WithRetry(&apikeysclient.RetryPolicy{
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     50 * time.Millisecond,
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			began := time.Now()
+			_, err = client.GetAPIKeyByID(context.Background(), uuid.New())
+			if elapsed := time.Since(began); elapsed > time.Second {
+				t.Errorf("GetAPIKeyByID took %v, want no wait beyond MaxBackoff", elapsed)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("server hit %d times, want %d", got, tt.wantHits)
+			}
+
+			var apiErr *apikeysclient.APIError
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Errorf("GetAPIKeyByID error = %v, want nil", err)
+			case tt.wantStatus != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus):
+				t.Errorf("GetAPIKeyByID error = %v, want APIError %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// KeyPrefixLength is the number of leading characters of key material kept
+// in APIKey.KeyPrefix.
+const KeyPrefixLength = 8
+
+// KeyPrefix returns the displayable prefix of the key material apiKey.
+func KeyPrefix(apiKey string) string {
+	if len(apiKey) <= KeyPrefixLength {
+		return apiKey
+	}
+	return apiKey[:KeyPrefixLength]
+}
+
+// WithRedactedSecrets removes key material from every key returned by the
+// client except those of CreateKey, CreateAPIKeys and RotateAPIKey, which
+// issue it. Listed and fetched keys only carry their KeyPrefix and KeyHash,
+// so key inventories and logs built from them cannot leak secrets. Use
+// RevealAPIKey to retrieve the material of a stored key.
+func WithRedactedSecrets() Option {
+	return func(c *Client, _ *options) {
+		c.redactSecrets = true
+	}
+}
+
+// WithElevatedTokenSource authenticates calls needing elevated privileges,
+// currently RevealAPIKey, with bearer tokens from ts instead of the client's
+// regular credentials.
+func WithElevatedTokenSource(ts TokenSource) Option {
+	return func(c *Client, _ *options) {
+		c.elevatedTokenSource = ts
+	}
+}
+
+// revealResponse is the body of a reveal call.
+type revealResponse struct {
+	APIKey string `json:"api_key"`
+}
+
+// RevealAPIKey returns the key material of the key with the given id. The
+// server only allows it to callers with elevated privileges; configure
+// their credentials with WithElevatedTokenSource. It fails with ErrNotFound
+// when the server does not keep the material, e.g. because it only stores
+// key hashes.
+func (c *Client) RevealAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
+	var revealed revealResponse
+	_, err := c.do(ctx, &request{
+		op:       "RevealAPIKey",
+		keyID:    id,
+		method:   http.MethodPost,
+		url:      c.endpoint("apikeys", id.String(), "reveal"),
+		in:       id,
+		elevated: true,
+	}, &revealed)
+	if err != nil {
+		return "", err
+	}
+
+	return revealed.APIKey, nil
+}
+
+// prepareKeys fills in the KeyPrefix of the keys decoded into out by r and,
+// with WithRedactedSecrets, removes their key material unless r issues it.
+func (c *Client) prepareKeys(r *request, out any) {
+	prepare := func(k *APIKey) {
+		if k.KeyPrefix == "" && k.APIKey != "" {
+			k.KeyPrefix = KeyPrefix(k.APIKey)
+		}
+		if c.redactSecrets && !r.secret && k.APIKey != "" {
+			// The hash keeps the key identifiable, so revoking it still
+			// invalidates the caches.
+			if k.KeyHash == "" {
+				k.KeyHash = HashAPIKey(k.APIKey)
+			}
+			k.APIKey = ""
+		}
+	}
+
+	switch out := out.(type) {
+	case *APIKey:
+		prepare(out)
+	case *[]APIKey:
+		for i := range *out {
+			prepare(&(*out)[i])
+		}
+	case *RotateAPIKeyResponse:
+		prepare(&out.NewKey)
+		prepare(&out.OldKey)
+	case *SearchResults:
+		for i := range out.Results {
+			prepare(&out.Results[i].Key)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package apikeysclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Revocation identifies a revoked key by ID and by the SHA-256 digest of its
+// material, so revocations can be matched without the server publishing
+// secrets.
+type Revocation struct {
+	KeyID     uuid.UUID `json:"key_id"`
+	KeyHash   string    `json:"key_hash"`
+	RevokedAt time.Time `json:"revoked_at"`
+
+	// ExpiresAt is the expiry the key had, nil if it had none. Once it has
+	// passed the key fails validation anyway, so watchers forget it.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// RevocationMode selects how a RevocationWatcher learns about revocations.
+type RevocationMode int
+
+const (
+	// RevocationPoll polls GET /apikeys/revocations?since=.
+	RevocationPoll RevocationMode = iota
+
+	// RevocationStream consumes the server-sent events stream at
+	// GET /apikeys/revocations/stream, reconnecting as soon as it ends.
+	// The stream is not bounded by the timeouts of the client.
+	RevocationStream
+)
+
+// RevocationWatcherConfig configures a RevocationWatcher.
+type RevocationWatcherConfig struct {
+	Mode RevocationMode
+
+	// PollInterval is the wait between polls, and before reconnecting in
+	// stream mode after a failed attempt. Defaults to 5 seconds.
+	PollInterval time.Duration
+
+	// OnError, if set, is called with errors encountered while watching.
+	// Watching continues after errors.
+	OnError func(error)
+}
+
+// RevocationWatcher maintains a local set of revoked keys. Once attached to a
+// Client with WatchRevocations, revoked keys are reported invalid by
+// ValidateAPIKey and the middleware without a server round trip, and are
+// evicted from the validation cache as soon as their revocation is seen.
+type RevocationWatcher struct {
+	client *Client
+	cfg    RevocationWatcherConfig
+
+	// ids and hashes map the revoked keys to their expiry, zero for none.
+	mu       sync.RWMutex
+	ids      map[uuid.UUID]time.Time
+	hashes   map[string]time.Time
+	since    string
+	prunedAt time.Time
+}
+
+// WatchRevocations attaches a new RevocationWatcher to c and returns it. The
+// watcher does nothing until Run is called, typically in its own goroutine.
+func (c *Client) WatchRevocations(cfg RevocationWatcherConfig) *RevocationWatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+
+	w := &RevocationWatcher{
+		client: c,
+		cfg:    cfg,
+		ids:    make(map[uuid.UUID]time.Time),
+		hashes: make(map[string]time.Time),
+	}
+	c.revocations.Store(w)
+
+	return w
+}
+
+// Run watches for revocations until ctx is done, then returns ctx.Err().
+func (w *RevocationWatcher) Run(ctx context.Context) error {
+	for {
+		var err error
+		if w.cfg.Mode == RevocationStream {
+			var received bool
+			received, err = w.stream(ctx)
+			if err == nil && received {
+				// The server ended a working stream, as it does on
+				// deploys or to rebalance connections.
+				continue
+			}
+		} else {
+			err = w.Poll(ctx)
+		}
+		if err != nil && ctx.Err() == nil && w.cfg.OnError != nil {
+			w.cfg.OnError(err)
+		}
+
+		if err := sleep(ctx, w.cfg.PollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+type revocationsResponse struct {
+	Revocations []Revocation `json:"revocations"`
+	NextSince   string       `json:"next_since"`
+}
+
+// Poll fetches the revocations published since the previous poll.
+func (w *RevocationWatcher) Poll(ctx context.Context) error {
+	w.mu.RLock()
+	since := w.since
+	w.mu.RUnlock()
+
+	r := &request{
+		op:     "PollRevocations",
+		method: http.MethodGet,
+		url:    w.client.endpoint("apikeys", "revocations"),
+		in:     since,
+	}
+	if since != "" {
+		r.query = map[string][]string{"since": {since}}
+	}
+
+	var resp revocationsResponse
+	if _, err := w.client.do(ctx, r, &resp); err != nil {
+		return err
+	}
+
+	w.add(ctx, resp.Revocations...)
+	if resp.NextSince != "" {
+		w.mu.Lock()
+		w.since = resp.NextSince
+		w.mu.Unlock()
+	}
+
+	return nil
+}
+
+// stream consumes the revocation event stream until it ends, reporting
+// whether anything, including keep-alives, was received. Each event's data
+// is a JSON Revocation and its id is the cursor to resume from.
+func (w *RevocationWatcher) stream(ctx context.Context) (received bool, err error) {
+	w.mu.RLock()
+	since := w.since
+	w.mu.RUnlock()
+
+	r := &request{
+		op:     "StreamRevocations",
+		method: http.MethodGet,
+		url:    w.client.endpoint("apikeys", "revocations", "stream"),
+		accept: "text/event-stream",
+		stream: true,
+	}
+	if since != "" {
+		r.query = map[string][]string{"since": {since}}
+	}
+
+	resp, err := w.client.open(ctx, r)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var id string
+	var data strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		received = true
+		line := scanner.Text()
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "":
+			if line != "" {
+				// A comment line, used by servers as keep-alive.
+				continue
+			}
+			if data.Len() == 0 {
+				continue
+			}
+
+			var rev Revocation
+			if err := json.Unmarshal([]byte(data.String()), &rev); err != nil {
+				return true, fmt.Errorf("decode revocation event: %w", err)
+			}
+			w.add(ctx, rev)
+
+			if id != "" {
+				w.mu.Lock()
+				w.since = id
+				w.mu.Unlock()
+			}
+			data.Reset()
+		}
+	}
+
+	return received, scanner.Err()
+}
+
+// add records revs as revoked and evicts them from the validation cache.
+func (w *RevocationWatcher) add(ctx context.Context, revs ...Revocation) {
+	if len(revs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	for _, rev := range revs {
+		var expiresAt time.Time
+		if rev.ExpiresAt != nil {
+			if !now.Before(*rev.ExpiresAt) {
+				continue
+			}
+			expiresAt = *rev.ExpiresAt
+		}
+		if rev.KeyID != uuid.Nil {
+			w.ids[rev.KeyID] = expiresAt
+		}
+		if rev.KeyHash != "" {
+			w.hashes[rev.KeyHash] = expiresAt
+		}
+	}
+	if now.Sub(w.prunedAt) >= w.cfg.PollInterval {
+		w.prune(now)
+	}
+	w.mu.Unlock()
+
+	if cache := w.client.validationCache; cache != nil {
+		for _, rev := range revs {
+			if rev.KeyHash != "" {
+				cache.delete(ctx, rev.KeyHash)
+			}
+		}
+	}
+}
+
+// prune forgets the revoked keys expired at now. w.mu must be held.
+func (w *RevocationWatcher) prune(now time.Time) {
+	expired := func(expiresAt time.Time) bool {
+		return !expiresAt.IsZero() && !now.Before(expiresAt)
+	}
+	maps.DeleteFunc(w.ids, func(_ uuid.UUID, expiresAt time.Time) bool { return expired(expiresAt) })
+	maps.DeleteFunc(w.hashes, func(_ string, expiresAt time.Time) bool { return expired(expiresAt) })
+	w.prunedAt = now
+}
+
+// IsRevoked reports whether the key with the given material has been seen
+// revoked.
+func (w *RevocationWatcher) IsRevoked(apiKey string) bool {
+	return w.isHashRevoked(HashAPIKey(apiKey))
+}
+
+func (w *RevocationWatcher) isHashRevoked(hash string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	_, ok := w.hashes[hash]
+	return ok
+}
+
+// IsKeyIDRevoked reports whether the key with the given ID has been seen
+// revoked.
+func (w *RevocationWatcher) IsKeyIDRevoked(id uuid.UUID) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	_, ok := w.ids[id]
+	return ok
+}
@@ -0,0 +1,142 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// writeRevocation writes rev as a server-sent event.
+func writeRevocation(w http.ResponseWriter, rev apikeysclient.Revocation) {
+	data, _ := json.Marshal(rev)
+	fmt.Fprintf(w, "id: %s\ndata: %s\n\n", rev.KeyID, data)
+	w.(http.Flusher).Flush()
+}
+
+// waitRevoked waits for w to see the key with the given id revoked.
+func waitRevoked(t *testing.T, w *apikeysclient.RevocationWatcher, id uuid.UUID) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !w.IsKeyIDRevoked(id) {
+		if time.Now().After(deadline) {
+			t.Fatalf("revocation of %s not seen", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRevocationStream(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name string
+		// serve answers the n-th connection to the stream.
+		serve func(w http.ResponseWriter, r *http.Request, n int32)
+	}{
+		{"outlives client timeouts", func(w http.ResponseWriter, r *http.Request, n int32) {
+			writeRevocation(w, apikeysclient.Revocation{KeyID: first})
+			select {
+			case <-time.After(300 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+			writeRevocation(w, apikeysclient.Revocation{KeyID: second})
+			<-r.Context().Done()
+		}},
+		{"reconnects after the end", func(w http.ResponseWriter, r *http.Request, n int32) {
+			if n == 1 {
+				writeRevocation(w, apikeysclient.Revocation{KeyID: first})
+				return
+			}
+			writeRevocation(w, apikeysclient.Revocation{KeyID: second})
+			<-r.Context().Done()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conns atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				tt.serve(w, r, conns.Add(1))
+			}))
+			defer srv.Close()
+
+			client, err := apikeysclient.NewClient(srv.URL,
+				apikeysclient.WithTimeout(100*time.Millisecond),
+				apikeysclient.WithCallTimeout(100*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var errs atomic.Int32
+			watcher := client.WatchRevocations(apikeysclient.RevocationWatcherConfig{
+				Mode:         apikeysclient.RevocationStream,
+				PollInterval: time.Hour,
+				OnError:      func(error) { errs.Add(1) },
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go watcher.Run(ctx)
+
+			waitRevoked(t, watcher, first)
+			waitRevoked(t, watcher, second)
+			if n := errs.Load(); n != 0 {
+				t.Errorf("OnError called %d times", n)
+			}
+		})
+	}
+}
+
+func TestRevocationWatcherForgetsExpiredKeys(t *testing.T) {
+	expired, expiring, permanent := uuid.New(), uuid.New(), uuid.New()
+	past := time.Now().Add(-time.Minute)
+	soon := time.Now().Add(50 * time.Millisecond)
+
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var revs []apikeysclient.Revocation
+		if polls.Add(1) == 1 {
+			revs = []apikeysclient.Revocation{
+				{KeyID: expired, ExpiresAt: &past},
+				{KeyID: expiring, ExpiresAt: &soon},
+				{KeyID: permanent},
+			}
+		} else {
+			revs = []apikeysclient.Revocation{{KeyID: uuid.New()}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"revocations": revs})
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := client.WatchRevocations(apikeysclient.RevocationWatcherConfig{PollInterval: time.Millisecond})
+	ctx := context.Background()
+
+	if err := watcher.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if watcher.IsKeyIDRevoked(expired) || !watcher.IsKeyIDRevoked(expiring) || !watcher.IsKeyIDRevoked(permanent) {
+		t.Fatal("after the first poll, want only the unexpired keys revoked")
+	}
+
+	time.Sleep(time.Until(soon))
+	if err := watcher.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if watcher.IsKeyIDRevoked(expiring) || !watcher.IsKeyIDRevoked(permanent) {
+		t.Error("after expiry, want the expired key forgotten and the permanent one kept")
+	}
+}
@@ -0,0 +1,74 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+// ErrInsufficientScope is passed to RequireScopes' error handling when a key
+// lacks a required scope.
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// HasScope reports whether the key is granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// HasAllScopes reports whether the key is granted every one of scopes.
+func (k *APIKey) HasAllScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !k.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAnyScope reports whether the key is granted at least one of scopes.
+func (k *APIKey) HasAnyScope(scopes ...string) bool {
+	for _, scope := range scopes {
+		if k.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAPIKeyWithScopes reports whether apiKey is valid and granted all of
+// requiredScopes. Validity is checked with ValidateAPIKey, then the key
+// record is fetched for its scopes.
+func (c *Client) ValidateAPIKeyWithScopes(ctx context.Context, apiKey string, requiredScopes ...string) (bool, error) {
+	key, err := c.authenticate(ctx, apiKey, true)
+	if errors.Is(err, ErrInvalidAPIKey) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return key.HasAllScopes(requiredScopes...), nil
+}
+
+// RequireScopes returns middleware that rejects requests whose APIKey,
+// stored in the context by Client.Middleware, lacks any of scopes. It must
+// wrap handlers behind Client.Middleware with key resolution enabled.
+// Requests without a key get 401, keys missing a scope get 403.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := APIKeyFromContext(r.Context())
+			if !ok {
+				defaultErrorHandler(w, r, http.StatusUnauthorized, ErrMissingAPIKey)
+				return
+			}
+			if !key.HasAllScopes(scopes...) {
+				defaultErrorHandler(w, r, http.StatusForbidden, ErrInsufficientScope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
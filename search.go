@@ -0,0 +1,282 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidSearchQuery is returned by ParseSearchQuery for queries it
+// cannot parse. Servers answer such queries with 400 Bad Request.
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// SearchOptions selects a page of search results. Zero fields are not sent.
+type SearchOptions struct {
+	PerPage int
+	Cursor  string
+}
+
+// values encodes the query q and o as query parameters.
+func (o *SearchOptions) values(q string) url.Values {
+	v := url.Values{"q": {q}}
+	if o == nil {
+		return v
+	}
+
+	if o.PerPage > 0 {
+		v.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	}
+	return v
+}
+
+// SearchResult is a key matching a search, with its relevance.
+type SearchResult struct {
+	Key APIKey `json:"key"`
+
+	// Score ranks the result against the query's free text; higher is more
+	// relevant. It is zero for queries without free text.
+	Score float64 `json:"score"`
+}
+
+// SearchResults is one page of the results of a search, most relevant
+// first.
+type SearchResults struct {
+	Results []SearchResult `json:"results"`
+
+	// TotalCount is the number of keys matching the query across all pages.
+	TotalCount int `json:"total_count"`
+
+	// NextCursor is the cursor of the following page, empty on the last.
+	NextCursor string `json:"next_cursor"`
+}
+
+// SearchAPIKeys retrieves the page of keys matching query selected by opts,
+// ranked by relevance. opts may be nil. See ParseSearchQuery for the query
+// syntax; queries are parsed by the server, which may support more fields.
+//
+//	results, err := client.SearchAPIKeys(ctx, `billing status:active label:team=infra created:>2024-01-01`, nil)
+func (c *Client) SearchAPIKeys(ctx context.Context, query string, opts *SearchOptions) (*SearchResults, error) {
+	var results SearchResults
+	_, err := c.do(ctx, &request{
+		op:     "SearchAPIKeys",
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys", "search"),
+		query:  opts.values(query),
+		in:     SearchInput{Query: query, Options: opts},
+	}, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// SearchQuery is a parsed search query. It is used by servers implementing
+// search; clients send queries as text.
+type SearchQuery struct {
+	// Text holds the free-text terms of the query, lower-cased. Every term
+	// must appear in the name or description of matching keys.
+	Text []string
+
+	Status           []KeyStatus
+	Labels           LabelSelector
+	Scopes           []string
+	ServiceAccountID uuid.UUID
+
+	// CreatedFrom and CreatedUntil bound CreatedAt; CreatedFrom is
+	// inclusive, CreatedUntil exclusive. Zero bounds are open.
+	CreatedFrom  time.Time
+	CreatedUntil time.Time
+}
+
+// ParseSearchQuery parses a search query: whitespace-separated free-text
+// terms, with double quotes around terms containing spaces or colons, and
+// field:value filters. The fields are
+//
+//	status:active,suspended   keys in one of the statuses
+//	label:team=infra          keys with the label; repeat for several
+//	scope:keys:read           keys granted the scope
+//	service_account:<uuid>    keys of the service account
+//	created:>2024-01-01       keys created after the day; also >=, <, <=
+//	                          and a bare date for that day, in UTC, or
+//	                          RFC 3339 times
+//
+// Unknown fields fail with ErrInvalidSearchQuery.
+func ParseSearchQuery(s string) (*SearchQuery, error) {
+	terms, err := splitSearchQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &SearchQuery{Labels: LabelSelector{}}
+	for _, term := range terms {
+		field, value, ok := strings.Cut(term.text, ":")
+		if term.quoted || !ok {
+			q.Text = append(q.Text, strings.ToLower(term.text))
+			continue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%w: empty %s filter", ErrInvalidSearchQuery, field)
+		}
+
+		switch field {
+		case "status":
+			for _, status := range strings.Split(value, ",") {
+				q.Status = append(q.Status, KeyStatus(status))
+			}
+		case "label":
+			k, v, ok := strings.Cut(value, "=")
+			if !ok || k == "" {
+				return nil, fmt.Errorf("%w: label filter %q: want key=value", ErrInvalidSearchQuery, value)
+			}
+			q.Labels[k] = v
+		case "scope":
+			q.Scopes = append(q.Scopes, value)
+		case "service_account":
+			if q.ServiceAccountID, err = uuid.Parse(value); err != nil {
+				return nil, fmt.Errorf("%w: service_account filter: %v", ErrInvalidSearchQuery, err)
+			}
+		case "created":
+			if err := q.parseCreated(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSearchQuery, field)
+		}
+	}
+	return q, nil
+}
+
+// parseCreated adds the bound of a created filter to q.
+func (q *SearchQuery) parseCreated(value string) error {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, prefix) {
+			op, value = prefix, value[len(prefix):]
+			break
+		}
+	}
+
+	// A date covers its whole day and a time the instant itself, so that
+	// > and <= exclude and include the same keys for both.
+	start, err := time.Parse(time.DateOnly, value)
+	end := start.AddDate(0, 0, 1)
+	if err != nil {
+		if start, err = time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("%w: created filter %q: want a date or RFC 3339 time", ErrInvalidSearchQuery, value)
+		}
+		end = start.Add(time.Nanosecond)
+	}
+
+	switch op {
+	case ">":
+		q.CreatedFrom = end
+	case ">=":
+		q.CreatedFrom = start
+	case "<":
+		q.CreatedUntil = start
+	case "<=":
+		q.CreatedUntil = end
+	default:
+		q.CreatedFrom, q.CreatedUntil = start, end
+	}
+	return nil
+}
+
+// Matches reports whether key matches every filter and free-text term of
+// q. Deleted keys only match queries filtering on status:deleted.
+func (q *SearchQuery) Matches(key *APIKey) bool {
+	status := key.EffectiveStatus()
+	switch {
+	case len(q.Status) > 0 && !slices.Contains(q.Status, status):
+		return false
+	case len(q.Status) == 0 && status == KeyDeleted:
+		return false
+	case !q.Labels.Matches(key.Labels):
+		return false
+	case q.ServiceAccountID != uuid.Nil && key.ServiceAccountID != q.ServiceAccountID:
+		return false
+	case !q.CreatedFrom.IsZero() && key.CreatedAt.Before(q.CreatedFrom):
+		return false
+	case !q.CreatedUntil.IsZero() && !key.CreatedAt.Before(q.CreatedUntil):
+		return false
+	case !key.HasAllScopes(q.Scopes...):
+		return false
+	}
+
+	name, description := strings.ToLower(key.Name), strings.ToLower(key.Description)
+	for _, term := range q.Text {
+		if !strings.Contains(name, term) && !strings.Contains(description, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// Score returns the relevance of a matching key to the free text of q:
+// terms found in the name weigh twice those found in the description, and
+// a name equal to the whole text scores higher still.
+func (q *SearchQuery) Score(key *APIKey) float64 {
+	name, description := strings.ToLower(key.Name), strings.ToLower(key.Description)
+
+	var score float64
+	for _, term := range q.Text {
+		if strings.Contains(name, term) {
+			score += 2
+		}
+		if strings.Contains(description, term) {
+			score++
+		}
+	}
+	if len(q.Text) > 0 && name == strings.Join(q.Text, " ") {
+		score += 2
+	}
+	return score
+}
+
+type searchTerm struct {
+	text   string
+	quoted bool
+}
+
+// splitSearchQuery splits s into whitespace-separated terms, keeping quoted
+// terms whole.
+func splitSearchQuery(s string) ([]searchTerm, error) {
+	var terms []searchTerm
+	for {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return terms, nil
+		}
+
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidSearchQuery)
+			}
+			if text := s[1 : end+1]; text != "" {
+				terms = append(terms, searchTerm{text: text, quoted: true})
+			}
+			s = s[end+2:]
+			continue
+		}
+
+		end := strings.IndexAny(s, " \t\n")
+		if end < 0 {
+			end = len(s)
+		}
+		terms = append(terms, searchTerm{text: s[:end]})
+		s = s[end:]
+	}
+}
@@ -0,0 +1,142 @@
+package apikeysclient_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	account := uuid.New()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	instant := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		query   string
+		want    apikeysclient.SearchQuery
+		wantErr bool
+	}{
+		{"", apikeysclient.SearchQuery{Labels: apikeysclient.LabelSelector{}}, false},
+		{"  Billing   API ", apikeysclient.SearchQuery{Text: []string{"billing", "api"}, Labels: apikeysclient.LabelSelector{}}, false},
+		{`"Payment Service" "a:b"`, apikeysclient.SearchQuery{Text: []string{"payment service", "a:b"}, Labels: apikeysclient.LabelSelector{}}, false},
+		{"status:active,suspended", apikeysclient.SearchQuery{
+			Status: []apikeysclient.KeyStatus{apikeysclient.KeyActive, apikeysclient.KeySuspended},
+			Labels: apikeysclient.LabelSelector{},
+		}, false},
+		{"label:team=infra label:env=prod", apikeysclient.SearchQuery{Labels: apikeysclient.LabelSelector{"team": "infra", "env": "prod"}}, false},
+		{"label:flag=", apikeysclient.SearchQuery{Labels: apikeysclient.LabelSelector{"flag": ""}}, false},
+		{"scope:keys:read scope:keys:write", apikeysclient.SearchQuery{Scopes: []string{"keys:read", "keys:write"}, Labels: apikeysclient.LabelSelector{}}, false},
+		{"service_account:" + account.String(), apikeysclient.SearchQuery{ServiceAccountID: account, Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:2024-01-01", apikeysclient.SearchQuery{CreatedFrom: day, CreatedUntil: day.AddDate(0, 0, 1), Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:>2024-01-01", apikeysclient.SearchQuery{CreatedFrom: day.AddDate(0, 0, 1), Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:>=2024-01-01", apikeysclient.SearchQuery{CreatedFrom: day, Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:<2024-01-01", apikeysclient.SearchQuery{CreatedUntil: day, Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:<=2024-01-01", apikeysclient.SearchQuery{CreatedUntil: day.AddDate(0, 0, 1), Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:>2024-01-01T12:30:00Z", apikeysclient.SearchQuery{CreatedFrom: instant.Add(time.Nanosecond), Labels: apikeysclient.LabelSelector{}}, false},
+		{"created:>=2024-01-01 created:<2024-02-01 billing", apikeysclient.SearchQuery{
+			Text:         []string{"billing"},
+			Labels:       apikeysclient.LabelSelector{},
+			CreatedFrom:  day,
+			CreatedUntil: day.AddDate(0, 1, 0),
+		}, false},
+		{`"unterminated`, apikeysclient.SearchQuery{}, true},
+		{"status:", apikeysclient.SearchQuery{}, true},
+		{"label:team", apikeysclient.SearchQuery{}, true},
+		{"label:=infra", apikeysclient.SearchQuery{}, true},
+		{"service_account:nope", apikeysclient.SearchQuery{}, true},
+		{"created:yesterday", apikeysclient.SearchQuery{}, true},
+		{"owner:alice", apikeysclient.SearchQuery{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := apikeysclient.ParseSearchQuery(tt.query)
+			if tt.wantErr {
+				if !errors.Is(err, apikeysclient.ErrInvalidSearchQuery) {
+					t.Fatalf("ParseSearchQuery = %+v, %v, want ErrInvalidSearchQuery", q, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*q, tt.want) {
+				t.Errorf("ParseSearchQuery = %+v, want %+v", *q, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	account := uuid.New()
+	key := apikeysclient.APIKey{
+		ServiceAccountID: account,
+		Name:             "Billing worker",
+		Description:      "Charges invoices",
+		Scopes:           []string{"invoices:write"},
+		Labels:           map[string]string{"team": "payments"},
+		Status:           apikeysclient.KeyActive,
+		IsActive:         true,
+		Valid:            true,
+		CreatedAt:        time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+	deleted := key
+	deleted.SetStatus(apikeysclient.KeyDeleted)
+
+	tests := []struct {
+		query string
+		key   apikeysclient.APIKey
+		want  bool
+	}{
+		{"", key, true},
+		{"billing invoices", key, true},
+		{"BILLING", key, true},
+		{"billing refunds", key, false},
+		{"status:active", key, true},
+		{"status:suspended,revoked", key, false},
+		{"label:team=payments", key, true},
+		{"label:team=infra", key, false},
+		{"scope:invoices:write", key, true},
+		{"scope:invoices:read", key, false},
+		{"service_account:" + account.String(), key, true},
+		{"service_account:" + uuid.NewString(), key, false},
+		{"created:2024-03-01", key, true},
+		{"created:>2024-03-01", key, false},
+		{"created:<=2024-03-01", key, true},
+		{"created:<2024-03-01T09:00:00Z", key, false},
+		{"billing", deleted, false},
+		{"billing status:deleted", deleted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := apikeysclient.ParseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := q.Matches(&tt.key); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQueryScore(t *testing.T) {
+	q, err := apikeysclient.ParseSearchQuery("billing worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exact := apikeysclient.APIKey{Name: "Billing Worker"}
+	name := apikeysclient.APIKey{Name: "billing worker 2"}
+	description := apikeysclient.APIKey{Name: "cron", Description: "billing worker"}
+	if !(q.Score(&exact) > q.Score(&name) && q.Score(&name) > q.Score(&description) && q.Score(&description) > 0) {
+		t.Errorf("scores exact %v, name %v, description %v, want decreasing and positive",
+			q.Score(&exact), q.Score(&name), q.Score(&description))
+	}
+}
@@ -0,0 +1,255 @@
+package apikeysclient
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// signedKeyPrefix marks API keys in the signed format:
+//
+//	akv1.<base64url(claims JSON)>.<base64url(signature)>
+//
+// The signature covers everything before the last dot.
+const signedKeyPrefix = "akv1."
+
+// Errors returned by ParseSignedKey.
+var (
+	ErrNotSignedKey     = errors.New("not a signed API key")
+	ErrInvalidSignature = errors.New("invalid API key signature")
+	ErrKeyExpired       = errors.New("API key expired")
+)
+
+// SignedKeyClaims are the claims embedded in a signed API key.
+type SignedKeyClaims struct {
+	KeyID            uuid.UUID
+	ServiceAccountID uuid.UUID
+	Scopes           []string
+	IssuedAt         time.Time
+	ExpiresAt        time.Time // zero if the key does not expire
+}
+
+// signedKeyPayload is the compact wire form of SignedKeyClaims.
+type signedKeyPayload struct {
+	KeyID            uuid.UUID `json:"kid"`
+	ServiceAccountID uuid.UUID `json:"sa"`
+	Scopes           []string  `json:"scp,omitempty"`
+	IssuedAt         int64     `json:"iat"`
+	ExpiresAt        int64     `json:"exp,omitempty"`
+}
+
+// apiKey returns the APIKey record described by the claims.
+func (cl *SignedKeyClaims) apiKey(key string) *APIKey {
+	k := &APIKey{
+		ID:               cl.KeyID,
+		ServiceAccountID: cl.ServiceAccountID,
+		APIKey:           key,
+		CreatedAt:        cl.IssuedAt,
+		Status:           KeyActive,
+		Valid:            true,
+		IsActive:         true,
+		Scopes:           cl.Scopes,
+	}
+	if !cl.ExpiresAt.IsZero() {
+		expiresAt := cl.ExpiresAt
+		k.ExpiresAt = &expiresAt
+	}
+	return k
+}
+
+// KeySigner signs API keys. It is used by issuers of signed keys.
+type KeySigner interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+// KeyVerifier verifies the signature of API keys.
+type KeyVerifier interface {
+	Verify(msg, sig []byte) error
+}
+
+// HMACKey signs and verifies keys with HMAC-SHA256 using a shared secret.
+type HMACKey []byte
+
+// Sign implements KeySigner.
+func (k HMACKey) Sign(msg []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// Verify implements KeyVerifier.
+func (k HMACKey) Verify(msg, sig []byte) error {
+	expected, _ := k.Sign(msg)
+	if !hmac.Equal(expected, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Ed25519Signer signs keys with an Ed25519 private key.
+type Ed25519Signer ed25519.PrivateKey
+
+// Sign implements KeySigner.
+func (k Ed25519Signer) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(k), msg), nil
+}
+
+// Ed25519Verifier verifies keys with an Ed25519 public key, so verifiers
+// never hold signing material.
+type Ed25519Verifier ed25519.PublicKey
+
+// Verify implements KeyVerifier.
+func (k Ed25519Verifier) Verify(msg, sig []byte) error {
+	if !ed25519.Verify(ed25519.PublicKey(k), msg, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// IssueSignedKey returns a signed API key embedding claims.
+func IssueSignedKey(signer KeySigner, claims SignedKeyClaims) (string, error) {
+	payload := signedKeyPayload{
+		KeyID:            claims.KeyID,
+		ServiceAccountID: claims.ServiceAccountID,
+		Scopes:           claims.Scopes,
+		IssuedAt:         claims.IssuedAt.Unix(),
+	}
+	if !claims.ExpiresAt.IsZero() {
+		payload.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signed := signedKeyPrefix + base64.RawURLEncoding.EncodeToString(data)
+	sig, err := signer.Sign([]byte(signed))
+	if err != nil {
+		return "", fmt.Errorf("sign key: %w", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// IsSignedKey reports whether key is in the signed key format. It does not
+// verify the key.
+func IsSignedKey(key string) bool {
+	return strings.HasPrefix(key, signedKeyPrefix) && strings.Count(key, ".") == 2
+}
+
+// ParseSignedKey verifies key with verifier and returns its claims. It fails
+// with ErrNotSignedKey, ErrInvalidSignature or ErrKeyExpired.
+func ParseSignedKey(key string, verifier KeyVerifier) (*SignedKeyClaims, error) {
+	if !IsSignedKey(key) {
+		return nil, ErrNotSignedKey
+	}
+
+	dot := strings.LastIndexByte(key, '.')
+	signed, encodedSig := key[:dot], key[dot+1:]
+
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if err := verifier.Verify([]byte(signed), sig); err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(signed, signedKeyPrefix))
+	if err != nil {
+		return nil, ErrNotSignedKey
+	}
+
+	var payload signedKeyPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrNotSignedKey
+	}
+
+	claims := &SignedKeyClaims{
+		KeyID:            payload.KeyID,
+		ServiceAccountID: payload.ServiceAccountID,
+		Scopes:           payload.Scopes,
+		IssuedAt:         time.Unix(payload.IssuedAt, 0),
+	}
+	if payload.ExpiresAt != 0 {
+		claims.ExpiresAt = time.Unix(payload.ExpiresAt, 0)
+		if !time.Now().Before(claims.ExpiresAt) {
+			return nil, ErrKeyExpired
+		}
+	}
+
+	return claims, nil
+}
+
+// ErrMissingVerifier is returned by NewClient for WithSignedKeys without a
+// Verifier.
+var ErrMissingVerifier = errors.New("signed keys need a verifier")
+
+// SignedKeyConfig configures local validation of signed API keys.
+type SignedKeyConfig struct {
+	// Verifier checks the signatures of keys. It is required.
+	Verifier KeyVerifier
+
+	// SkipRevocationCheck trusts any correctly signed, unexpired key without
+	// asking the server whether it was revoked.
+	SkipRevocationCheck bool
+}
+
+// WithSignedKeys verifies signed API keys locally. A key with a bad
+// signature or a passed expiry is invalid without a server round trip;
+// otherwise the server is only consulted to check for revocation, through
+// the validation cache when one is configured, unless a RevocationWatcher
+// is attached. Keys not in the signed format are validated remotely as
+// usual. NewClient fails with ErrMissingVerifier when cfg has no Verifier.
+func WithSignedKeys(cfg SignedKeyConfig) Option {
+	return func(c *Client, _ *options) {
+		c.signedKeys = &cfg
+	}
+}
+
+// verifySignedKey checks key locally when signed keys are configured. It
+// returns ok=false when key is not handled locally. Otherwise claims is nil
+// for a key that failed verification, and final reports whether no
+// revocation check is needed.
+func (c *Client) verifySignedKey(key string) (claims *SignedKeyClaims, final, ok bool) {
+	if c.signedKeys == nil || !IsSignedKey(key) {
+		return nil, false, false
+	}
+
+	claims, err := ParseSignedKey(key, c.signedKeys.Verifier)
+	if err != nil {
+		return nil, true, true
+	}
+
+	// A revocation watcher makes the remote revocation check unnecessary.
+	if w := c.revocations.Load(); w != nil {
+		if w.IsKeyIDRevoked(claims.KeyID) {
+			return nil, true, true
+		}
+		return claims, true, true
+	}
+
+	return claims, c.signedKeys.SkipRevocationCheck, true
+}
+
+// validateSignedKey validates a key in the signed format, returning handled
+// false for keys that must be validated remotely.
+func (c *Client) validateSignedKey(key string) (valid, handled bool) {
+	claims, final, ok := c.verifySignedKey(key)
+	if !ok {
+		return false, false
+	}
+	if claims == nil {
+		return false, true
+	}
+	return true, final
+}
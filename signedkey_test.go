@@ -0,0 +1,50 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestWithSignedKeys(t *testing.T) {
+	secret := apikeysclient.HMACKey("test secret")
+	key, err := apikeysclient.IssueSignedKey(secret, apikeysclient.SignedKeyClaims{
+		KeyID:    uuid.New(),
+		IssuedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       apikeysclient.SignedKeyConfig
+		wantErr   error
+		wantValid bool
+	}{
+		{"verifier", apikeysclient.SignedKeyConfig{Verifier: secret, SkipRevocationCheck: true}, nil, true},
+		{"wrong verifier", apikeysclient.SignedKeyConfig{Verifier: apikeysclient.HMACKey("other"), SkipRevocationCheck: true}, nil, false},
+		{"no verifier", apikeysclient.SignedKeyConfig{SkipRevocationCheck: true}, apikeysclient.ErrMissingVerifier, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := apikeysclient.NewClient("http://keys.invalid", apikeysclient.WithSignedKeys(tt.cfg))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewClient error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if valid, err := client.ValidateAPIKey(context.Background(), key); valid != tt.wantValid || err != nil {
+				t.Errorf("ValidateAPIKey = %v, %v; want %v, nil", valid, err, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package apikeysclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers of signed requests, set by clients configured with
+// WithRequestSigning.
+const (
+	// SignatureHeader carries the hex-encoded signature of the request.
+	SignatureHeader = "X-Signature"
+
+	// TimestampHeader carries the unix time the request was signed at.
+	TimestampHeader = "X-Timestamp"
+
+	// NonceHeader carries a value unique to each signed request.
+	NonceHeader = "X-Nonce"
+)
+
+// signedHeaders are the headers covered by request signatures besides
+// TimestampHeader and NonceHeader: those changing what a request does.
+var signedHeaders = []string{
+	DryRunHeader,
+	ActorHeader,
+	OrgIDHeader,
+	ProjectIDHeader,
+	"If-Match",
+	"If-None-Match",
+	IdempotencyKeyHeader,
+}
+
+// RequestSignatureTolerance is how far the timestamp of a signed request may
+// be from the current time before VerifyRequestSignature rejects it as a
+// possible replay. Within it, a captured request verifies again unless the
+// server also rejects the NonceHeader values it has already seen.
+const RequestSignatureTolerance = 5 * time.Minute
+
+// maxSignedBodySize bounds the request bodies VerifyRequestSignature reads.
+const maxSignedBodySize = 1 << 20
+
+// ErrInvalidRequestSignature is returned by VerifyRequestSignature for
+// requests that are unsigned, were altered after being signed or were
+// signed too long ago.
+var ErrInvalidRequestSignature = errors.New("invalid request signature")
+
+// WithRequestSigning signs every REST request of the client with signer, so
+// the keys service can tell them from requests altered after TLS was
+// terminated. The signature covers the method, the path and query, the
+// TimestampHeader and NonceHeader, the headers selecting the tenant, actor,
+// dry run, precondition and idempotency key of the request, and its body. It
+// is sent in SignatureHeader. Servers check it with VerifyRequestSignature. HMACKey
+// signs with a shared secret.
+func WithRequestSigning(signer KeySigner) Option {
+	return func(c *Client, _ *options) {
+		c.requestSigner = signer
+	}
+}
+
+// signRequest sets the signature headers of req, whose body is body, when
+// request signing is enabled.
+func (c *Client) signRequest(req *http.Request, body []byte) error {
+	if c.requestSigner == nil {
+		return nil
+	}
+
+	req.Header.Set(TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(NonceHeader, uuid.NewString())
+	sig, err := c.requestSigner.Sign(canonicalRequest(req, body))
+	if err != nil {
+		return fmt.Errorf("sign request: %w", err)
+	}
+	req.Header.Set(SignatureHeader, hex.EncodeToString(sig))
+	return nil
+}
+
+// VerifyRequestSignature checks that r was signed by a client configured
+// with WithRequestSigning, using the verifier of its signer, no longer than
+// RequestSignatureTolerance ago. It reads the body of r, up to 1 MiB, and
+// replaces it so handlers can read it again. It fails with
+// ErrInvalidRequestSignature, and with other errors when the body cannot be
+// read.
+//
+// It keeps no state, so a request replayed within the tolerance verifies.
+// Servers needing replay protection remember the NonceHeader of verified
+// requests for RequestSignatureTolerance and reject repeated ones.
+func VerifyRequestSignature(r *http.Request, verifier KeyVerifier) error {
+	ts := r.Header.Get(TimestampHeader)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidRequestSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > RequestSignatureTolerance || age < -RequestSignatureTolerance {
+		return ErrInvalidRequestSignature
+	}
+	sig, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil || len(sig) == 0 {
+		return ErrInvalidRequestSignature
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return fmt.Errorf("read request body: %w", err)
+		}
+		if len(body) > maxSignedBodySize {
+			return fmt.Errorf("%w: body too large", ErrInvalidRequestSignature)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.Header.Get(NonceHeader) == "" {
+		return ErrInvalidRequestSignature
+	}
+	if err := verifier.Verify(canonicalRequest(r, body), sig); err != nil {
+		return ErrInvalidRequestSignature
+	}
+	return nil
+}
+
+// RequireSignedRequests returns an http.Handler passing to next only the
+// requests VerifyRequestSignature accepts with verifier, and answering the
+// others with 401 Unauthorized.
+func RequireSignedRequests(next http.Handler, verifier KeyVerifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyRequestSignature(r, verifier); err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canonicalRequest returns the message signed for r, with body:
+//
+//	<method>\n<escaped path>[?<query>]\n<timestamp>\n<nonce>\n
+//	<value of each of signedHeaders, in order, followed by \n>
+//	<hex SHA-256 of body>
+//
+// Absent headers sign as empty values.
+func canonicalRequest(r *http.Request, body []byte) []byte {
+	target := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	digest := sha256.Sum256(body)
+
+	msg := make([]byte, 0, 256)
+	msg = append(msg, r.Method...)
+	msg = append(append(msg, '\n'), target...)
+	msg = append(append(msg, '\n'), r.Header.Get(TimestampHeader)...)
+	msg = append(append(msg, '\n'), r.Header.Get(NonceHeader)...)
+	msg = append(msg, '\n')
+	for _, name := range signedHeaders {
+		msg = append(append(msg, r.Header.Get(name)...), '\n')
+	}
+	return hex.AppendEncode(msg, digest[:])
+}
@@ -0,0 +1,103 @@
+package apikeysclient_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// signedRequest is a request as received by the server.
+type signedRequest struct {
+	method, target string
+	header         http.Header
+	body           []byte
+}
+
+// request returns a copy of s to verify.
+func (s *signedRequest) request() *http.Request {
+	r := httptest.NewRequest(s.method, s.target, bytes.NewReader(s.body))
+	r.Header = s.header.Clone()
+	return r
+}
+
+func TestVerifyRequestSignature(t *testing.T) {
+	key := apikeysclient.HMACKey("secret")
+
+	var signed signedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signed = signedRequest{r.Method, r.URL.RequestURI(), r.Header.Clone(), body}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(apikeysclient.APIKey{ID: uuid.New()})
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL,
+		apikeysclient.WithRequestSigning(key),
+		apikeysclient.WithTenant(apikeysclient.Tenant{OrgID: "org"}),
+		apikeysclient.WithActor("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateAPIKey(context.Background(), apikeysclient.APIKey{Name: "signed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		alter    func(r *http.Request)
+		verifier apikeysclient.KeyVerifier
+		wantErr  bool
+	}{
+		{"unaltered", func(*http.Request) {}, key, false},
+		{"other key", func(*http.Request) {}, apikeysclient.HMACKey("other"), true},
+		{"method", func(r *http.Request) { r.Method = http.MethodPut }, key, true},
+		{"path", func(r *http.Request) { r.URL.Path = "/apikeys/other" }, key, true},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "purge=true" }, key, true},
+		{"body", func(r *http.Request) { r.Body = io.NopCloser(bytes.NewReader([]byte(`{}`))) }, key, true},
+		{"tenant", func(r *http.Request) { r.Header.Set(apikeysclient.OrgIDHeader, "other") }, key, true},
+		{"project", func(r *http.Request) { r.Header.Set(apikeysclient.ProjectIDHeader, "other") }, key, true},
+		{"actor", func(r *http.Request) { r.Header.Del(apikeysclient.ActorHeader) }, key, true},
+		{"dry run", func(r *http.Request) { r.Header.Set(apikeysclient.DryRunHeader, "true") }, key, true},
+		{"If-Match", func(r *http.Request) { r.Header.Set("If-Match", `"1"`) }, key, true},
+		{"idempotency key", func(r *http.Request) { r.Header.Set(apikeysclient.IdempotencyKeyHeader, "other") }, key, true},
+		{"nonce", func(r *http.Request) { r.Header.Set(apikeysclient.NonceHeader, "other") }, key, true},
+		{"no nonce", func(r *http.Request) { r.Header.Del(apikeysclient.NonceHeader) }, key, true},
+		{"no signature", func(r *http.Request) { r.Header.Del(apikeysclient.SignatureHeader) }, key, true},
+		{"expired", func(r *http.Request) {
+			old := time.Now().Add(-2 * apikeysclient.RequestSignatureTolerance).Unix()
+			r.Header.Set(apikeysclient.TimestampHeader, strconv.FormatInt(old, 10))
+		}, key, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signed.request()
+			tt.alter(r)
+			err := apikeysclient.VerifyRequestSignature(r, tt.verifier)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("VerifyRequestSignature = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, apikeysclient.ErrInvalidRequestSignature) {
+				t.Errorf("VerifyRequestSignature = %v, want ErrInvalidRequestSignature", err)
+			}
+			if err == nil {
+				if body, _ := io.ReadAll(r.Body); !bytes.Equal(body, signed.body) {
+					t.Errorf("body after verification = %q, want %q", body, signed.body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// KeyStatus is the lifecycle state of an API key. Keys move between states
+// through the lifecycle calls:
+//
+//	pending   -> active     ActivateAPIKey
+//	active    -> suspended  SuspendAPIKey
+//	suspended -> active     ResumeAPIKey
+//	any       -> revoked    RevokeAPIKey, except deleted keys
+//	any       -> deleted    DeleteAPIKey
+//
+// Revoked and deleted keys never come back; deleted keys disappear for good
+// with PurgeAPIKey. Keys past their ExpiresAt are expired whatever their
+// stored state, unless revoked or deleted.
+type KeyStatus string
+
+// Key statuses. Only active keys pass validation.
+const (
+	KeyPending   KeyStatus = "pending"
+	KeyActive    KeyStatus = "active"
+	KeySuspended KeyStatus = "suspended"
+	KeyRevoked   KeyStatus = "revoked"
+	KeyExpired   KeyStatus = "expired"
+	KeyDeleted   KeyStatus = "deleted"
+)
+
+// Audit event types of the lifecycle calls added with KeyStatus.
+const (
+	AuditKeySuspended AuditEventType = "key.suspended"
+	AuditKeyResumed   AuditEventType = "key.resumed"
+	AuditKeyPurged    AuditEventType = "key.purged"
+)
+
+// EffectiveStatus returns the status of k as of now: Status, or one derived
+// from Valid and IsActive for servers that do not send it, turned expired
+// once ExpiresAt has passed. Without a Status, valid active keys are active,
+// valid inactive ones suspended and invalid ones revoked.
+func (k *APIKey) EffectiveStatus() KeyStatus {
+	status := k.Status
+	if status == "" {
+		switch {
+		case !k.Valid:
+			status = KeyRevoked
+		case !k.IsActive:
+			status = KeySuspended
+		default:
+			status = KeyActive
+		}
+	}
+	if status != KeyRevoked && status != KeyDeleted && k.IsExpired() {
+		return KeyExpired
+	}
+	return status
+}
+
+// SetStatus sets the Status of k and the Valid and IsActive flags derived
+// from it, so clients reading the flags see the same state. Servers storing
+// keys use it; clients change statuses through the lifecycle calls.
+func (k *APIKey) SetStatus(status KeyStatus) {
+	k.Status = status
+	k.IsActive = status == KeyActive
+	k.Valid = status != KeyRevoked && status != KeyDeleted
+}
+
+// SuspendAPIKey suspends the active key with the given id and returns its
+// updated state. Suspended keys fail validation until resumed with
+// ResumeAPIKey; the key is dropped from the validation cache as with
+// RevokeAPIKey.
+func (c *Client) SuspendAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	key, err := c.patchKeyState(ctx, "SuspendAPIKey", id, "suspend")
+	if err != nil {
+		return nil, err
+	}
+
+	c.invalidateKey(key)
+	return key, nil
+}
+
+// ResumeAPIKey makes the suspended key with the given id active again and
+// returns its updated state.
+func (c *Client) ResumeAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	return c.patchKeyState(ctx, "ResumeAPIKey", id, "resume")
+}
+
+// PurgeAPIKey permanently removes the key with the given id, whatever its
+// status, along with its usage history. Unlike DeleteAPIKey it cannot be
+// undone by the server's operators; its audit events are kept. Responses and
+// the validation cache are handled as by DeleteAPIKey.
+func (c *Client) PurgeAPIKey(ctx context.Context, id uuid.UUID) error {
+	return c.removeAPIKey(ctx, "PurgeAPIKey", id, url.Values{"purge": {"true"}})
+}
+
+// invalidateKey drops key from the validation cache when the server returned
+// its material or hash.
+func (c *Client) invalidateKey(key *APIKey) {
+	switch {
+	case key.KeyHash != "":
+		c.invalidateHash(key.KeyHash)
+	case key.APIKey != "":
+		c.Invalidate(key.APIKey)
+	}
+}
@@ -0,0 +1,205 @@
+package apikeysclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MediaTypeNDJSON is the media type of newline-delimited JSON, in which
+// key listings are streamed one key per line.
+const MediaTypeNDJSON = "application/x-ndjson"
+
+// APIKeyStream reads a key listing incrementally, holding a single key in
+// memory at a time. Call Next until it returns false, then check Err, and
+// always Close the stream:
+//
+//	stream, err := client.ListAPIKeysStream(ctx, opts)
+//	if err != nil {
+//		...
+//	}
+//	defer stream.Close()
+//	for stream.Next() {
+//		key := stream.APIKey()
+//		...
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type APIKeyStream struct {
+	ctx  context.Context
+	c    *Client
+	opts ListAPIKeysOptions
+	r    *request
+
+	body io.ReadCloser
+	dec  *json.Decoder
+
+	// array is set when the server answered with a JSON array page instead
+	// of ND-JSON; next is the cursor of the following page.
+	array bool
+	next  string
+
+	// it replaces the HTTP stream over custom transports.
+	it *APIKeyIterator
+
+	key APIKey
+	err error
+}
+
+// ListAPIKeysStream lists every key matching opts, decoding the response as
+// it arrives instead of buffering the whole listing as ListAPIKeys does.
+// The server is asked for an ND-JSON stream; servers that answer with
+// regular JSON pages are read one key at a time too, following their
+// X-Next-Cursor headers. opts.Page, opts.PerPage and opts.Cursor are not
+// sent. Streaming needs REST; over a custom Transport the stream pages
+// through ListAPIKeysPage instead.
+//
+// The stream is bound to ctx for its whole lifetime. The client's call
+// timeout only bounds the wait for each response; the listing is then read
+// for as long as it takes, failing if no data arrives for the Timeout of the
+// client's http.Client.
+func (c *Client) ListAPIKeysStream(ctx context.Context, opts *ListAPIKeysOptions) (*APIKeyStream, error) {
+	s := &APIKeyStream{ctx: ctx, c: c}
+	if opts != nil {
+		s.opts = *opts
+	}
+	s.opts.Page, s.opts.PerPage, s.opts.Cursor = 0, 0, ""
+
+	if c.transport != nil {
+		s.it = c.ListAPIKeysIter(&s.opts)
+		return s, nil
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open starts reading the listing at s.opts.Cursor.
+func (s *APIKeyStream) open() error {
+	s.r = &request{
+		op:     "ListAPIKeysStream",
+		method: http.MethodGet,
+		url:    s.c.tenantEndpoint(s.opts.Tenant, "apikeys"),
+		query:  s.opts.values(),
+		tenant: s.opts.Tenant,
+		accept: MediaTypeNDJSON + ", application/json;q=0.9",
+		stream: true,
+		// Only stalls are bounded: the listing may be long to read.
+		idleTimeout: s.c.HttpClient.Timeout,
+	}
+	resp, err := s.c.open(s.ctx, s.r)
+	if err != nil {
+		return err
+	}
+
+	s.body = resp.Body
+	s.dec = json.NewDecoder(resp.Body)
+	mediaType, _ := parseMediaType(resp.Header.Get("Content-Type"))
+	s.array = mediaType != MediaTypeNDJSON
+	s.next = resp.Header.Get("X-Next-Cursor")
+
+	if s.array {
+		if err := s.expectDelim('['); err != nil {
+			s.body.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *APIKeyStream) expectDelim(want json.Delim) error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return fmt.Errorf("decode key stream: %w", err)
+	}
+	if tok != want {
+		return fmt.Errorf("decode key stream: unexpected %v, want %v", tok, want)
+	}
+	return nil
+}
+
+// Next advances to the next key. It returns false at the end of the listing
+// or when an error occurred.
+func (s *APIKeyStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.it != nil {
+		if !s.it.Next(s.ctx) {
+			s.err = s.it.Err()
+			return false
+		}
+		s.key = s.it.APIKey()
+		return true
+	}
+	if s.body == nil {
+		return false
+	}
+
+	for s.array && !s.dec.More() {
+		// End of the page: consume its closing bracket and move on to the
+		// next one, if any.
+		if err := s.expectDelim(']'); err != nil {
+			s.fail(err)
+			return false
+		}
+		s.body.Close()
+		s.body = nil
+		if s.next == "" {
+			return false
+		}
+
+		s.opts.Cursor = s.next
+		if err := s.open(); err != nil {
+			s.err = err
+			return false
+		}
+	}
+
+	var key APIKey
+	if err := s.dec.Decode(&key); err != nil {
+		if !s.array && errors.Is(err, io.EOF) {
+			s.body.Close()
+			s.body = nil
+			return false
+		}
+		s.fail(fmt.Errorf("decode key stream: %w", err))
+		return false
+	}
+	s.c.prepareKeys(s.r, &key)
+	s.key = key
+	return true
+}
+
+func (s *APIKeyStream) fail(err error) {
+	s.err = err
+	s.body.Close()
+	s.body = nil
+}
+
+// APIKey returns the current key. It is only valid after Next returned true.
+func (s *APIKeyStream) APIKey() APIKey {
+	return s.key
+}
+
+// Err returns the error that stopped the stream, if any.
+func (s *APIKeyStream) Err() error {
+	return s.err
+}
+
+// Close releases the connection of the stream. It is safe to call more
+// than once.
+func (s *APIKeyStream) Close() error {
+	if s.body == nil {
+		return nil
+	}
+	err := s.body.Close()
+	s.body = nil
+	return err
+}
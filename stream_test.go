@@ -0,0 +1,71 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestListAPIKeysStreamTimeouts(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		pauses  []time.Duration
+		wantErr error
+	}{
+		// Each pause is under the timeout but the listing takes longer.
+		{"slow listing", []time.Duration{60 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond}, nil},
+		{"stalled listing", []time.Duration{0, 3 * timeout}, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", apikeysclient.MediaTypeNDJSON)
+				enc := json.NewEncoder(w)
+				for _, pause := range tt.pauses {
+					select {
+					case <-time.After(pause):
+					case <-r.Context().Done():
+						return
+					}
+					enc.Encode(apikeysclient.APIKey{ID: uuid.New()})
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer srv.Close()
+
+			client, err := apikeysclient.NewClient(srv.URL,
+				apikeysclient.WithTimeout(timeout),
+				apikeysclient.WithCallTimeout(timeout))
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream, err := client.ListAPIKeysStream(context.Background(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			n := 0
+			for stream.Next() {
+				n++
+			}
+			if err := stream.Err(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && n != len(tt.pauses) {
+				t.Errorf("read %d keys, want %d", n, len(tt.pauses))
+			}
+		})
+	}
+}
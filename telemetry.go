@@ -0,0 +1,126 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/PiccoloMondoC/apikeysclient/internal/redact"
+)
+
+// instrumentationName identifies this package to OpenTelemetry providers.
+const instrumentationName = "github.com/PiccoloMondoC/apikeysclient"
+
+// WithTracerProvider records a span for every client call using tp. Spans
+// carry the client method, HTTP method, status code and key ID; key material
+// is redacted from paths.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(_ *Client, o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider records request counts, errors and latencies using mp.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(_ *Client, o *options) {
+		o.meterProvider = mp
+	}
+}
+
+// telemetry holds the OpenTelemetry instruments of a Client. A Client
+// without tracer or meter provider has a nil telemetry.
+type telemetry struct {
+	tracer trace.Tracer
+
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider) (*telemetry, error) {
+	t := &telemetry{}
+	if tp != nil {
+		t.tracer = tp.Tracer(instrumentationName)
+	}
+
+	if mp != nil {
+		meter := mp.Meter(instrumentationName)
+
+		var err error
+		t.requests, err = meter.Int64Counter("apikeys.client.requests",
+			metric.WithDescription("Number of calls made to the keys server."))
+		if err != nil {
+			return nil, err
+		}
+		t.errors, err = meter.Int64Counter("apikeys.client.errors",
+			metric.WithDescription("Number of calls to the keys server that failed."))
+		if err != nil {
+			return nil, err
+		}
+		t.duration, err = meter.Float64Histogram("apikeys.client.request.duration",
+			metric.WithDescription("Duration of calls to the keys server, including retries."),
+			metric.WithUnit("s"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// start begins instrumenting r. The returned function must be called with the
+// outcome of the call.
+func (t *telemetry) start(ctx context.Context, r *request) (context.Context, func(*http.Response, error)) {
+	attrs := []attribute.KeyValue{
+		attribute.String("apikeys.method", r.op),
+		attribute.String("http.request.method", r.method),
+	}
+	if r.keyID != uuid.Nil {
+		attrs = append(attrs, attribute.String("apikeys.key_id", r.keyID.String()))
+	}
+
+	var span trace.Span
+	if t.tracer != nil {
+		spanAttrs := attrs
+		if u, err := url.Parse(r.url); err == nil {
+			spanAttrs = append(spanAttrs, attribute.String("url.path", redact.Path(u.Path)))
+		}
+		ctx, span = t.tracer.Start(ctx, "apikeys."+r.op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(spanAttrs...))
+	}
+
+	began := time.Now()
+
+	return ctx, func(resp *http.Response, err error) {
+		outcome := attrs
+		if resp != nil {
+			outcome = append(outcome, attribute.Int("http.response.status_code", resp.StatusCode))
+		}
+
+		if span != nil {
+			span.SetAttributes(outcome[len(attrs):]...)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+
+		if t.requests != nil {
+			set := metric.WithAttributes(outcome...)
+			t.requests.Add(ctx, 1, set)
+			if err != nil {
+				t.errors.Add(ctx, 1, set)
+			}
+			t.duration.Record(ctx, time.Since(began).Seconds(), set)
+		}
+	}
+}
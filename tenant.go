@@ -0,0 +1,114 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// Headers carrying the tenant of requests in TenantHeaders mode.
+const (
+	OrgIDHeader     = "X-Org-ID"
+	ProjectIDHeader = "X-Project-ID"
+)
+
+// Tenant identifies the organization, and optionally the project within it,
+// that calls are scoped to on a keys service hosting several products.
+type Tenant struct {
+	OrgID     string
+	ProjectID string
+}
+
+// IsZero reports whether t scopes nothing.
+func (t Tenant) IsZero() bool {
+	return t.OrgID == "" && t.ProjectID == ""
+}
+
+// TenantMode is how the tenant of a call is sent to the server.
+type TenantMode int
+
+const (
+	// TenantHeaders sends the tenant in the OrgIDHeader and ProjectIDHeader
+	// headers.
+	TenantHeaders TenantMode = iota
+
+	// TenantPathPrefix sends calls under /orgs/{org}/projects/{project},
+	// or /orgs/{org} without a project, relative to the base URL.
+	TenantPathPrefix
+)
+
+// WithTenant scopes every call of the client to t, so one client per
+// tenant can share a keys service. Listings can be scoped to other tenants
+// through ListAPIKeysOptions.Tenant and ListTenantServiceAccounts.
+func WithTenant(t Tenant) Option {
+	return func(c *Client, _ *options) {
+		c.tenant = t
+	}
+}
+
+// WithTenantMode sets how tenants are sent, TenantHeaders by default.
+func WithTenantMode(mode TenantMode) Option {
+	return func(c *Client, _ *options) {
+		c.tenantMode = mode
+	}
+}
+
+// Tenant returns the tenant set with WithTenant.
+func (c *Client) Tenant() Tenant {
+	return c.tenant
+}
+
+// tenantEndpoint is endpoint for calls scoped to t. A zero t scopes them to
+// the client's tenant.
+func (c *Client) tenantEndpoint(t Tenant, elems ...string) string {
+	if t.IsZero() {
+		t = c.tenant
+	}
+	if c.tenantMode == TenantPathPrefix && t.OrgID != "" {
+		prefix := []string{"orgs", t.OrgID}
+		if t.ProjectID != "" {
+			prefix = append(prefix, "projects", t.ProjectID)
+		}
+		elems = append(prefix, elems...)
+	}
+	return c.joinEndpoint(elems...)
+}
+
+// requestTenant returns the tenant r is scoped to.
+func (c *Client) requestTenant(r *request) Tenant {
+	if r.tenant.IsZero() {
+		return c.tenant
+	}
+	return r.tenant
+}
+
+// setTenantHeaders sets the tenant headers of req in TenantHeaders mode.
+func (c *Client) setTenantHeaders(req *http.Request, r *request) {
+	if c.tenantMode != TenantHeaders {
+		return
+	}
+	t := c.requestTenant(r)
+	if t.OrgID != "" {
+		req.Header.Set(OrgIDHeader, t.OrgID)
+	}
+	if t.ProjectID != "" {
+		req.Header.Set(ProjectIDHeader, t.ProjectID)
+	}
+}
+
+// ListTenantServiceAccounts returns every service account of tenant, as
+// ListServiceAccounts does for the client's own tenant.
+func (c *Client) ListTenantServiceAccounts(ctx context.Context, tenant Tenant) ([]ServiceAccount, error) {
+	var accounts []ServiceAccount
+	_, err := c.do(ctx, &request{
+		op:     "ListTenantServiceAccounts",
+		method: http.MethodGet,
+		url:    c.tenantEndpoint(tenant, "serviceaccounts"),
+		in:     tenant,
+		tenant: tenant,
+	}, &accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,73 @@
+package apikeysclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// ErrTLSTransport is returned by NewClient when TLS, proxy or dialing
+// options are combined with an http.Client whose Transport is not an
+// *http.Transport, which the client cannot configure. Configure that
+// transport instead.
+var ErrTLSTransport = errors.New("TLS, proxy and dialing options need an *http.Transport")
+
+// WithTLSConfig sets the TLS configuration of connections to the keys
+// server. WithClientCertificate and WithRootCAs apply on top of it. The
+// http.Client in use is copied, with a copy of its transport, so a
+// caller-supplied client is not mutated.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(_ *Client, o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithClientCertificate authenticates the client to servers requiring
+// mutual TLS with the PEM-encoded certificate and private key in certFile
+// and keyFile. The files are read by NewClient, which fails if they cannot
+// be loaded; clients must be recreated to pick up renewed certificates.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(_ *Client, o *options) {
+		o.clientCertFile, o.clientKeyFile = certFile, keyFile
+	}
+}
+
+// WithRootCAs sets the certificate authorities trusted to sign the keys
+// server's certificate, such as a mesh's internal CA, instead of the
+// system's.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(_ *Client, o *options) {
+		o.rootCAs = pool
+	}
+}
+
+// hasTLS reports whether o configures TLS.
+func (o *options) hasTLS() bool {
+	return o.tlsConfig != nil || o.clientCertFile != "" || o.rootCAs != nil
+}
+
+// configureTLS installs the TLS settings of o on t.
+func (o *options) configureTLS(t *http.Transport) error {
+	cfg := o.tlsConfig.Clone()
+	if cfg == nil {
+		cfg = t.TLSClientConfig.Clone()
+	}
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if o.rootCAs != nil {
+		cfg.RootCAs = o.rootCAs
+	}
+	if o.clientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.clientCertFile, o.clientKeyFile)
+		if err != nil {
+			return fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = append(slices.Clip(cfg.Certificates), cert)
+	}
+	t.TLSClientConfig = cfg
+	return nil
+}
@@ -0,0 +1,149 @@
+package apikeysclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient/jwks"
+)
+
+// Errors returned by VerifyToken.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Token is a short-lived JWT issued in exchange for an API key.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// TokenClaims are the claims of a token issued by ExchangeForToken.
+type TokenClaims struct {
+	Issuer string
+
+	// ServiceAccountID is the token's subject, the service account owning
+	// the exchanged key.
+	ServiceAccountID uuid.UUID
+
+	// KeyID is the ID of the exchanged key.
+	KeyID uuid.UUID
+
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the token is granted scope.
+func (cl *TokenClaims) HasScope(scope string) bool {
+	return slices.Contains(cl.Scopes, scope)
+}
+
+// tokenPayload is the JSON form of TokenClaims. Scopes are space separated
+// as in OAuth 2.0.
+type tokenPayload struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub"`
+	KeyID     string `json:"key_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+// ExchangeForToken trades apiKey for a signed, short-lived JWT carrying the
+// key's service account and scopes. Services receiving the token can check
+// it with VerifyToken without ever seeing the long-lived key.
+func (c *Client) ExchangeForToken(ctx context.Context, apiKey string) (*Token, error) {
+	var token Token
+	_, err := c.do(ctx, &request{
+		op:     "ExchangeForToken",
+		method: http.MethodPost,
+		url:    c.endpoint("token", "exchange"),
+		body:   keyRequest{APIKey: apiKey},
+		in:     apiKey,
+	}, &token, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// VerifyToken checks the signature and lifetime of a token issued by
+// ExchangeForToken against the server's JSON Web Key Set and returns its
+// claims. The key set is cached as described in package jwks. Verification
+// fails with ErrInvalidToken or ErrTokenExpired.
+func (c *Client) VerifyToken(ctx context.Context, token string) (*TokenClaims, error) {
+	raw, err := c.tokenKeys.Verify(ctx, token)
+	switch {
+	case errors.Is(err, jwks.ErrMalformedToken), errors.Is(err, jwks.ErrUnknownKey), errors.Is(err, jwks.ErrInvalidSignature):
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	case err != nil:
+		return nil, err
+	}
+
+	var payload tokenPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	return payload.claims(time.Now())
+}
+
+// fetchTokenKeys retrieves the server's JSON Web Key Set for VerifyToken.
+func (c *Client) fetchTokenKeys(ctx context.Context) (*jwks.KeySet, error) {
+	var set jwks.KeySet
+	_, err := c.do(ctx, &request{
+		op:     "GetJWKS",
+		method: http.MethodGet,
+		url:    c.endpoint(".well-known", "jwks.json"),
+	}, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+// claims checks the lifetime of the token at now and returns its claims.
+func (p *tokenPayload) claims(now time.Time) (*TokenClaims, error) {
+	if p.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	}
+	if p.NotBefore != 0 && now.Before(time.Unix(p.NotBefore, 0)) {
+		return nil, fmt.Errorf("%w: not yet valid", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(p.ExpiresAt, 0)) {
+		return nil, ErrTokenExpired
+	}
+
+	sa, err := uuid.Parse(p.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: subject is not a service account ID", ErrInvalidToken)
+	}
+	claims := &TokenClaims{
+		Issuer:           p.Issuer,
+		ServiceAccountID: sa,
+		Scopes:           strings.Fields(p.Scope),
+		ExpiresAt:        time.Unix(p.ExpiresAt, 0),
+	}
+	if p.KeyID != "" {
+		if claims.KeyID, err = uuid.Parse(p.KeyID); err != nil {
+			return nil, fmt.Errorf("%w: malformed key_id", ErrInvalidToken)
+		}
+	}
+	if p.IssuedAt != 0 {
+		claims.IssuedAt = time.Unix(p.IssuedAt, 0)
+	}
+	return claims, nil
+}
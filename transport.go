@@ -0,0 +1,218 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnsupportedOperation is returned, possibly wrapped, by transports for
+// client calls they cannot carry.
+var ErrUnsupportedOperation = errors.New("operation not supported by transport")
+
+// Transport carries client calls to the keys server by other means than the
+// built-in REST implementation, such as gRPC or an in-memory fake. Install
+// one with WithTransport. Options that act on HTTP exchanges (retries,
+// interceptors, logging) do not apply to custom transports; the circuit
+// breaker, validation cache and telemetry do.
+type Transport interface {
+	// RoundTrip performs call, decoding its result into call.Output. Errors
+	// reported by the server should be *APIError values with the equivalent
+	// HTTP status code so they match the package's sentinel errors.
+	RoundTrip(ctx context.Context, call *Call) error
+}
+
+// Call is a single client call handed to a Transport. Op names the client
+// method and determines the types of Input and Output:
+//
+//	Op                           Input                      Output
+//	CreateAPIKey                 APIKey                     *APIKey
+//	CreateEphemeralKey           EphemeralKeyInput          *APIKey
+//	GetAPIKeyByID                uuid.UUID                  *APIKey
+//	GetAPIKeyByAPIKey            string                     *APIKey
+//	GetAPIKeyByHash              string (key hash)          *APIKey
+//	LookupAPIKey                 string                     *APIKey
+//	UpdateAPIKey                 *APIKey                    *APIKey
+//	PatchAPIKey                  PatchAPIKeyInput           *APIKey
+//	DeleteAPIKey                 uuid.UUID                  nil
+//	PurgeAPIKey                  uuid.UUID                  nil
+//	ListAPIKeys                  nil                        *[]APIKey
+//	ListAPIKeysPage              *ListAPIKeysOptions        *[]APIKey
+//	ListAPIKeysByServiceAccount  ServiceAccountListInput    *[]APIKey
+//	SearchAPIKeys                SearchInput                *SearchResults
+//	ValidateAPIKey               string                     *ValidateResponse
+//	ValidateAPIKeyHash           string (key hash)          *ValidateResponse
+//	ValidateAPIKeyPOST           string                     *ValidateResponse
+//	RotateAPIKey                 uuid.UUID                  *RotateAPIKeyResponse
+//	RotateAPIKeys                []uuid.UUID                *Operation
+//	RevealAPIKey                 uuid.UUID                  reveal response
+//	RevokeAPIKey                 uuid.UUID                  *APIKey
+//	ActivateAPIKey               uuid.UUID                  *APIKey
+//	SuspendAPIKey                uuid.UUID                  *APIKey
+//	ResumeAPIKey                 uuid.UUID                  *APIKey
+//	ExtendExpiry                 ExtendExpiryInput          *APIKey
+//	GetRestrictions              uuid.UUID                  *Restrictions
+//	SetRestrictions              SetRestrictionsInput       *APIKey
+//	SetKeyQuota                  SetKeyQuotaInput           *APIKey
+//	GetKeyQuotaUsage             uuid.UUID                  *QuotaUsage
+//	GetAPIKeyUsage               UsageInput                 *APIKeyUsage
+//	ReportUsage                  []UsageEvent               nil
+//	ListAuditEvents              *ListAuditEventsOptions    *AuditEventPage
+//	ExchangeForToken             string                     *Token
+//	GetJWKS                      nil                        *jwks.KeySet
+//	CreateServiceAccount         ServiceAccount             *ServiceAccount
+//	GetServiceAccount            uuid.UUID                  *ServiceAccount
+//	ListServiceAccounts          nil                        *[]ServiceAccount
+//	ListTenantServiceAccounts    Tenant                     *[]ServiceAccount
+//	DeleteServiceAccount         DeleteServiceAccountInput  nil
+//	CreateWebhook                Webhook                    *Webhook
+//	ListWebhooks                 nil                        *[]Webhook
+//	DeleteWebhook                uuid.UUID                  nil
+//	CreateAPIKeys                []APIKeyRequest            bulk response
+//	DeleteAPIKeys                []uuid.UUID                bulk response
+//	PollRevocations              string (since cursor)      revocations page
+//	Health                       nil                        *HealthStatus
+//	GetVersion                   nil                        *ServerVersion
+//	GetCapabilities              nil                        *Capabilities
+//	GetOperation                 string (operation ID)      *Operation
+//
+// Transports should return ErrUnsupportedOperation for bulk and revocation
+// calls they do not implement; batch methods then fall back to single calls.
+// List calls report the total count and next cursor through the
+// X-Total-Count and X-Next-Cursor entries of Header, and any call may report
+// the server's rate limit through the X-RateLimit-* entries.
+type Call struct {
+	Op     string
+	Input  any
+	Output any
+
+	// IdempotencyKey, when set, identifies a mutating call so the server
+	// can deduplicate retries of it.
+	IdempotencyKey string
+
+	// DryRun asks for the call to be checked and its result returned
+	// without applying it; see WithDryRun.
+	DryRun bool
+
+	// Tenant is the tenant the call is scoped to, zero for none; see
+	// WithTenant.
+	Tenant Tenant
+
+	// Actor is who the call is made on behalf of, empty for none; see
+	// WithActor.
+	Actor string
+
+	// Header carries response metadata set by the transport.
+	Header http.Header
+}
+
+// ExtendExpiryInput is the Call input of ExtendExpiry.
+type ExtendExpiryInput struct {
+	ID        uuid.UUID
+	ExpiresAt time.Time
+}
+
+// SetRestrictionsInput is the Call input of SetRestrictions.
+type SetRestrictionsInput struct {
+	ID           uuid.UUID
+	Restrictions Restrictions
+}
+
+// SetKeyQuotaInput is the Call input of SetKeyQuota.
+type SetKeyQuotaInput struct {
+	ID    uuid.UUID
+	Quota Quota
+}
+
+// PatchAPIKeyInput is the Call input of PatchAPIKey.
+type PatchAPIKeyInput struct {
+	ID    uuid.UUID
+	Patch APIKeyPatch
+}
+
+// EphemeralKeyInput is the Call input of CreateEphemeralKey.
+type EphemeralKeyInput struct {
+	ServiceAccountID uuid.UUID
+	TTL              time.Duration
+	Scopes           []string
+}
+
+// UsageInput is the Call input of GetAPIKeyUsage.
+type UsageInput struct {
+	ID       uuid.UUID
+	From, To time.Time
+}
+
+// ServiceAccountListInput is the Call input of ListAPIKeysByServiceAccount.
+type ServiceAccountListInput struct {
+	ServiceAccountID uuid.UUID
+	Options          *ListAPIKeysOptions
+}
+
+// SearchInput is the Call input of SearchAPIKeys.
+type SearchInput struct {
+	Query   string
+	Options *SearchOptions
+}
+
+// DeleteServiceAccountInput is the Call input of DeleteServiceAccount.
+type DeleteServiceAccountInput struct {
+	ID      uuid.UUID
+	Options *DeleteServiceAccountOptions
+}
+
+// WithTransport sends all calls through t instead of the REST API.
+func WithTransport(t Transport) Option {
+	return func(c *Client, _ *options) {
+		c.transport = t
+	}
+}
+
+// roundTrip performs r through the client's custom transport. The returned
+// response is synthesized so callers can treat both paths alike.
+func (c *Client) roundTrip(ctx context.Context, r *request, out any) (resp *http.Response, err error) {
+	resp = &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+
+	ctx, cancel := c.callContext(ctx, r.op)
+	defer cancel()
+
+	if c.telemetry != nil {
+		var end func(*http.Response, error)
+		ctx, end = c.telemetry.start(ctx, r)
+		defer func() { end(resp, err) }()
+	}
+	if c.observer != nil {
+		end := c.observeRequest(r.op)
+		defer func() { end(err) }()
+	}
+
+	if err := c.throttle(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.breaker != nil && !c.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	call := &Call{Op: r.op, Input: r.in, Output: out, IdempotencyKey: r.idempotencyKey, DryRun: c.isDryRun(ctx, r), Tenant: c.requestTenant(r), Actor: c.requestActor(ctx, r), Header: resp.Header}
+	err = c.transport.RoundTrip(ctx, call)
+	c.recordRateLimit(resp.Header)
+
+	if c.breaker != nil {
+		if errors.Is(err, context.Canceled) {
+			c.breaker.release()
+		} else {
+			c.breaker.record(err == nil || !isUnavailable(err))
+		}
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		resp.StatusCode = apiErr.StatusCode
+	}
+
+	return resp, err
+}
@@ -0,0 +1,91 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyUsage reports how a key was used over a time range.
+type APIKeyUsage struct {
+	KeyID uuid.UUID `json:"key_id"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+
+	// RequestCount is the number of requests authenticated with the key in
+	// the range, and ErrorCount how many of them failed.
+	RequestCount int64 `json:"request_count"`
+	ErrorCount   int64 `json:"error_count"`
+
+	// LastUsedAt is when the key was last used at all, nil if never.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// Buckets breaks RequestCount down over the range when the server
+	// provides a breakdown.
+	Buckets []UsageBucket `json:"buckets,omitempty"`
+}
+
+// UsageBucket is the request count of one interval of a usage report.
+type UsageBucket struct {
+	Start        time.Time `json:"start"`
+	RequestCount int64     `json:"request_count"`
+}
+
+// GetAPIKeyUsage retrieves the usage of the key with the given id between
+// from and to. Zero times leave that end of the range to the server's
+// default.
+func (c *Client) GetAPIKeyUsage(ctx context.Context, id uuid.UUID, from, to time.Time) (*APIKeyUsage, error) {
+	q := url.Values{}
+	if !from.IsZero() {
+		q.Set("from", from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		q.Set("to", to.UTC().Format(time.RFC3339))
+	}
+
+	var usage APIKeyUsage
+	_, err := c.do(ctx, &request{
+		op:     "GetAPIKeyUsage",
+		keyID:  id,
+		method: http.MethodGet,
+		url:    c.endpoint("apikeys", id.String(), "usage"),
+		query:  q,
+		in:     UsageInput{ID: id, From: from, To: to},
+	}, &usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// ListStaleKeys returns the active keys not used for at least olderThan:
+// keys whose LastUsedAt is earlier, and keys never used that were created
+// earlier. It pages through all active keys, so it can be slow on large
+// installations.
+func (c *Client) ListStaleKeys(ctx context.Context, olderThan time.Duration) ([]APIKey, error) {
+	cutoff := time.Now().Add(-olderThan)
+	active := true
+
+	var stale []APIKey
+	it := c.ListAPIKeysIter(&ListAPIKeysOptions{PerPage: 100, IsActive: &active})
+	for it.Next(ctx) {
+		key := it.APIKey()
+
+		lastUsed := key.CreatedAt
+		if key.LastUsedAt != nil {
+			lastUsed = *key.LastUsedAt
+		}
+		if lastUsed.Before(cutoff) {
+			stale = append(stale, key)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return stale, nil
+}
@@ -0,0 +1,213 @@
+package apikeysclient
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageEvent is a use of a key observed by a service, as reported by a
+// UsageReporter.
+type UsageEvent struct {
+	KeyID  uuid.UUID `json:"key_id"`
+	UsedAt time.Time `json:"used_at"`
+
+	// RemoteIP is the address of the client that used the key, the zero
+	// Addr if unknown.
+	RemoteIP netip.Addr `json:"remote_ip"`
+}
+
+// UsageReporterConfig configures a UsageReporter.
+type UsageReporterConfig struct {
+	// FlushInterval is the wait between reports of the events recorded
+	// meanwhile. Defaults to 10 seconds.
+	FlushInterval time.Duration
+
+	// MaxBatchSize is the most events sent in one report. Reaching it
+	// triggers a report before FlushInterval has passed. Defaults to 500.
+	MaxBatchSize int
+
+	// MaxPending bounds the events held while reports fail or lag behind.
+	// Further events are dropped and counted by Dropped. Defaults to
+	// 10000.
+	MaxPending int
+
+	// OnError, if set, is called with errors encountered while reporting.
+	// The events of a failed report are kept for the next one.
+	OnError func(error)
+}
+
+// UsageReporter batches the key uses observed by a service and reports them
+// to the server in the background, so LastUsedAt and GetAPIKeyUsage reflect
+// them without adding latency to requests. Once attached to a Client with
+// ReportUsage, the middleware records the keys it accepts; framework
+// adapters do so through RecordUsage.
+type UsageReporter struct {
+	client *Client
+	cfg    UsageReporterConfig
+	full   chan struct{}
+
+	// flushMu serializes reports so each pending event is sent once. It
+	// also guards unacked and unackedKey: the number of pending events of a
+	// failed report and its idempotency key, resent as is until the server
+	// acknowledges them so it can drop the duplicates of a report that did
+	// arrive.
+	flushMu    sync.Mutex
+	unacked    int
+	unackedKey string
+
+	mu      sync.Mutex
+	pending []UsageEvent
+	dropped int64
+}
+
+// ReportUsage attaches a new UsageReporter to c and returns it. The reporter
+// only records events until Run is called, typically in its own goroutine.
+func (c *Client) ReportUsage(cfg UsageReporterConfig) *UsageReporter {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 10 * time.Second
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = 500
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+
+	r := &UsageReporter{
+		client: c,
+		cfg:    cfg,
+		full:   make(chan struct{}, 1),
+	}
+	c.usageReporter.Store(r)
+
+	return r
+}
+
+// RecordUsage records a use of key by the client described by meta with the
+// UsageReporter attached to c, if any. It never blocks. The middleware calls
+// it for the requests it accepts.
+func (c *Client) RecordUsage(key *APIKey, meta RequestMeta) {
+	r := c.usageReporter.Load()
+	if r == nil || key == nil {
+		return
+	}
+	r.Record(UsageEvent{KeyID: key.ID, UsedAt: time.Now().UTC(), RemoteIP: meta.RemoteIP})
+}
+
+// Record adds e to the next report. It never blocks; events beyond
+// MaxPending are dropped.
+func (r *UsageReporter) Record(e UsageEvent) {
+	r.mu.Lock()
+	if len(r.pending) >= r.cfg.MaxPending {
+		r.dropped++
+		r.mu.Unlock()
+		return
+	}
+	r.pending = append(r.pending, e)
+	full := len(r.pending) >= r.cfg.MaxBatchSize
+	r.mu.Unlock()
+
+	if full {
+		select {
+		case r.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because MaxPending events
+// were already held.
+func (r *UsageReporter) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Run reports the recorded events every FlushInterval, and as soon as
+// MaxBatchSize of them are pending, until ctx is done. It then reports the
+// pending events a last time, for at most FlushInterval, and returns
+// ctx.Err().
+func (r *UsageReporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.FlushInterval)
+			r.report(final)
+			cancel()
+			return ctx.Err()
+		case <-ticker.C:
+		case <-r.full:
+		}
+		r.report(ctx)
+	}
+}
+
+// report flushes the pending events, passing errors to OnError.
+func (r *UsageReporter) report(ctx context.Context) {
+	if err := r.Flush(ctx); err != nil && ctx.Err() == nil && r.cfg.OnError != nil {
+		r.cfg.OnError(err)
+	}
+}
+
+// Flush reports the pending events now, in batches of at most MaxBatchSize.
+// When a report fails, its events and those after it are kept for the next
+// flush, which resends the failed batch with the same idempotency key.
+func (r *UsageReporter) Flush(ctx context.Context) error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	for {
+		n := r.unacked
+		r.mu.Lock()
+		if n == 0 {
+			n = min(len(r.pending), r.cfg.MaxBatchSize)
+		}
+		batch := slices.Clone(r.pending[:n])
+		r.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		if r.unacked == 0 {
+			// Each batch needs a key of its own, so one set on ctx is not
+			// used.
+			r.unacked, r.unackedKey = n, r.client.idempotencyKey(context.Background(), "ReportUsage", nil)
+		}
+		if err := r.client.reportUsage(ctx, batch, r.unackedKey); err != nil {
+			return err
+		}
+		r.unacked, r.unackedKey = 0, ""
+
+		// Events are only appended meanwhile, so the batch is still first.
+		r.mu.Lock()
+		r.pending = slices.Delete(r.pending, 0, n)
+		r.mu.Unlock()
+	}
+}
+
+type usageReport struct {
+	Events []UsageEvent `json:"events"`
+}
+
+// reportUsage sends events to POST /apikeys/usage/report with the given
+// idempotency key.
+func (c *Client) reportUsage(ctx context.Context, events []UsageEvent, idempotencyKey string) error {
+	_, err := c.do(ctx, &request{
+		op:             "ReportUsage",
+		method:         http.MethodPost,
+		url:            c.endpoint("apikeys", "usage", "report"),
+		body:           usageReport{Events: events},
+		in:             events,
+		idempotencyKey: idempotencyKey,
+	}, nil, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+	return err
+}
@@ -0,0 +1,75 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+type usageReport struct {
+	key    string
+	events int
+}
+
+// TestUsageReporterResendsFailedBatch checks that a failed report is resent
+// with its idempotency key and events, even once more events are pending.
+func TestUsageReporterResendsFailedBatch(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reports []usageReport
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Events []apikeysclient.UsageEvent `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		reports = append(reports, usageReport{r.Header.Get(apikeysclient.IdempotencyKeyHeader), len(body.Events)})
+		failed := len(reports) == 1
+		mu.Unlock()
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client, err := apikeysclient.NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter := client.ReportUsage(apikeysclient.UsageReporterConfig{MaxBatchSize: 3})
+	record := func(n int) {
+		for range n {
+			reporter.Record(apikeysclient.UsageEvent{KeyID: uuid.New()})
+		}
+	}
+	ctx := apikeysclient.ContextWithIdempotencyKey(context.Background(), "flush")
+
+	record(2)
+	if err := reporter.Flush(ctx); err == nil {
+		t.Fatal("first Flush succeeded, want the 503")
+	}
+	record(2)
+	if err := reporter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3: %v", len(reports), reports)
+	}
+	if reports[0].key == "" || reports[0].key == "flush" || reports[1].key != reports[0].key || reports[1].events != 2 {
+		t.Errorf("resent report = %+v, want the failed %+v", reports[1], reports[0])
+	}
+	if reports[2].key == reports[0].key || reports[2].events != 2 {
+		t.Errorf("next report = %+v, want a new key and the 2 later events", reports[2])
+	}
+}
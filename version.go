@@ -0,0 +1,148 @@
+package apikeysclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// versionDiscoveryBackoff is how long a failed APIVersionAuto discovery is
+// remembered before GET /version is tried again.
+const versionDiscoveryBackoff = 30 * time.Second
+
+// APIVersion is a version of the keys server's REST API.
+type APIVersion string
+
+// API versions understood by the client.
+const (
+	// APIVersion1 responses are bare JSON objects. It is the default.
+	APIVersion1 APIVersion = "v1"
+
+	// APIVersion2 responses wrap their payload in an envelope:
+	//
+	//	{"data": ..., "meta": {"total_count": 42, "next_cursor": "..."}}
+	APIVersion2 APIVersion = "v2"
+
+	// APIVersionAuto picks the newest version supported by both the client
+	// and the server, as reported by GET /version.
+	APIVersionAuto APIVersion = "auto"
+)
+
+// MediaTypeV2 is the media type requesting and identifying APIVersion2
+// responses.
+const MediaTypeV2 = "application/vnd.apikeys.v2+json"
+
+// ServerVersion is the server's build and the API versions it serves.
+type ServerVersion struct {
+	Version     string       `json:"version"`
+	APIVersions []APIVersion `json:"api_versions"`
+}
+
+// WithAPIVersion selects the API version of the server's responses, or
+// APIVersionAuto to negotiate it. Responses are decoded according to their
+// Content-Type, so a server answering an older version than requested
+// still works.
+func WithAPIVersion(v APIVersion) Option {
+	return func(c *Client, _ *options) {
+		c.apiVersion = v
+	}
+}
+
+// GetVersion fetches the server's version information from GET /version.
+func (c *Client) GetVersion(ctx context.Context) (*ServerVersion, error) {
+	var version ServerVersion
+	_, err := c.do(ctx, &request{
+		op:     "GetVersion",
+		method: http.MethodGet,
+		url:    c.endpoint("version"),
+		// The response is always bare so it can be read before negotiating.
+		accept: "application/json",
+	}, &version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// negotiatedVersion returns the API version to request. With
+// APIVersionAuto it is discovered once; servers without GET /version are
+// assumed to serve APIVersion1, and failed discoveries are retried after
+// versionDiscoveryBackoff, falling back to APIVersion1 meanwhile.
+func (c *Client) negotiatedVersion(ctx context.Context) APIVersion {
+	if c.apiVersion != APIVersionAuto {
+		return c.apiVersion
+	}
+	if v := c.resolvedVersion.Load(); v != nil {
+		return *v
+	}
+	if time.Now().UnixNano() < c.versionRetryAt.Load() {
+		return APIVersion1
+	}
+
+	resolved := APIVersion1
+	version, err := c.GetVersion(ctx)
+	switch {
+	case err == nil:
+		if slices.Contains(version.APIVersions, APIVersion2) {
+			resolved = APIVersion2
+		}
+	case !isMissingEndpoint(err):
+		// The caller giving up says nothing about the server.
+		if ctx.Err() == nil {
+			c.versionRetryAt.Store(time.Now().Add(versionDiscoveryBackoff).UnixNano())
+		}
+		return APIVersion1
+	}
+
+	c.resolvedVersion.Store(&resolved)
+	return resolved
+}
+
+// envelope is the body of an APIVersion2 response.
+type envelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta *envelopeMeta   `json:"meta"`
+}
+
+type envelopeMeta struct {
+	TotalCount *int   `json:"total_count"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// isEnveloped reports whether resp is an APIVersion2 response.
+func isEnveloped(resp *http.Response) bool {
+	mediaType, err := parseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == MediaTypeV2
+}
+
+// unwrapEnvelope decodes the envelope of an APIVersion2 response into out.
+// The listing metadata is copied to the X-Total-Count and X-Next-Cursor
+// headers, where APIVersion1 servers report it.
+func unwrapEnvelope(resp *http.Response, out any) error {
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("decode response envelope: %w", err)
+	}
+
+	if meta := env.Meta; meta != nil {
+		if meta.TotalCount != nil && resp.Header.Get("X-Total-Count") == "" {
+			resp.Header.Set("X-Total-Count", strconv.Itoa(*meta.TotalCount))
+		}
+		if meta.NextCursor != "" && resp.Header.Get("X-Next-Cursor") == "" {
+			resp.Header.Set("X-Next-Cursor", meta.NextCursor)
+		}
+	}
+
+	if len(env.Data) == 0 || string(env.Data) == "null" {
+		return errEmptyBody
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package apikeysclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+// TestVersionDiscoveryFailure checks that a failed GET /version is not sent
+// again before each call.
+func TestVersionDiscoveryFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"missing endpoint", http.StatusNotFound},
+		{"server error", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var discoveries atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/version" {
+					discoveries.Add(1)
+					w.WriteHeader(tt.status)
+					return
+				}
+				if accept := r.Header.Get("Accept"); accept != "application/json" {
+					t.Errorf("Accept = %q, want the APIVersion1 default", accept)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			client, err := apikeysclient.NewClient(srv.URL, apikeysclient.WithAPIVersion(apikeysclient.APIVersionAuto))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range 3 {
+				if _, err := client.GetAPIKeyByID(context.Background(), uuid.New()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := discoveries.Load(); got != 1 {
+				t.Errorf("GET /version sent %d times, want 1", got)
+			}
+		})
+	}
+}
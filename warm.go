@@ -0,0 +1,73 @@
+package apikeysclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoValidationCache is returned by WarmCache for clients created without
+// WithValidationCache.
+var ErrNoValidationCache = errors.New("no validation cache configured")
+
+// WarmCache pre-populates the validation cache, and the key cache if there
+// is one, with the active keys of the given service accounts, so the first
+// validations after a deploy do not all go to the server. The accounts are
+// listed concurrently, as many at once as set by WithBatchConcurrency, and
+// only key hashes are stored. It returns the number of keys cached; a
+// failure to list one account does not stop the others, and the errors are
+// joined into the returned error.
+func (c *Client) WarmCache(ctx context.Context, serviceAccountIDs ...uuid.UUID) (int, error) {
+	if c.validationCache == nil {
+		return 0, ErrNoValidationCache
+	}
+
+	var (
+		warmed atomic.Int64
+		mu     sync.Mutex
+		errs   []error
+	)
+	active := true
+	err := forEach(ctx, len(serviceAccountIDs), c.batchConcurrency, func(i int) {
+		id := serviceAccountIDs[i]
+		it := c.ListAPIKeysByServiceAccountIter(id, &ListAPIKeysOptions{IsActive: &active})
+		for it.Next(ctx) {
+			key := it.APIKey()
+			if c.warmKey(ctx, &key) {
+				warmed.Add(1)
+			}
+		}
+		if err := it.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("warm service account %s: %w", id, err))
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return int(warmed.Load()), errors.Join(errs...)
+}
+
+// warmKey caches key as validated if it is currently usable, and reports
+// whether it did.
+func (c *Client) warmKey(ctx context.Context, key *APIKey) bool {
+	hash := key.KeyHash
+	if hash == "" && key.APIKey != "" {
+		hash = HashAPIKey(key.APIKey)
+	}
+	if hash == "" || key.EffectiveStatus() != KeyActive {
+		return false
+	}
+
+	c.validationCache.set(ctx, hash, true, key.ExpiresAt)
+	if c.keyCache != nil {
+		c.keyCache.set(ctx, "hash:"+hash, *key, "")
+	}
+	return true
+}
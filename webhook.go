@@ -0,0 +1,177 @@
+package apikeysclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEventType is a key lifecycle event a webhook can subscribe to.
+type WebhookEventType string
+
+// Webhook event types delivered by the server.
+const (
+	WebhookKeyCreated WebhookEventType = "key.created"
+	WebhookKeyRevoked WebhookEventType = "key.revoked"
+	WebhookKeyExpired WebhookEventType = "key.expired"
+)
+
+// WebhookSignatureHeader is the request header carrying the signature of a
+// webhook delivery, in the form
+//
+//	t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// WebhookTolerance is how far the timestamp of a webhook signature may be
+// from the current time before VerifyWebhookSignature rejects it as a
+// possible replay.
+const WebhookTolerance = 5 * time.Minute
+
+// ErrInvalidWebhookSignature is returned by VerifyWebhookSignature and
+// ParseWebhookEvent for deliveries that were not signed with the secret, or
+// were signed too long ago.
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// Webhook is a URL notified of key lifecycle events.
+type Webhook struct {
+	ID     uuid.UUID          `json:"id"`
+	URL    string             `json:"url"`
+	Events []WebhookEventType `json:"events"`
+
+	// Secret signs the deliveries of the webhook. The server generates it
+	// when none is given; it is only returned by CreateWebhook.
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookEvent is the payload of a webhook delivery.
+type WebhookEvent struct {
+	ID         string           `json:"id"`
+	Type       WebhookEventType `json:"type"`
+	KeyID      uuid.UUID        `json:"key_id"`
+	OccurredAt time.Time        `json:"occurred_at"`
+
+	// Key is the state of the key after the event, without key material.
+	Key *APIKey `json:"key,omitempty"`
+}
+
+// CreateWebhook registers hook.URL to be notified of hook.Events and returns
+// the stored webhook, including its signing secret.
+func (c *Client) CreateWebhook(ctx context.Context, hook Webhook) (*Webhook, error) {
+	var created Webhook
+	_, err := c.do(ctx, &request{
+		op:             "CreateWebhook",
+		method:         http.MethodPost,
+		url:            c.endpoint("webhooks"),
+		body:           hook,
+		in:             hook,
+		idempotencyKey: c.idempotencyKey(ctx, "CreateWebhook", hook),
+	}, &created, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// ListWebhooks returns every registered webhook. Secrets are not included.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	_, err := c.do(ctx, &request{
+		op:     "ListWebhooks",
+		method: http.MethodGet,
+		url:    c.endpoint("webhooks"),
+	}, &hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook unregisters the webhook with the given id.
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, &request{
+		op:             "DeleteWebhook",
+		method:         http.MethodDelete,
+		url:            c.endpoint("webhooks", id.String()),
+		in:             id,
+		idempotencyKey: c.idempotencyKey(ctx, "DeleteWebhook", id),
+	}, nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// SignWebhookPayload returns the WebhookSignatureHeader value of a delivery
+// of payload signed with secret at t. It is used by servers delivering
+// webhooks and by tests of webhook handlers.
+func SignWebhookPayload(payload []byte, secret string, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(webhookMAC(payload, secret, ts))
+}
+
+// VerifyWebhookSignature checks that signature, the WebhookSignatureHeader
+// value of a delivery, signs payload with secret and is no older than
+// WebhookTolerance. It fails with ErrInvalidWebhookSignature.
+func VerifyWebhookSignature(payload []byte, signature, secret string) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(signature, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			// Several signatures are sent while the secret is rotated.
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > WebhookTolerance || age < -WebhookTolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	expected := webhookMAC(payload, secret, ts)
+	for _, sig := range sigs {
+		if hmac.Equal(expected, sig) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+// ParseWebhookEvent verifies a delivery like VerifyWebhookSignature and
+// decodes its payload.
+func ParseWebhookEvent(payload []byte, signature, secret string) (*WebhookEvent, error) {
+	if err := VerifyWebhookSignature(payload, signature, secret); err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decode webhook event: %w", err)
+	}
+	return &event, nil
+}
+
+func webhookMAC(payload []byte, secret, ts string) []byte {
+	msg := make([]byte, 0, len(ts)+1+len(payload))
+	msg = append(append(append(msg, ts...), '.'), payload...)
+	sig, _ := HMACKey(secret).Sign(msg)
+	return sig
+}
@@ -0,0 +1,80 @@
+package apikeysclient_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/PiccoloMondoC/apikeysclient"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"key.revoked"}`)
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	valid := apikeysclient.SignWebhookPayload(payload, secret, now)
+	v1 := valid[strings.Index(valid, ",v1=")+1:]
+	other := apikeysclient.SignWebhookPayload(payload, "whsec_old", now)
+	otherV1 := other[strings.Index(other, ",v1=")+1:]
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{"valid", payload, valid, false},
+		{"spaces around parts", payload, strings.ReplaceAll(valid, ",", " , "), false},
+		{"rotated secret, new signature second", payload, other + "," + v1, false},
+		{"other secret", payload, other, true},
+		{"altered payload", []byte(`{"id":"evt_1","type":"key.created"}`), valid, true},
+		{"too old", payload, apikeysclient.SignWebhookPayload(payload, secret, now.Add(-2*apikeysclient.WebhookTolerance)), true},
+		{"too far ahead", payload, apikeysclient.SignWebhookPayload(payload, secret, now.Add(2*apikeysclient.WebhookTolerance)), true},
+		{"timestamp changed", payload, "t=" + strconv.FormatInt(now.Unix()-1, 10) + "," + v1, true},
+		{"no timestamp", payload, v1, true},
+		{"no signature", payload, "t=" + ts, true},
+		{"malformed signature", payload, "t=" + ts + ",v1=zz," + otherV1, true},
+		{"empty", payload, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := apikeysclient.VerifyWebhookSignature(tt.payload, tt.signature, secret)
+			if tt.wantErr {
+				if !errors.Is(err, apikeysclient.ErrInvalidWebhookSignature) {
+					t.Errorf("VerifyWebhookSignature = %v, want ErrInvalidWebhookSignature", err)
+				}
+			} else if err != nil {
+				t.Errorf("VerifyWebhookSignature = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseWebhookEvent(t *testing.T) {
+	const secret = "whsec_test"
+	keyID := uuid.New()
+	payload := []byte(`{"id":"evt_1","type":"key.revoked","key_id":"` + keyID.String() + `"}`)
+
+	event, err := apikeysclient.ParseWebhookEvent(payload, apikeysclient.SignWebhookPayload(payload, secret, time.Now()), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "evt_1" || event.Type != apikeysclient.WebhookKeyRevoked || event.KeyID != keyID {
+		t.Errorf("ParseWebhookEvent = %+v", event)
+	}
+
+	if _, err := apikeysclient.ParseWebhookEvent(payload, "t=1,v1=00", secret); !errors.Is(err, apikeysclient.ErrInvalidWebhookSignature) {
+		t.Errorf("ParseWebhookEvent of an unsigned delivery = %v, want ErrInvalidWebhookSignature", err)
+	}
+
+	garbled := []byte(`{"id":`)
+	if _, err := apikeysclient.ParseWebhookEvent(garbled, apikeysclient.SignWebhookPayload(garbled, secret, time.Now()), secret); err == nil || errors.Is(err, apikeysclient.ErrInvalidWebhookSignature) {
+		t.Errorf("ParseWebhookEvent of a signed invalid payload = %v, want a decoding error", err)
+	}
+}